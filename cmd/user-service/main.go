@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os/signal"
+	"sort"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -37,6 +41,63 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manage the schema migrations of the stored users",
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Upgrade all the stored users to the latest schema version",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := entrypoint.AppConfig{}
+		configuration.GetConfiguration(viper.GetViper(), &cfg)
+
+		err := entrypoint.MigrateUp(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+
+		zap.L().Info("Migrated the users to the latest schema version")
+		return nil
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the schema versions of the stored users and the applied migrations",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := entrypoint.AppConfig{}
+		configuration.GetConfiguration(viper.GetViper(), &cfg)
+
+		status, err := entrypoint.MigrationStatus(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+
+		out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(out, "Latest schema version:\t%d\n\n", status.LatestVersion)
+
+		versions := make([]int, 0, len(status.Documents))
+		for version := range status.Documents {
+			versions = append(versions, version)
+		}
+		sort.Ints(versions)
+
+		_, _ = fmt.Fprintln(out, "SCHEMA VERSION\tUSERS")
+		for _, version := range versions {
+			_, _ = fmt.Fprintf(out, "%d\t%d\n", version, status.Documents[version])
+		}
+
+		_, _ = fmt.Fprintln(out, "\nMIGRATED TO\tUSERS\tAPPLIED AT\tDESCRIPTION")
+		for _, applied := range status.Applied {
+			_, _ = fmt.Fprintf(out, "%d\t%d\t%s\t%s\n", applied.Version, applied.Documents, applied.AppliedAt.Format(time.RFC3339), applied.Description)
+		}
+
+		return out.Flush()
+	},
+}
+
 func setupGlobalLogger() {
 	// For simplicity, we'll just use the production logger
 	logger, _ := zap.NewProduction()
@@ -60,6 +121,10 @@ func main() {
 	// Add the flags to the root command
 	rootCmd.PersistentFlags().StringVarP(&configFilePath, "config", "c", "", "Path to the configuration file")
 
+	// Add the subcommands
+	migrateCmd.AddCommand(migrateUpCmd, migrateStatusCmd)
+	rootCmd.AddCommand(migrateCmd)
+
 	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		zap.L().Fatal("Failed to execute the root command", zap.Error(err))
@@ -0,0 +1,28 @@
+package users
+
+import "context"
+
+// Caller is the authenticated client calling the service.
+type Caller struct {
+	// Subject identifies the client, e.g. the user or the service the token was issued to.
+	Subject string
+
+	// Issuer is the issuer of the client's token.
+	Issuer string
+
+	// Claims are all the claims of the client's token.
+	Claims map[string]any
+}
+
+type callerKey struct{}
+
+// ContextWithCaller returns the context carrying the authenticated caller.
+func ContextWithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the authenticated caller, if the call was authenticated.
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
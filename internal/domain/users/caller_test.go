@@ -0,0 +1,25 @@
+package users
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestUserService_CallerLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	service := &userServiceImpl{logger: zap.New(core)}
+
+	ctx := ContextWithCaller(context.Background(), Caller{Subject: "faceit-admin"})
+	service.callerLogger(ctx).Info("Authenticated")
+	service.callerLogger(context.Background()).Info("Anonymous")
+
+	entries := logs.All()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "faceit-admin", entries[0].ContextMap()["caller"])
+		assert.NotContains(t, entries[1].ContextMap(), "caller")
+	}
+}
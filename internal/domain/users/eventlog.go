@@ -0,0 +1,189 @@
+package users
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+)
+
+// EventLog delivers the changes of the repositories watched within the process to the watchers. The last changes
+// are kept, so the watchers can resume after a change they have already received.
+type EventLog struct {
+	// id distinguishes the resume tokens of the logs, as the changes of each log are numbered from the start.
+	id string
+
+	// mu orders the published changes and subscribing the watchers, so no change is missed or delivered twice.
+	mu       sync.Mutex
+	size     int
+	events   []UserEvent
+	sequence uint64
+
+	changes *broadcast.Broadcaster[UserEvent]
+}
+
+// NewEventLog creates an EventLog keeping the last size changes, delivering them to the watchers as configured.
+func NewEventLog(size int, cfg broadcast.Config) *EventLog {
+	return &EventLog{
+		id:      uuid.NewString(),
+		size:    size,
+		changes: broadcast.New[UserEvent](cfg),
+	}
+}
+
+// Publish numbers the change and delivers it to the watchers. The changes must be published in the order they were made.
+func (l *EventLog) Publish(event UserEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sequence++
+	event.ResumeToken = l.token(l.sequence)
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	l.events = append(l.events, event)
+	if len(l.events) > l.size {
+		l.events = l.events[len(l.events)-l.size:]
+	}
+
+	l.changes.Publish(event)
+}
+
+// Position returns the resume token continuing with the changes published after the call. The repositories must
+// hold off publishing while reading a snapshot, so the position matches the snapshot.
+func (l *EventLog) Position() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.token(l.sequence)
+}
+
+// token returns the resume token of the change with the sequence number.
+func (l *EventLog) token(sequence uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(l.id + ":" + strconv.FormatUint(sequence, 10)))
+}
+
+// Watch returns the changes matching the query, until the context is done or the watcher is disconnected for
+// falling behind.
+func (l *EventLog) Watch(ctx context.Context, query WatchQuery) (<-chan UserEvent, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	l.mu.Lock()
+	live := l.changes.Subscribe(ctx)
+	missed, err := l.missed(query)
+	l.mu.Unlock()
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	events := make(chan UserEvent)
+	go func() {
+		defer cancel()
+		defer close(events)
+
+		send := func(event UserEvent) bool {
+			// Changes made before the start time are not delivered, even if it is in the future
+			if !query.Matches(event) || (query.StartAt != nil && event.Time.Before(*query.StartAt)) {
+				return true
+			}
+
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, event := range missed {
+			if !send(event) {
+				return
+			}
+		}
+
+		for event := range live {
+			if !send(event) {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// Close stops delivering the changes to the watchers.
+func (l *EventLog) Close(ctx context.Context) error {
+	return l.changes.Close(ctx)
+}
+
+// missed returns the kept changes the watcher has not received yet. Must be called with the lock held.
+func (l *EventLog) missed(query WatchQuery) ([]UserEvent, error) {
+	// The number of the oldest kept change
+	oldest := l.sequence - uint64(len(l.events)) + 1
+
+	switch {
+	case query.ResumeToken != "":
+		sequence, err := l.decodeToken(query.ResumeToken)
+		if err != nil {
+			return nil, err
+		}
+
+		if sequence > l.sequence {
+			return nil, ErrInvalidResumeToken
+		}
+
+		if sequence+1 < oldest {
+			return nil, ErrResumeTokenExpired
+		}
+
+		return slices.Clone(l.events[sequence+1-oldest:]), nil
+	case query.StartAt != nil:
+		// Some changes after the start time might have been dropped already
+		if oldest > 1 && l.events[0].Time.After(*query.StartAt) {
+			return nil, ErrResumeTokenExpired
+		}
+
+		return slices.Clone(l.events), nil
+	default:
+		return nil, nil
+	}
+}
+
+// decodeToken returns the number of the change the resume token was created for.
+func (l *EventLog) decodeToken(token string) (uint64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidResumeToken
+	}
+
+	id, number, ok := strings.Cut(string(data), ":")
+	if !ok {
+		return 0, ErrInvalidResumeToken
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return 0, ErrInvalidResumeToken
+	}
+
+	sequence, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidResumeToken
+	}
+
+	// The changes of the previous logs, e.g. before a restart, are lost
+	if id != l.id {
+		return 0, fmt.Errorf("%w: the changes were made before a restart", ErrResumeTokenExpired)
+	}
+
+	return sequence, nil
+}
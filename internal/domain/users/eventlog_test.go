@@ -0,0 +1,75 @@
+package users
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+)
+
+func TestEventLog_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log := NewEventLog(2, broadcast.Config{BufferSize: 10})
+	defer log.Close(ctx)
+	for _, id := range []string{"1", "2", "3"} {
+		log.Publish(UserEvent{ChangeType: ChangeInsert, User: User{ID: id}})
+	}
+
+	events, err := log.Watch(ctx, WatchQuery{})
+	require.NoError(t, err)
+
+	log.Publish(UserEvent{ChangeType: ChangeDelete, User: User{ID: "1"}})
+	deleted := <-events
+	assert.Equal(t, ChangeDelete, deleted.ChangeType)
+	assert.NotEmpty(t, deleted.ResumeToken)
+
+	t.Run("Resume token", func(t *testing.T) {
+		log.Publish(UserEvent{ChangeType: ChangeDelete, User: User{ID: "2"}})
+
+		resumed, err := log.Watch(ctx, WatchQuery{ResumeToken: deleted.ResumeToken})
+		require.NoError(t, err)
+
+		event := <-resumed
+		assert.Equal(t, ChangeDelete, event.ChangeType)
+		assert.Equal(t, "2", event.User.ID)
+	})
+
+	t.Run("Evicted resume token", func(t *testing.T) {
+		// Only the last two changes are kept, so the change after the token is dropped
+		log.Publish(UserEvent{ChangeType: ChangeDelete, User: User{ID: "3"}})
+		log.Publish(UserEvent{ChangeType: ChangeInsert, User: User{ID: "4"}})
+
+		_, err := log.Watch(ctx, WatchQuery{ResumeToken: deleted.ResumeToken})
+		assert.ErrorIs(t, err, ErrResumeTokenExpired)
+	})
+
+	t.Run("Resume token of another log", func(t *testing.T) {
+		other := NewEventLog(2, broadcast.Config{BufferSize: 10})
+		defer other.Close(ctx)
+
+		_, err := other.Watch(ctx, WatchQuery{ResumeToken: deleted.ResumeToken})
+		assert.ErrorIs(t, err, ErrResumeTokenExpired)
+	})
+
+	t.Run("Invalid resume token", func(t *testing.T) {
+		_, err := log.Watch(ctx, WatchQuery{ResumeToken: "bm90IGEgdG9rZW4"})
+		assert.ErrorIs(t, err, ErrInvalidResumeToken)
+	})
+
+	t.Run("Start time", func(t *testing.T) {
+		_, err := log.Watch(ctx, WatchQuery{StartAt: &deleted.Time})
+		assert.ErrorIs(t, err, ErrResumeTokenExpired)
+
+		startAt := time.Now().Add(-time.Minute)
+		other := NewEventLog(2, broadcast.Config{BufferSize: 10})
+		defer other.Close(ctx)
+
+		_, err = other.Watch(ctx, WatchQuery{StartAt: &startAt})
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,414 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxFilterLength is the longest filter expression accepted.
+const MaxFilterLength = 2048
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Operator compares the value of a field in a Comparison.
+type Operator string
+
+const (
+	OperatorEqual          Operator = "="
+	OperatorNotEqual       Operator = "!="
+	OperatorLess           Operator = "<"
+	OperatorLessOrEqual    Operator = "<="
+	OperatorGreater        Operator = ">"
+	OperatorGreaterOrEqual Operator = ">="
+)
+
+// filterableFields are the fields that can be used in the filter expressions.
+var filterableFields = map[string]bool{
+	"first_name":   true,
+	"last_name":    true,
+	FieldNickname:  true,
+	FieldEmail:     true,
+	FieldCountry:   true,
+	FieldCreatedAt: true,
+	FieldUpdatedAt: true,
+}
+
+// Expression is a parsed filter expression, see ParseFilter.
+type Expression interface {
+	// Matches checks if the user matches the expression.
+	Matches(user User) bool
+}
+
+// AndExpression matches the users matching all the expressions.
+type AndExpression struct {
+	Expressions []Expression
+}
+
+// OrExpression matches the users matching any of the expressions.
+type OrExpression struct {
+	Expressions []Expression
+}
+
+// NotExpression matches the users not matching the expression.
+type NotExpression struct {
+	Expression Expression
+}
+
+// Comparison compares the field of the users with the value. The value is a time.Time for the time fields,
+// otherwise a string.
+type Comparison struct {
+	Field    string
+	Operator Operator
+	Value    any
+}
+
+func (e AndExpression) Matches(user User) bool {
+	for _, expression := range e.Expressions {
+		if !expression.Matches(user) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e OrExpression) Matches(user User) bool {
+	for _, expression := range e.Expressions {
+		if expression.Matches(user) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e NotExpression) Matches(user User) bool {
+	return !e.Expression.Matches(user)
+}
+
+func (c Comparison) Matches(user User) bool {
+	var result int
+	switch value := c.Value.(type) {
+	case time.Time:
+		result = fieldTime(user, c.Field).Compare(value)
+	case string:
+		result = strings.Compare(fieldString(user, c.Field), value)
+	default:
+		return false
+	}
+
+	switch c.Operator {
+	case OperatorEqual:
+		return result == 0
+	case OperatorNotEqual:
+		return result != 0
+	case OperatorLess:
+		return result < 0
+	case OperatorLessOrEqual:
+		return result <= 0
+	case OperatorGreater:
+		return result > 0
+	case OperatorGreaterOrEqual:
+		return result >= 0
+	default:
+		return false
+	}
+}
+
+func fieldTime(user User, field string) time.Time {
+	if field == FieldUpdatedAt {
+		return user.UpdatedAt
+	}
+
+	return user.CreatedAt
+}
+
+func fieldString(user User, field string) string {
+	switch field {
+	case "first_name":
+		return user.FirstName
+	case "last_name":
+		return user.LastName
+	case FieldNickname:
+		return user.Nickname
+	case FieldEmail:
+		return user.Email
+	case FieldCountry:
+		return user.Country
+	default:
+		return ""
+	}
+}
+
+// Expression returns the parsed Filter of the query, or nil if the query has no filter.
+func (q Query) Expression() (Expression, error) {
+	if q.Filter == nil || strings.TrimSpace(*q.Filter) == "" {
+		return nil, nil
+	}
+
+	return ParseFilter(*q.Filter)
+}
+
+// ParseFilter parses a filter expression, following a subset of the AIP-160 grammar:
+//
+//	expression = and_expression
+//	and_expression = or_expression { "AND" or_expression }
+//	or_expression = unary { "OR" unary }
+//	unary = [ "NOT" ] ( "(" expression ")" | comparison )
+//	comparison = field operator value
+//	operator = "=" | "!=" | "<" | "<=" | ">" | ">="
+//
+// The fields are first_name, last_name, nickname, email, country, created_at and updated_at. The values are either
+// quoted using double or single quotes, or bare words, e.g. country = "DE" OR country = AT. The values of the time
+// fields are RFC 3339 timestamps, e.g. created_at >= "2024-01-01T00:00:00Z". The keywords are case-sensitive and
+// OR binds tighter than AND, as specified by AIP-160.
+func ParseFilter(filter string) (Expression, error) {
+	if len(filter) > MaxFilterLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrInvalidFilter, MaxFilterLength)
+	}
+
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &filterParser{tokens: tokens}
+	expression, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	if !parser.done() {
+		return nil, parser.errorf("unexpected %q", parser.peek().value)
+	}
+
+	return expression, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type filterToken struct {
+	kind     tokenKind
+	value    string
+	position int
+}
+
+// tokenizeFilter splits the filter to words, quoted strings, operators and parentheses.
+func tokenizeFilter(filter string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	runes := []rune(filter)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: tokenOpen, value: "(", position: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: tokenClose, value: ")", position: i})
+			i++
+		case r == '"' || r == '\'':
+			value := strings.Builder{}
+			start := i
+
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("%w: unterminated string at position %d", ErrInvalidFilter, start)
+				}
+
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					value.WriteRune(runes[i])
+					continue
+				}
+
+				if runes[i] == r {
+					i++
+					break
+				}
+
+				value.WriteRune(runes[i])
+			}
+
+			tokens = append(tokens, filterToken{kind: tokenString, value: value.String(), position: start})
+		case strings.ContainsRune("=!<>", r):
+			start := i
+			for i < len(runes) && strings.ContainsRune("=!<>", runes[i]) {
+				i++
+			}
+
+			operator := string(runes[start:i])
+			switch Operator(operator) {
+			case OperatorEqual, OperatorNotEqual, OperatorLess, OperatorLessOrEqual, OperatorGreater, OperatorGreaterOrEqual:
+			default:
+				return nil, fmt.Errorf("%w: unknown operator %q at position %d", ErrInvalidFilter, operator, start)
+			}
+
+			tokens = append(tokens, filterToken{kind: tokenOperator, value: operator, position: start})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()\"'=!<>", runes[i]) {
+				i++
+			}
+
+			tokens = append(tokens, filterToken{kind: tokenWord, value: string(runes[start:i]), position: start})
+		}
+	}
+
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	next   int
+}
+
+func (p *filterParser) done() bool {
+	return p.next >= len(p.tokens)
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.next]
+}
+
+// isKeyword checks if the next token is the keyword.
+func (p *filterParser) isKeyword(keyword string) bool {
+	return !p.done() && p.peek().kind == tokenWord && p.peek().value == keyword
+}
+
+func (p *filterParser) errorf(format string, args ...any) error {
+	position := -1
+	if !p.done() {
+		position = p.peek().position
+	}
+
+	if position < 0 {
+		return fmt.Errorf("%w: %s at the end", ErrInvalidFilter, fmt.Sprintf(format, args...))
+	}
+
+	return fmt.Errorf("%w: %s at position %d", ErrInvalidFilter, fmt.Sprintf(format, args...), position)
+}
+
+func (p *filterParser) parseAnd() (Expression, error) {
+	expressions := []Expression{}
+	for {
+		expression, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		expressions = append(expressions, expression)
+		if !p.isKeyword("AND") {
+			break
+		}
+		p.next++
+	}
+
+	if len(expressions) == 1 {
+		return expressions[0], nil
+	}
+
+	return AndExpression{Expressions: expressions}, nil
+}
+
+func (p *filterParser) parseOr() (Expression, error) {
+	expressions := []Expression{}
+	for {
+		expression, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		expressions = append(expressions, expression)
+		if !p.isKeyword("OR") {
+			break
+		}
+		p.next++
+	}
+
+	if len(expressions) == 1 {
+		return expressions[0], nil
+	}
+
+	return OrExpression{Expressions: expressions}, nil
+}
+
+func (p *filterParser) parseUnary() (Expression, error) {
+	if p.done() {
+		return nil, p.errorf("expected a comparison")
+	}
+
+	if p.isKeyword("NOT") {
+		p.next++
+
+		expression, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return NotExpression{Expression: expression}, nil
+	}
+
+	if p.peek().kind == tokenOpen {
+		p.next++
+
+		expression, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.done() || p.peek().kind != tokenClose {
+			return nil, p.errorf("expected a closing parenthesis")
+		}
+		p.next++
+
+		return expression, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (Expression, error) {
+	field := p.peek()
+	if field.kind != tokenWord || !filterableFields[field.value] {
+		return nil, p.errorf("cannot filter by %q", field.value)
+	}
+	p.next++
+
+	if p.done() || p.peek().kind != tokenOperator {
+		return nil, p.errorf("expected an operator")
+	}
+	operator := Operator(p.peek().value)
+	p.next++
+
+	if p.done() || (p.peek().kind != tokenWord && p.peek().kind != tokenString) {
+		return nil, p.errorf("expected a value")
+	}
+	value := p.peek()
+
+	comparison := Comparison{Field: field.value, Operator: operator, Value: value.value}
+	if IsTimeField(field.value) {
+		t, err := time.Parse(time.RFC3339Nano, value.value)
+		if err != nil {
+			return nil, p.errorf("%q is not an RFC 3339 timestamp", value.value)
+		}
+
+		comparison.Value = t
+	}
+	p.next++
+
+	return comparison, nil
+}
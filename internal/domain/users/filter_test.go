@@ -0,0 +1,84 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	expression, err := ParseFilter(`country = "DE" OR country = 'AT' AND NOT (nickname != s1mple)`)
+	require.NoError(t, err)
+
+	// OR binds tighter than AND
+	assert.Equal(t, AndExpression{Expressions: []Expression{
+		OrExpression{Expressions: []Expression{
+			Comparison{Field: FieldCountry, Operator: OperatorEqual, Value: "DE"},
+			Comparison{Field: FieldCountry, Operator: OperatorEqual, Value: "AT"},
+		}},
+		NotExpression{Expression: Comparison{Field: FieldNickname, Operator: OperatorNotEqual, Value: "s1mple"}},
+	}}, expression)
+
+	expression, err = ParseFilter(`created_at>="2024-01-01T00:00:00Z"`)
+	require.NoError(t, err)
+	assert.Equal(t, Comparison{
+		Field:    FieldCreatedAt,
+		Operator: OperatorGreaterOrEqual,
+		Value:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, expression)
+
+	expression, err = ParseFilter(`last_name = "O\"Neil"`)
+	require.NoError(t, err)
+	assert.Equal(t, Comparison{Field: "last_name", Operator: OperatorEqual, Value: `O"Neil`}, expression)
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	tests := []string{
+		``,
+		`country`,
+		`country =`,
+		`country == "DE"`,
+		`password = "secret"`,
+		`country = "DE`,
+		`(country = "DE"`,
+		`country = "DE")`,
+		`country = "DE" AND`,
+		`country = "DE" and nickname = "s1mple"`,
+		`created_at > "yesterday"`,
+		`NOT`,
+	}
+
+	for _, filter := range tests {
+		_, err := ParseFilter(filter)
+		assert.ErrorIs(t, err, ErrInvalidFilter, filter)
+	}
+}
+
+func TestExpression_Matches(t *testing.T) {
+	user := User{
+		Nickname:  "s1mple",
+		Country:   "UA",
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		filter   string
+		expected bool
+	}{
+		{filter: `country = "UA"`, expected: true},
+		{filter: `country != "UA"`, expected: false},
+		{filter: `country = "DE" OR country = "UA"`, expected: true},
+		{filter: `NOT country = "UA"`, expected: false},
+		{filter: `nickname > "a" AND nickname < "t"`, expected: true},
+		{filter: `created_at >= "2024-01-01T00:00:00Z"`, expected: true},
+		{filter: `created_at < "2024-01-01T01:00:00+01:00"`, expected: false},
+	}
+
+	for _, tt := range tests {
+		expression, err := ParseFilter(tt.filter)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, expression.Matches(user), tt.filter)
+	}
+}
@@ -0,0 +1,188 @@
+package users
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultMaxDistance is the largest number of single character edits a fuzzy matched nickname is allowed by default.
+	DefaultMaxDistance = 2
+
+	// DefaultMinSimilarity is the smallest trigram similarity a fuzzy matched nickname is allowed by default.
+	DefaultMinSimilarity = 0.3
+
+	// MaxDistanceLimit is the largest allowed MaxDistance, as larger distances match almost any short nickname.
+	MaxDistanceLimit = 5
+)
+
+// FuzzyOptions configure how close the nickname must be to the searched text to match it.
+type FuzzyOptions struct {
+	// MaxDistance is the largest edit distance between the nickname and the text, defaulting to DefaultMaxDistance.
+	MaxDistance *int `json:"max_distance,omitempty"`
+
+	// MinSimilarity is the smallest trigram similarity of the nickname and the text, defaulting to DefaultMinSimilarity.
+	MinSimilarity *float64 `json:"min_similarity,omitempty"`
+}
+
+func (o FuzzyOptions) maxDistance() int {
+	if o.MaxDistance == nil {
+		return DefaultMaxDistance
+	}
+
+	return *o.MaxDistance
+}
+
+func (o FuzzyOptions) minSimilarity() float64 {
+	if o.MinSimilarity == nil {
+		return DefaultMinSimilarity
+	}
+
+	return *o.MinSimilarity
+}
+
+// Similarity checks if the nickname is close enough to the text - either within the MaxDistance edits, or at least
+// MinSimilarity similar. The nickname must share at least one trigram with the text, so the matching users can be
+// found using an index of the trigrams. The similarity is between 0 and 1, where 1 is an exact (case-insensitive) match.
+func (o FuzzyOptions) Similarity(text, nickname string) (float64, bool) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	nickname = strings.ToLower(nickname)
+
+	trigramSimilarity := TrigramSimilarity(text, nickname)
+	distance := EditDistance(text, nickname)
+
+	// The edit distance relative to the longer of the two, so it is comparable with the trigram similarity
+	longest := max(len([]rune(text)), len([]rune(nickname)), 1)
+	editSimilarity := 1 - float64(distance)/float64(longest)
+
+	matches := trigramSimilarity > 0 && (distance <= o.maxDistance() || trigramSimilarity >= o.minSimilarity())
+	return max(trigramSimilarity, editSimilarity), matches
+}
+
+// Trigrams returns the distinct trigrams of the lowercase words in the text. Each word is padded with two spaces
+// in front and one at the end, so short words and the beginnings of the words have trigrams as well.
+func Trigrams(text string) []string {
+	trigrams := []string{}
+	for _, word := range SearchTerms(text) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			trigram := string(padded[i : i+3])
+			if !slices.Contains(trigrams, trigram) {
+				trigrams = append(trigrams, trigram)
+			}
+		}
+	}
+
+	return trigrams
+}
+
+// TrigramSimilarity returns the number of trigrams shared by both the texts, relative to the number of their
+// distinct trigrams.
+func TrigramSimilarity(a, b string) float64 {
+	first, second := Trigrams(a), Trigrams(b)
+
+	shared := 0
+	for _, trigram := range first {
+		if slices.Contains(second, trigram) {
+			shared++
+		}
+	}
+
+	all := len(first) + len(second) - shared
+	if all == 0 {
+		return 0
+	}
+
+	return float64(shared) / float64(all)
+}
+
+// EditDistance returns the Levenshtein distance of the texts - the number of single character insertions, deletions
+// or substitutions needed to change one into the other.
+func EditDistance(a, b string) int {
+	first, second := []rune(a), []rune(b)
+
+	previous := make([]int, len(second)+1)
+	current := make([]int, len(second)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(first); i++ {
+		current[0] = i
+		for j := 1; j <= len(second); j++ {
+			substitution := previous[j-1]
+			if first[i-1] != second[j-1] {
+				substitution++
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(second)]
+}
+
+// FuzzyPage returns the page of the candidates with a nickname matching the fuzzy search, the most similar first.
+// The candidates must already be filtered by the fields of the query.
+func (q SearchQuery) FuzzyPage(candidates []User) (*UserPage, error) {
+	cursor, err := q.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	type match struct {
+		user       User
+		similarity float64
+	}
+
+	matches := []match{}
+	for _, candidate := range candidates {
+		similarity, ok := q.Fuzzy.Similarity(q.Text, candidate.Nickname)
+		if ok {
+			matches = append(matches, match{user: candidate, similarity: similarity})
+		}
+	}
+
+	// The most similar first, using the id as a tiebreaker in the same direction
+	compareMatches := func(a, b match) int {
+		if a.similarity != b.similarity {
+			return cmp.Compare(b.similarity, a.similarity)
+		}
+
+		return strings.Compare(b.user.ID, a.user.ID)
+	}
+	slices.SortFunc(matches, compareMatches)
+
+	page := &UserPage{Users: []User{}}
+	if q.IncludeTotalSize {
+		total := int64(len(matches))
+		page.TotalSize = &total
+	}
+
+	// Continue after the cursor or skip the offset
+	start := min(q.Skip(), int64(len(matches)))
+	if cursor != nil {
+		similarity, _ := cursor.Values[0].(float64)
+		last := match{user: User{ID: cursor.ID}, similarity: similarity}
+
+		start = int64(sort.Search(len(matches), func(i int) bool {
+			return compareMatches(matches[i], last) > 0
+		}))
+	}
+
+	end := min(start+q.PageSize(), int64(len(matches)))
+	for _, m := range matches[start:end] {
+		page.Users = append(page.Users, m.user)
+	}
+
+	if end < int64(len(matches)) && end > start {
+		last := matches[end-1]
+		page.NextPageToken = NewRelevanceCursor(last.user.ID, last.similarity).Encode()
+	}
+
+	return page, nil
+}
@@ -0,0 +1,53 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "s1mple", b: "s1mple", expected: 0},
+		{a: "s1mple", b: "s1mpel", expected: 2},
+		{a: "s1mple", b: "simple", expected: 1},
+		{a: "zywoo", b: "zywo", expected: 1},
+		{a: "", b: "olek", expected: 4},
+		{a: "żywoo", b: "zywoo", expected: 1},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, EditDistance(tt.a, tt.b), "%s - %s", tt.a, tt.b)
+		assert.Equal(t, tt.expected, EditDistance(tt.b, tt.a), "%s - %s", tt.b, tt.a)
+	}
+}
+
+func TestTrigrams(t *testing.T) {
+	assert.Equal(t, []string{"  o", " ol", "ole", "lek", "ek "}, Trigrams("Olek"))
+	assert.Equal(t, []string{"  a", " a ", "  b", " b "}, Trigrams("a-b"))
+	assert.Empty(t, Trigrams("--"))
+
+	assert.Equal(t, 1.0, TrigramSimilarity("s1mple", "S1MPLE"))
+	assert.InDelta(t, 0.4, TrigramSimilarity("simple", "s1mple"), 0.001)
+	assert.Zero(t, TrigramSimilarity("olek", "zywoo"))
+}
+
+func TestFuzzyOptions_Similarity(t *testing.T) {
+	similarity, matches := FuzzyOptions{}.Similarity("S1mple", "s1mple")
+	assert.True(t, matches)
+	assert.Equal(t, 1.0, similarity)
+
+	similarity, matches = FuzzyOptions{}.Similarity("s1mpel", "s1mple")
+	assert.True(t, matches, "within the default distance")
+	assert.Less(t, similarity, 1.0)
+
+	_, matches = FuzzyOptions{MaxDistance: lo.ToPtr(1), MinSimilarity: lo.ToPtr(0.9)}.Similarity("s1mpel", "s1mple")
+	assert.False(t, matches, "neither within the distance nor similar enough")
+
+	_, matches = FuzzyOptions{MaxDistance: lo.ToPtr(5)}.Similarity("olek", "zywoo")
+	assert.False(t, matches, "no shared trigrams")
+}
@@ -0,0 +1,162 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	FieldCreatedAt = "created_at"
+	FieldUpdatedAt = "updated_at"
+	FieldNickname  = "nickname"
+	FieldEmail     = "email"
+	FieldCountry   = "country"
+
+	// DefaultOrderBy lists the newest users first.
+	DefaultOrderBy = FieldCreatedAt + " desc"
+)
+
+var ErrInvalidOrderBy = errors.New("invalid order by")
+
+// sortableFields are the fields the users can be ordered by. Only indexed fields are allowed, so sorting does not
+// require scanning all the users.
+var sortableFields = map[string]bool{
+	FieldCreatedAt: true,
+	FieldUpdatedAt: true,
+	FieldNickname:  true,
+	FieldEmail:     true,
+	FieldCountry:   true,
+}
+
+// indexedOrderings are the orderings by multiple fields, each backed by an index of all the repositories. As an
+// index can also be read backwards, the users can be ordered in either the same or all the opposite directions.
+var indexedOrderings = []Ordering{
+	{{Field: FieldCountry}, {Field: FieldNickname}},
+	{{Field: FieldCountry}, {Field: FieldCreatedAt, Descending: true}},
+	{{Field: FieldNickname}, {Field: FieldCreatedAt, Descending: true}},
+}
+
+// OrderField is a single field the users are ordered by.
+type OrderField struct {
+	Field      string
+	Descending bool
+}
+
+// Ordering is the order of the users. Users with equal values of all the fields are ordered by their ID,
+// in the direction of the last field, so the order is always stable.
+type Ordering []OrderField
+
+// ParseOrderBy parses a comma separated list of fields, each optionally followed by asc or desc, e.g.
+// "nickname asc, created_at desc". Fields are sorted in ascending order by default. An empty expression
+// returns the DefaultOrderBy.
+func ParseOrderBy(orderBy string) (Ordering, error) {
+	if strings.TrimSpace(orderBy) == "" {
+		orderBy = DefaultOrderBy
+	}
+
+	ordering := Ordering{}
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(orderBy, ",") {
+		tokens := strings.Fields(part)
+		if len(tokens) == 0 || len(tokens) > 2 {
+			return nil, fmt.Errorf("%w: malformed field %q", ErrInvalidOrderBy, strings.TrimSpace(part))
+		}
+
+		field := OrderField{Field: tokens[0]}
+		if !sortableFields[field.Field] {
+			return nil, fmt.Errorf("%w: cannot order by %q", ErrInvalidOrderBy, field.Field)
+		}
+
+		if seen[field.Field] {
+			return nil, fmt.Errorf("%w: duplicate field %q", ErrInvalidOrderBy, field.Field)
+		}
+		seen[field.Field] = true
+
+		if len(tokens) == 2 {
+			switch strings.ToLower(tokens[1]) {
+			case "asc":
+			case "desc":
+				field.Descending = true
+			default:
+				return nil, fmt.Errorf("%w: unknown direction %q", ErrInvalidOrderBy, tokens[1])
+			}
+		}
+
+		ordering = append(ordering, field)
+	}
+
+	if len(ordering) > 1 && !ordering.indexed() {
+		return nil, fmt.Errorf("%w: cannot order by %q, as there is no index for it", ErrInvalidOrderBy, ordering.String())
+	}
+
+	return ordering, nil
+}
+
+// indexed checks if the ordering by multiple fields is one of the indexedOrderings, read in either direction.
+func (o Ordering) indexed() bool {
+	for _, indexed := range indexedOrderings {
+		if len(indexed) != len(o) {
+			continue
+		}
+
+		same, reversed := true, true
+		for i, field := range indexed {
+			same = same && o[i] == field
+			reversed = reversed && o[i] == OrderField{Field: field.Field, Descending: !field.Descending}
+		}
+
+		if same || reversed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String returns the normalized order by expression.
+func (o Ordering) String() string {
+	parts := make([]string, 0, len(o))
+	for _, field := range o {
+		direction := "asc"
+		if field.Descending {
+			direction = "desc"
+		}
+
+		parts = append(parts, field.Field+" "+direction)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// IDDescending returns the direction of the ID tiebreaker.
+func (o Ordering) IDDescending() bool {
+	return len(o) > 0 && o[len(o)-1].Descending
+}
+
+// NewCursor creates the cursor pointing at the user.
+func (o Ordering) NewCursor(user User) Cursor {
+	values := make([]any, 0, len(o))
+	for _, field := range o {
+		switch field.Field {
+		case FieldCreatedAt:
+			values = append(values, user.CreatedAt)
+		case FieldUpdatedAt:
+			values = append(values, user.UpdatedAt)
+		case FieldNickname:
+			values = append(values, user.Nickname)
+		case FieldEmail:
+			values = append(values, user.Email)
+		case FieldCountry:
+			values = append(values, user.Country)
+		}
+	}
+
+	return Cursor{OrderBy: o.String(), Values: values, ID: user.ID}
+}
+
+// IsTimeField checks if the values of the field are times, all the other sortable fields are strings.
+func IsTimeField(field string) bool {
+	return field == FieldCreatedAt || field == FieldUpdatedAt
+}
@@ -0,0 +1,195 @@
+package users
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// DefaultPageSize is the number of users returned when the query has no limit.
+	DefaultPageSize = 30
+
+	// MaxPageSize is the largest number of users returned at once, larger limits are lowered to it.
+	MaxPageSize = 100
+)
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// UserPage is a single page of users matching a Query.
+type UserPage struct {
+	Users []User `json:"users"`
+
+	// NextPageToken continues listing after the last user of this page. Empty when there are no more users.
+	NextPageToken string `json:"next_page_token,omitempty"`
+
+	// TotalSize is the number of users matching the query on all the pages, if it was requested.
+	TotalSize *int64 `json:"total_size,omitempty"`
+}
+
+// Cursor is the position of the last user on a page. As the users are sorted by the ordered fields, with the ID
+// as a tiebreaker, the next page starts right after it, regardless of the users created or deleted in the meantime.
+type Cursor struct {
+	// OrderBy is the normalized ordering of the page, the cursor cannot be used with any other ordering.
+	OrderBy string `json:"order_by"`
+
+	// Values of the ordered fields of the user - times for the time fields, the score for the relevance
+	// and strings for the others.
+	Values []any `json:"values"`
+
+	ID string `json:"id"`
+}
+
+// Encode encodes the cursor to an opaque page token.
+func (c Cursor) Encode() string {
+	// Marshalling the cursor cannot fail
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodePageToken decodes the page token created by Cursor.Encode.
+func DecodePageToken(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	cursor := &Cursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil || cursor.ID == "" {
+		return nil, ErrInvalidPageToken
+	}
+
+	ordering := relevanceOrdering
+	if cursor.OrderBy != relevanceOrdering.String() {
+		ordering, err = ParseOrderBy(cursor.OrderBy)
+	}
+
+	if err != nil || len(ordering) != len(cursor.Values) {
+		return nil, ErrInvalidPageToken
+	}
+
+	// The times are encoded as strings
+	for i, field := range ordering {
+		switch value := cursor.Values[i].(type) {
+		case float64:
+			if field.Field != FieldRelevance {
+				return nil, ErrInvalidPageToken
+			}
+		case string:
+			if field.Field == FieldRelevance {
+				return nil, ErrInvalidPageToken
+			}
+
+			if IsTimeField(field.Field) {
+				cursor.Values[i], err = time.Parse(time.RFC3339Nano, value)
+				if err != nil {
+					return nil, ErrInvalidPageToken
+				}
+			}
+		default:
+			return nil, ErrInvalidPageToken
+		}
+	}
+
+	return cursor, nil
+}
+
+// Validate checks the time ranges, ordering and pagination of the query.
+func (q Query) Validate() error {
+	err := q.validateQuery()
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Cursor()
+	return err
+}
+
+func (q Query) validateQuery() error {
+	if q.Limit != nil && *q.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+
+	if q.Offset != nil && *q.Offset < 0 {
+		return fmt.Errorf("offset must not be negative")
+	}
+
+	if q.Offset != nil && *q.Offset != 0 && q.hasPageToken() {
+		return fmt.Errorf("page token cannot be combined with an offset")
+	}
+
+	if q.CreatedAfter != nil && q.CreatedBefore != nil && !q.CreatedAfter.Before(*q.CreatedBefore) {
+		return fmt.Errorf("created after must be before the created before")
+	}
+
+	if q.UpdatedAfter != nil && q.UpdatedBefore != nil && !q.UpdatedAfter.Before(*q.UpdatedBefore) {
+		return fmt.Errorf("updated after must be before the updated before")
+	}
+
+	_, err := q.Expression()
+	return err
+}
+
+// PageSize returns the number of users to return, capped at MaxPageSize. A missing or zero limit returns the
+// DefaultPageSize, as the older clients leave the limit unset.
+func (q Query) PageSize() int64 {
+	switch {
+	case q.Limit == nil || *q.Limit <= 0:
+		return DefaultPageSize
+	case *q.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return *q.Limit
+	}
+}
+
+// Skip returns the number of users to skip. Queries with a page token never skip any users.
+func (q Query) Skip() int64 {
+	if q.Offset == nil || q.hasPageToken() || *q.Offset < 0 {
+		return 0
+	}
+
+	return *q.Offset
+}
+
+// Ordering returns the parsed OrderBy of the query.
+func (q Query) Ordering() (Ordering, error) {
+	return ParseOrderBy(lo.FromPtr(q.OrderBy))
+}
+
+// Cursor returns the decoded page token, or nil if the query starts from the first page. The page token must
+// have been created with the same ordering as the query.
+func (q Query) Cursor() (*Cursor, error) {
+	ordering, err := q.Ordering()
+	if err != nil {
+		return nil, err
+	}
+
+	return q.cursor(ordering)
+}
+
+func (q Query) cursor(ordering Ordering) (*Cursor, error) {
+	if !q.hasPageToken() {
+		return nil, nil
+	}
+
+	cursor, err := DecodePageToken(*q.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor.OrderBy != ordering.String() {
+		return nil, fmt.Errorf("%w: the page token was created with a different order by", ErrInvalidPageToken)
+	}
+
+	return cursor, nil
+}
+
+func (q Query) hasPageToken() bool {
+	return q.PageToken != nil && *q.PageToken != ""
+}
@@ -8,6 +8,9 @@ import (
 var (
 	ErrUserNotFound      = errors.New("user not found")
 	ErrUserAlreadyExists = errors.New("user already exists")
+
+	// ErrInvalidID is returned for the IDs which are not in the format of the repository, so they cannot belong to any user.
+	ErrInvalidID = errors.New("invalid user id")
 )
 
 type Repository interface {
@@ -15,6 +18,15 @@ type Repository interface {
 	UpdateUser(ctx context.Context, user User) (*User, error)
 	DeleteUser(ctx context.Context, id string) error
 	GetUser(ctx context.Context, id string) (*User, error)
-	GetUsers(ctx context.Context, firstName, lastName, nickname, country, email *string, limit, offset *int64) ([]User, error)
-	Watch(ctx context.Context) (<-chan UserEvent, error)
+	GetUsers(ctx context.Context, query Query) (*UserPage, error)
+	SearchUsers(ctx context.Context, query SearchQuery) (*UserPage, error)
+	GetUserStats(ctx context.Context, query StatsQuery) (*UserStats, error)
+	Watch(ctx context.Context, query WatchQuery) (<-chan UserEvent, error)
+
+	// Snapshot reads all the users at a single point in time, so that watching from the snapshot's position
+	// delivers exactly the changes made after it.
+	Snapshot(ctx context.Context) (*Snapshot, error)
+
+	// Close stops delivering the changes, closing the channels of all the watchers.
+	Close(ctx context.Context) error
 }
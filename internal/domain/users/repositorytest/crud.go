@@ -0,0 +1,138 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+)
+
+func testAddUser(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	user := newUser("s1mple", "DE")
+	require.NoError(t, repository.AddUser(ctx, user))
+
+	require.NotEmpty(t, user.ID, "the user should get an ID")
+
+	stored, err := repository.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assertSameUser(t, *user, *stored)
+	assert.NotEmpty(t, stored.CreatedAt)
+	assert.NotEmpty(t, stored.UpdatedAt)
+}
+
+func testAddUserDuplicateEmail(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	require.NoError(t, repository.AddUser(ctx, newUser("s1mple", "UA")))
+
+	duplicate := newUser("other", "DE")
+	duplicate.Email = "s1mple@faceit.com"
+	err := repository.AddUser(ctx, duplicate)
+	assert.ErrorIs(t, err, users.ErrUserAlreadyExists)
+}
+
+func testGetUserNotFound(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	_, err := repository.GetUser(ctx, deletedUserID(t, repository))
+	assert.ErrorIs(t, err, users.ErrUserNotFound)
+
+	_, err = repository.GetUser(ctx, "not-an-id")
+	assert.ErrorIs(t, err, users.ErrInvalidID)
+}
+
+func testUpdateUser(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	user := newUser("s1mple", "UA")
+	require.NoError(t, repository.AddUser(ctx, user))
+
+	created, err := repository.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+
+	update := users.User{
+		ID:        user.ID,
+		FirstName: "Oleksandr",
+		LastName:  "Kostyliev",
+		Nickname:  "s1mple",
+		Email:     "sasha@faceit.com",
+		Country:   "DE",
+	}
+	updated, err := repository.UpdateUser(ctx, update)
+	require.NoError(t, err)
+	assertSameUser(t, update, *updated)
+
+	stored, err := repository.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assertSameUser(t, update, *stored)
+	assert.WithinDuration(t, created.CreatedAt, stored.CreatedAt, 0, "updating must not change the creation time")
+	assert.False(t, stored.UpdatedAt.Before(created.UpdatedAt))
+}
+
+func testUpdateUserNotFound(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	user := *newUser("s1mple", "UA")
+	user.ID = deletedUserID(t, repository)
+
+	_, err := repository.UpdateUser(ctx, user)
+	assert.ErrorIs(t, err, users.ErrUserNotFound)
+
+	user.ID = "not-an-id"
+	_, err = repository.UpdateUser(ctx, user)
+	assert.ErrorIs(t, err, users.ErrInvalidID)
+}
+
+func testUpdateUserDuplicateEmail(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	first := newUser("s1mple", "UA")
+	require.NoError(t, repository.AddUser(ctx, first))
+
+	second := newUser("ZywOo", "FR")
+	require.NoError(t, repository.AddUser(ctx, second))
+
+	update := *second
+	update.Email = first.Email
+	_, err := repository.UpdateUser(ctx, update)
+	assert.ErrorIs(t, err, users.ErrUserAlreadyExists)
+}
+
+func testDeleteUser(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	user := newUser("s1mple", "UA")
+	require.NoError(t, repository.AddUser(ctx, user))
+	require.NoError(t, repository.DeleteUser(ctx, user.ID))
+
+	_, err := repository.GetUser(ctx, user.ID)
+	assert.ErrorIs(t, err, users.ErrUserNotFound)
+
+	err = repository.DeleteUser(ctx, user.ID)
+	assert.ErrorIs(t, err, users.ErrUserNotFound)
+}
+
+func testDeleteUserNotFound(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	err := repository.DeleteUser(ctx, deletedUserID(t, repository))
+	assert.ErrorIs(t, err, users.ErrUserNotFound)
+
+	err = repository.DeleteUser(ctx, "not-an-id")
+	assert.ErrorIs(t, err, users.ErrInvalidID)
+}
+
+// deletedUserID returns the ID of a deleted user, which is in the format of the repository, but not found.
+func deletedUserID(t *testing.T, repository users.Repository) string {
+	t.Helper()
+
+	user := newUser("deleted", "UA")
+	require.NoError(t, repository.AddUser(context.Background(), user))
+	require.NoError(t, repository.DeleteUser(context.Background(), user.ID))
+
+	return user.ID
+}
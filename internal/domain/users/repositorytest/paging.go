@@ -0,0 +1,332 @@
+package repositorytest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+)
+
+func testGetUsersFilters(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	s1mple := &users.User{FirstName: "Oleksandr", LastName: "Kostyliev", Nickname: "s1mple", Email: "s1mple@faceit.com", Country: "UA"}
+	electronic := &users.User{FirstName: "Denis", LastName: "Sharipov", Nickname: "electroNic", Email: "electronic@faceit.com", Country: "RU"}
+	b1t := &users.User{FirstName: "Valerii", LastName: "Vakhovskyi", Nickname: "b1t", Email: "b1t@faceit.com", Country: "UA"}
+	zywoo := &users.User{FirstName: "Mathieu", LastName: "Herbaut", Nickname: "ZywOo", Email: "zywoo@faceit.com", Country: "FR"}
+	for _, user := range []*users.User{s1mple, electronic, b1t, zywoo} {
+		require.NoError(t, repository.AddUser(ctx, user))
+	}
+
+	tests := []struct {
+		name     string
+		query    users.Query
+		expected []*users.User
+	}{
+		{name: "No filters", expected: []*users.User{zywoo, b1t, electronic, s1mple}},
+		{name: "First name", query: users.Query{FirstName: lo.ToPtr("Denis")}, expected: []*users.User{electronic}},
+		{name: "Last name", query: users.Query{LastName: lo.ToPtr("Herbaut")}, expected: []*users.User{zywoo}},
+		{name: "Nickname", query: users.Query{Nickname: lo.ToPtr("b1t")}, expected: []*users.User{b1t}},
+		{name: "Country", query: users.Query{Country: lo.ToPtr("UA")}, expected: []*users.User{b1t, s1mple}},
+		{name: "Email", query: users.Query{Email: lo.ToPtr("s1mple@faceit.com")}, expected: []*users.User{s1mple}},
+		{name: "Combined", query: users.Query{Country: lo.ToPtr("UA"), Nickname: lo.ToPtr("s1mple")}, expected: []*users.User{s1mple}},
+		{name: "Nickname is case sensitive", query: users.Query{Nickname: lo.ToPtr("S1MPLE")}, expected: []*users.User{}},
+		{name: "No match", query: users.Query{Country: lo.ToPtr("DE")}, expected: []*users.User{}},
+		{name: "Any of the countries", query: users.Query{Countries: []string{"FR", "RU"}}, expected: []*users.User{zywoo, electronic}},
+		{name: "Excluded countries", query: users.Query{ExcludedCountries: []string{"UA", "RU"}}, expected: []*users.User{zywoo}},
+		{
+			name:     "Countries and excluded countries",
+			query:    users.Query{Countries: []string{"UA", "FR"}, ExcludedCountries: []string{"FR"}},
+			expected: []*users.User{b1t, s1mple},
+		},
+		{
+			name:     "Country and countries",
+			query:    users.Query{Country: lo.ToPtr("UA"), Countries: []string{"FR", "RU"}},
+			expected: []*users.User{},
+		},
+		{name: "Filter", query: users.Query{Filter: lo.ToPtr(`country = "FR" OR country = "RU"`)}, expected: []*users.User{zywoo, electronic}},
+		{
+			name:     "Filter with AND and NOT",
+			query:    users.Query{Filter: lo.ToPtr(`country = "UA" AND NOT nickname = "b1t"`)},
+			expected: []*users.User{s1mple},
+		},
+		{
+			name:     "Filter with parentheses",
+			query:    users.Query{Filter: lo.ToPtr(`(country = "UA" AND last_name != "Kostyliev") OR first_name = "Mathieu"`)},
+			expected: []*users.User{zywoo, b1t},
+		},
+		{name: "Filter comparing strings", query: users.Query{Filter: lo.ToPtr(`email < "c"`)}, expected: []*users.User{b1t}},
+		{
+			name:     "Filter combined with fields",
+			query:    users.Query{Filter: lo.ToPtr(`country != "FR"`), Countries: []string{"FR", "UA"}, Nickname: lo.ToPtr("s1mple")},
+			expected: []*users.User{s1mple},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := repository.GetUsers(ctx, tt.query)
+			require.NoError(t, err)
+			assertUserIDs(t, tt.expected, result.Users)
+			assert.Empty(t, result.NextPageToken)
+		})
+	}
+
+	t.Run("Invalid filter", func(t *testing.T) {
+		_, err := repository.GetUsers(ctx, users.Query{Filter: lo.ToPtr(`password = "secret"`)})
+		assert.ErrorIs(t, err, users.ErrInvalidFilter)
+	})
+}
+
+func testGetUsersPagination(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	created := make([]*users.User, 35)
+	for i := range created {
+		created[i] = newUser(fmt.Sprintf("player%d", i), "DE")
+		require.NoError(t, repository.AddUser(ctx, created[i]))
+	}
+
+	// Users are sorted from the newest to the oldest
+	newestFirst := make([]*users.User, len(created))
+	for i, user := range created {
+		newestFirst[len(created)-1-i] = user
+	}
+
+	t.Run("Default limit", func(t *testing.T) {
+		result, err := repository.GetUsers(ctx, users.Query{})
+		require.NoError(t, err)
+		assertUserIDs(t, newestFirst[:users.DefaultPageSize], result.Users)
+		assert.NotEmpty(t, result.NextPageToken)
+		assert.Nil(t, result.TotalSize)
+
+		// The older clients send a zero limit when they do not set it
+		result, err = repository.GetUsers(ctx, users.Query{Limit: lo.ToPtr(int64(0))})
+		require.NoError(t, err)
+		assertUserIDs(t, newestFirst[:users.DefaultPageSize], result.Users)
+	})
+
+	t.Run("Offset without a limit", func(t *testing.T) {
+		// The older clients only page with the offset, getting the default number of users
+		result, err := repository.GetUsers(ctx, users.Query{Offset: lo.ToPtr(int64(3))})
+		require.NoError(t, err)
+		assertUserIDs(t, newestFirst[3:3+users.DefaultPageSize], result.Users)
+	})
+
+	t.Run("Offsets", func(t *testing.T) {
+		for offset := 0; offset < len(created); offset += 10 {
+			result, err := repository.GetUsers(ctx, users.Query{Limit: lo.ToPtr(int64(10)), Offset: lo.ToPtr(int64(offset))})
+			require.NoError(t, err)
+			assertUserIDs(t, newestFirst[offset:min(offset+10, len(created))], result.Users)
+		}
+	})
+
+	t.Run("Offset past the end", func(t *testing.T) {
+		result, err := repository.GetUsers(ctx, users.Query{Limit: lo.ToPtr(int64(10)), Offset: lo.ToPtr(int64(100))})
+		require.NoError(t, err)
+		assert.Empty(t, result.Users)
+		assert.Empty(t, result.NextPageToken)
+	})
+
+	t.Run("Page tokens", func(t *testing.T) {
+		query := users.Query{Limit: lo.ToPtr(int64(10)), IncludeTotalSize: true}
+		for offset := 0; offset < len(created); offset += 10 {
+			result, err := repository.GetUsers(ctx, query)
+			require.NoError(t, err)
+			assertUserIDs(t, newestFirst[offset:min(offset+10, len(created))], result.Users)
+			require.NotNil(t, result.TotalSize)
+			assert.EqualValues(t, len(created), *result.TotalSize)
+
+			if offset+10 >= len(created) {
+				assert.Empty(t, result.NextPageToken, "the last page should not have a next page token")
+			} else {
+				require.NotEmpty(t, result.NextPageToken)
+			}
+
+			query.PageToken = lo.ToPtr(result.NextPageToken)
+		}
+	})
+
+	t.Run("Page tokens with filters", func(t *testing.T) {
+		country := "UA"
+		for _, user := range created[:5] {
+			update := *user
+			update.Country = country
+			_, err := repository.UpdateUser(ctx, update)
+			require.NoError(t, err)
+		}
+
+		query := users.Query{Country: &country, Limit: lo.ToPtr(int64(3)), IncludeTotalSize: true}
+		result, err := repository.GetUsers(ctx, query)
+		require.NoError(t, err)
+		assertUserIDs(t, []*users.User{created[4], created[3], created[2]}, result.Users)
+		assert.EqualValues(t, 5, *result.TotalSize)
+
+		query.PageToken = lo.ToPtr(result.NextPageToken)
+		result, err = repository.GetUsers(ctx, query)
+		require.NoError(t, err)
+		assertUserIDs(t, []*users.User{created[1], created[0]}, result.Users)
+		assert.Empty(t, result.NextPageToken)
+	})
+
+	t.Run("Users created while paginating", func(t *testing.T) {
+		first, err := repository.GetUsers(ctx, users.Query{Limit: lo.ToPtr(int64(10))})
+		require.NoError(t, err)
+
+		// Newer users are listed first, so they must not shift the following pages
+		require.NoError(t, repository.AddUser(ctx, newUser("latecomer", "DE")))
+
+		second, err := repository.GetUsers(ctx, users.Query{Limit: lo.ToPtr(int64(10)), PageToken: lo.ToPtr(first.NextPageToken)})
+		require.NoError(t, err)
+		assertUserIDs(t, newestFirst[10:20], second.Users)
+	})
+
+	t.Run("Limit is capped", func(t *testing.T) {
+		for i := len(created); i <= users.MaxPageSize; i++ {
+			require.NoError(t, repository.AddUser(ctx, newUser(fmt.Sprintf("extra%d", i), "DE")))
+		}
+
+		result, err := repository.GetUsers(ctx, users.Query{Limit: lo.ToPtr(int64(users.MaxPageSize + 50))})
+		require.NoError(t, err)
+		assert.Len(t, result.Users, users.MaxPageSize)
+		assert.NotEmpty(t, result.NextPageToken)
+	})
+}
+
+func testGetUsersTimeRanges(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	// Times are stored with a millisecond precision, so the users are created in different milliseconds
+	created := make([]*users.User, 3)
+	for i := range created {
+		user := newUser(fmt.Sprintf("player%d", i), "DE")
+		require.NoError(t, repository.AddUser(ctx, user))
+
+		stored, err := repository.GetUser(ctx, user.ID)
+		require.NoError(t, err)
+		created[i] = stored
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	updated, err := repository.UpdateUser(ctx, *created[0])
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		query    users.Query
+		expected []*users.User
+	}{
+		{name: "Created after", query: users.Query{CreatedAfter: &created[1].CreatedAt}, expected: []*users.User{created[2], created[1]}},
+		{name: "Created before", query: users.Query{CreatedBefore: &created[1].CreatedAt}, expected: []*users.User{created[0]}},
+		{
+			name:     "Created between",
+			query:    users.Query{CreatedAfter: &created[1].CreatedAt, CreatedBefore: &created[2].CreatedAt},
+			expected: []*users.User{created[1]},
+		},
+		{name: "Updated after", query: users.Query{UpdatedAfter: &updated.UpdatedAt}, expected: []*users.User{created[0]}},
+		{name: "Updated before", query: users.Query{UpdatedBefore: &updated.UpdatedAt}, expected: []*users.User{created[2], created[1]}},
+		{
+			name:     "Combined with fields",
+			query:    users.Query{CreatedBefore: &created[2].CreatedAt, Nickname: lo.ToPtr("player1")},
+			expected: []*users.User{created[1]},
+		},
+		{
+			name:     "Filter",
+			query:    users.Query{Filter: lo.ToPtr(`created_at >= "` + created[1].CreatedAt.Format(time.RFC3339Nano) + `"`)},
+			expected: []*users.User{created[2], created[1]},
+		},
+		{
+			name:     "Filter with a range",
+			query:    users.Query{Filter: lo.ToPtr(`updated_at > "` + created[1].UpdatedAt.Format(time.RFC3339Nano) + `" AND created_at <= "` + created[1].CreatedAt.Format(time.RFC3339Nano) + `"`)},
+			expected: []*users.User{created[0]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := repository.GetUsers(ctx, tt.query)
+			require.NoError(t, err)
+			assertUserIDs(t, tt.expected, result.Users)
+		})
+	}
+}
+
+func testGetUsersOrdering(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	alpha := newUser("alpha", "UA")
+	bravo := newUser("bravo", "DE")
+	charlie := newUser("charlie", "UA")
+	delta := newUser("delta", "DE")
+	echo := newUser("echo", "UA")
+
+	for _, user := range []*users.User{alpha, bravo, charlie, delta, echo} {
+		require.NoError(t, repository.AddUser(ctx, user))
+	}
+
+	// Times are stored with a millisecond precision
+	time.Sleep(10 * time.Millisecond)
+	_, err := repository.UpdateUser(ctx, *bravo)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		orderBy  string
+		expected []*users.User
+	}{
+		{name: "Ascending", orderBy: "nickname asc", expected: []*users.User{alpha, bravo, charlie, delta, echo}},
+		{name: "Ascending by default", orderBy: "nickname", expected: []*users.User{alpha, bravo, charlie, delta, echo}},
+		{name: "Descending", orderBy: "nickname desc", expected: []*users.User{echo, delta, charlie, bravo, alpha}},
+		{name: "Multiple fields", orderBy: "country asc, created_at desc", expected: []*users.User{delta, bravo, echo, charlie, alpha}},
+		{name: "Multiple fields reversed", orderBy: "country desc, created_at asc", expected: []*users.User{alpha, charlie, echo, bravo, delta}},
+		{name: "Ascending tiebreaker", orderBy: "country", expected: []*users.User{bravo, delta, alpha, charlie, echo}},
+		{name: "Descending tiebreaker", orderBy: "country desc", expected: []*users.User{echo, charlie, alpha, delta, bravo}},
+		{name: "Oldest first", orderBy: "created_at asc", expected: []*users.User{alpha, bravo, charlie, delta, echo}},
+		{name: "Recently updated first", orderBy: "updated_at desc", expected: []*users.User{bravo, echo, delta, charlie, alpha}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := repository.GetUsers(ctx, users.Query{OrderBy: lo.ToPtr(tt.orderBy)})
+			require.NoError(t, err)
+			assertUserIDs(t, tt.expected, result.Users)
+
+			// Paginating must return the users in the same order
+			query := users.Query{OrderBy: lo.ToPtr(tt.orderBy), Limit: lo.ToPtr(int64(2))}
+			paginated := []users.User{}
+			for {
+				page, err := repository.GetUsers(ctx, query)
+				require.NoError(t, err)
+				paginated = append(paginated, page.Users...)
+
+				if page.NextPageToken == "" {
+					break
+				}
+
+				query.PageToken = lo.ToPtr(page.NextPageToken)
+			}
+			assertUserIDs(t, tt.expected, paginated)
+		})
+	}
+
+	t.Run("Invalid order by", func(t *testing.T) {
+		for _, orderBy := range []string{"password", "nickname up", "nickname, nickname desc", "nickname,", "country asc, nickname desc"} {
+			_, err := repository.GetUsers(ctx, users.Query{OrderBy: lo.ToPtr(orderBy)})
+			assert.ErrorIs(t, err, users.ErrInvalidOrderBy, orderBy)
+		}
+	})
+
+	t.Run("Page token with a different order by", func(t *testing.T) {
+		result, err := repository.GetUsers(ctx, users.Query{OrderBy: lo.ToPtr("nickname"), Limit: lo.ToPtr(int64(2))})
+		require.NoError(t, err)
+
+		_, err = repository.GetUsers(ctx, users.Query{OrderBy: lo.ToPtr("country"), PageToken: lo.ToPtr(result.NextPageToken)})
+		assert.ErrorIs(t, err, users.ErrInvalidPageToken)
+	})
+}
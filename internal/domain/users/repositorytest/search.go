@@ -0,0 +1,200 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+)
+
+// addSearchedUsers adds the users with distinct names, nicknames and emails the searches are tested on.
+func addSearchedUsers(t *testing.T, repository users.Repository) (s1mple, simon, electronic, zywoo, olek *users.User) {
+	ctx := context.Background()
+
+	s1mple = &users.User{FirstName: "Oleksandr", LastName: "Kostyliev", Nickname: "s1mple", Email: "s1mple@faceit.com", Country: "UA"}
+	simon = &users.User{FirstName: "Simon", LastName: "Smith", Nickname: "simonS", Email: "simon@example.com", Country: "DE"}
+	electronic = &users.User{FirstName: "Denis", LastName: "Sharipov", Nickname: "electronic", Email: "electronic@faceit.com", Country: "RU"}
+	zywoo = &users.User{FirstName: "Mathieu", LastName: "Herbaut", Nickname: "ZywOo", Email: "zywoo@faceit.com", Country: "FR"}
+	olek = &users.User{FirstName: "Oleksandr", LastName: "Petrenko", Nickname: "olek", Email: "olek@example.com", Country: "UA"}
+
+	for _, user := range []*users.User{s1mple, simon, electronic, zywoo, olek} {
+		require.NoError(t, repository.AddUser(ctx, user))
+	}
+
+	return s1mple, simon, electronic, zywoo, olek
+}
+
+func testSearchUsersPrefix(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+	s1mple, simon, electronic, zywoo, _ := addSearchedUsers(t, repository)
+
+	tests := []struct {
+		name     string
+		text     string
+		orderBy  *string
+		expected []*users.User
+	}{
+		{name: "Nickname", text: "s1m", expected: []*users.User{s1mple}},
+		{name: "Case insensitive", text: "zYW", expected: []*users.User{zywoo}},
+		{name: "Any field", text: "s", expected: []*users.User{electronic, simon, s1mple}},
+		{name: "Ordered", text: "s", orderBy: lo.ToPtr("nickname asc"), expected: []*users.User{electronic, s1mple, simon}},
+		{name: "Email", text: "simon@", expected: []*users.User{simon}},
+		{name: "Only the prefix", text: "faceit", expected: []*users.User{}},
+		{name: "Wildcards are literal", text: "%", expected: []*users.User{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := users.SearchQuery{Text: tt.text, Mode: users.SearchPrefix, Query: users.Query{OrderBy: tt.orderBy}}
+			result, err := repository.SearchUsers(ctx, query)
+			require.NoError(t, err)
+			assertUserIDs(t, tt.expected, result.Users)
+			assert.Empty(t, result.NextPageToken)
+		})
+	}
+
+	t.Run("Filtered", func(t *testing.T) {
+		query := users.SearchQuery{Text: "s", Mode: users.SearchPrefix, Query: users.Query{Country: lo.ToPtr("UA")}}
+		result, err := repository.SearchUsers(ctx, query)
+		require.NoError(t, err)
+		assertUserIDs(t, []*users.User{s1mple}, result.Users)
+	})
+
+	t.Run("Paginated", func(t *testing.T) {
+		query := users.SearchQuery{Text: "s", Mode: users.SearchPrefix, Query: users.Query{Limit: lo.ToPtr(int64(2)), IncludeTotalSize: true}}
+		result, err := repository.SearchUsers(ctx, query)
+		require.NoError(t, err)
+		assertUserIDs(t, []*users.User{electronic, simon}, result.Users)
+		assert.EqualValues(t, 3, *result.TotalSize)
+
+		query.PageToken = lo.ToPtr(result.NextPageToken)
+		result, err = repository.SearchUsers(ctx, query)
+		require.NoError(t, err)
+		assertUserIDs(t, []*users.User{s1mple}, result.Users)
+		assert.Empty(t, result.NextPageToken)
+	})
+}
+
+func testSearchUsersFullText(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+	s1mple, simon, electronic, zywoo, olek := addSearchedUsers(t, repository)
+
+	search := func(t *testing.T, text string) []users.User {
+		t.Helper()
+
+		result, err := repository.SearchUsers(ctx, users.SearchQuery{Text: text, Mode: users.SearchFullText})
+		require.NoError(t, err)
+		assert.Empty(t, result.NextPageToken)
+		return result.Users
+	}
+
+	t.Run("Single word", func(t *testing.T) {
+		assertUserIDs(t, []*users.User{zywoo}, search(t, "ZYWOO"))
+	})
+
+	t.Run("Any of the words", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{s1mple.ID, olek.ID, simon.ID}, userIDs(search(t, "oleksandr smith")))
+	})
+
+	t.Run("Email", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{s1mple.ID, electronic.ID, zywoo.ID}, userIDs(search(t, "faceit")))
+	})
+
+	t.Run("Most relevant first", func(t *testing.T) {
+		assertUserIDs(t, []*users.User{s1mple, olek}, search(t, "Oleksandr Kostyliev"))
+	})
+
+	t.Run("No match", func(t *testing.T) {
+		assert.Empty(t, search(t, "nobody"))
+	})
+
+	t.Run("Whole words only", func(t *testing.T) {
+		assert.Empty(t, search(t, "oleks"))
+	})
+
+	t.Run("Paginated", func(t *testing.T) {
+		query := users.SearchQuery{
+			Text:  "faceit example",
+			Mode:  users.SearchFullText,
+			Query: users.Query{Limit: lo.ToPtr(int64(2)), IncludeTotalSize: true},
+		}
+
+		found := []users.User{}
+		for {
+			result, err := repository.SearchUsers(ctx, query)
+			require.NoError(t, err)
+			require.NotNil(t, result.TotalSize)
+			assert.EqualValues(t, 5, *result.TotalSize)
+			found = append(found, result.Users...)
+
+			if result.NextPageToken == "" {
+				break
+			}
+
+			query.PageToken = lo.ToPtr(result.NextPageToken)
+		}
+
+		assert.ElementsMatch(t, []string{s1mple.ID, simon.ID, electronic.ID, zywoo.ID, olek.ID}, userIDs(found))
+	})
+}
+
+func testSearchUsersFuzzy(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+	s1mple, simon, electronic, zywoo, _ := addSearchedUsers(t, repository)
+
+	tests := []struct {
+		name     string
+		text     string
+		options  users.FuzzyOptions
+		expected []*users.User
+	}{
+		{name: "Exact", text: "S1MPLE", expected: []*users.User{s1mple}},
+		{name: "Swapped letters", text: "s1mpel", expected: []*users.User{s1mple}},
+		{name: "Missing letter", text: "zywo", expected: []*users.User{zywoo}},
+		{name: "Wrong letter", text: "electronik", expected: []*users.User{electronic}},
+		{name: "Most similar first", text: "simple", options: users.FuzzyOptions{MinSimilarity: lo.ToPtr(0.25)}, expected: []*users.User{s1mple, simon}},
+		{name: "Strict", text: "s1mpel", options: users.FuzzyOptions{MaxDistance: lo.ToPtr(1), MinSimilarity: lo.ToPtr(0.9)}, expected: []*users.User{}},
+		{name: "No match", text: "nobody", expected: []*users.User{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := repository.SearchUsers(ctx, users.SearchQuery{Text: tt.text, Mode: users.SearchFuzzy, Fuzzy: tt.options})
+			require.NoError(t, err)
+			assertUserIDs(t, tt.expected, result.Users)
+			assert.Empty(t, result.NextPageToken)
+		})
+	}
+
+	t.Run("Filtered", func(t *testing.T) {
+		query := users.SearchQuery{Text: "simple", Mode: users.SearchFuzzy, Query: users.Query{Country: lo.ToPtr("DE")}}
+		query.Fuzzy.MinSimilarity = lo.ToPtr(0.25)
+
+		result, err := repository.SearchUsers(ctx, query)
+		require.NoError(t, err)
+		assertUserIDs(t, []*users.User{simon}, result.Users)
+	})
+
+	t.Run("Paginated", func(t *testing.T) {
+		query := users.SearchQuery{
+			Text:  "simple",
+			Mode:  users.SearchFuzzy,
+			Fuzzy: users.FuzzyOptions{MinSimilarity: lo.ToPtr(0.25)},
+			Query: users.Query{Limit: lo.ToPtr(int64(1)), IncludeTotalSize: true},
+		}
+
+		result, err := repository.SearchUsers(ctx, query)
+		require.NoError(t, err)
+		assertUserIDs(t, []*users.User{s1mple}, result.Users)
+		assert.EqualValues(t, 2, *result.TotalSize)
+
+		query.PageToken = lo.ToPtr(result.NextPageToken)
+		result, err = repository.SearchUsers(ctx, query)
+		require.NoError(t, err)
+		assertUserIDs(t, []*users.User{simon}, result.Users)
+		assert.Empty(t, result.NextPageToken)
+	})
+}
@@ -0,0 +1,57 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+)
+
+func testSnapshot(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	empty, err := repository.Snapshot(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, empty.Users)
+	assert.False(t, empty.Time.IsZero())
+
+	s1mple := newUser("s1mple", "UA")
+	require.NoError(t, repository.AddUser(ctx, s1mple))
+	zywoo := newUser("zywoo", "FR")
+	require.NoError(t, repository.AddUser(ctx, zywoo))
+
+	snapshot, err := repository.Snapshot(ctx)
+	require.NoError(t, err)
+	assertUserIDs(t, []*users.User{s1mple, zywoo}, snapshot.Users)
+
+	// Only the changes made after the snapshot are delivered
+	update := *s1mple
+	update.Nickname = "s1mple2"
+	_, err = repository.UpdateUser(ctx, update)
+	require.NoError(t, err)
+	require.NoError(t, repository.DeleteUser(ctx, zywoo.ID))
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, err := repository.Watch(watchCtx, snapshot.WatchQuery())
+	require.NoError(t, err)
+
+	updated := receiveEvent(t, events)
+	assert.Equal(t, users.ChangeUpdate, updated.ChangeType)
+	assert.Equal(t, s1mple.ID, updated.User.ID)
+
+	deleted := receiveEvent(t, events)
+	assert.Equal(t, users.ChangeDelete, deleted.ChangeType)
+	assert.Equal(t, zywoo.ID, deleted.User.ID)
+
+	// The snapshot of the empty repository continues with all the changes
+	events, err = repository.Watch(watchCtx, empty.WatchQuery())
+	require.NoError(t, err)
+
+	inserted := receiveEvent(t, events)
+	assert.Equal(t, users.ChangeInsert, inserted.ChangeType)
+	assert.Equal(t, s1mple.ID, inserted.User.ID)
+}
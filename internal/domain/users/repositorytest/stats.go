@@ -0,0 +1,85 @@
+package repositorytest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+)
+
+func testGetUserStats(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	t.Run("No users", func(t *testing.T) {
+		stats, err := repository.GetUserStats(ctx, users.StatsQuery{})
+		require.NoError(t, err)
+		assert.Zero(t, stats.Total)
+		assert.Empty(t, stats.Countries)
+		assert.Empty(t, stats.SignUps)
+	})
+
+	created := []*users.User{}
+	for i, country := range []string{"UA", "FR", "UA", "DE", "FR", "UA"} {
+		user := newUser(fmt.Sprintf("player%d", i), country)
+		require.NoError(t, repository.AddUser(ctx, user))
+
+		stored, err := repository.GetUser(ctx, user.ID)
+		require.NoError(t, err)
+		created = append(created, stored)
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, interval := range []users.StatsInterval{users.StatsDay, users.StatsWeek, users.StatsMonth} {
+		t.Run(string(interval), func(t *testing.T) {
+			stats, err := repository.GetUserStats(ctx, users.StatsQuery{Interval: interval})
+			require.NoError(t, err)
+
+			assert.EqualValues(t, 6, stats.Total)
+			assert.Equal(t, []users.CountryCount{
+				{Country: "UA", Count: 3},
+				{Country: "FR", Count: 2},
+				{Country: "DE", Count: 1},
+			}, stats.Countries)
+
+			// The users might have been created around midnight, so the buckets are computed from their creation times
+			expected := []users.SignUpBucket{}
+			for _, user := range created {
+				start := interval.Truncate(user.CreatedAt)
+				if len(expected) > 0 && expected[len(expected)-1].Start.Equal(start) {
+					expected[len(expected)-1].Count++
+					continue
+				}
+
+				expected = append(expected, users.SignUpBucket{Start: start, Count: 1})
+			}
+
+			require.Len(t, stats.SignUps, len(expected))
+			for i, bucket := range stats.SignUps {
+				assert.True(t, expected[i].Start.Equal(bucket.Start), "expected %s, got %s", expected[i].Start, bucket.Start)
+				assert.Equal(t, expected[i].Count, bucket.Count)
+			}
+		})
+	}
+
+	t.Run("Time range", func(t *testing.T) {
+		stats, err := repository.GetUserStats(ctx, users.StatsQuery{
+			CreatedAfter:  &created[2].CreatedAt,
+			CreatedBefore: &created[5].CreatedAt,
+		})
+		require.NoError(t, err)
+
+		assert.EqualValues(t, 3, stats.Total)
+		assert.Equal(t, []users.CountryCount{
+			{Country: "DE", Count: 1},
+			{Country: "FR", Count: 1},
+			{Country: "UA", Count: 1},
+		}, stats.Countries)
+		assert.EqualValues(t, 3, lo.SumBy(stats.SignUps, func(bucket users.SignUpBucket) int64 { return bucket.Count }))
+	})
+}
@@ -0,0 +1,95 @@
+// Package repositorytest contains a conformance test suite for the users.Repository implementations,
+// ensuring all the backends behave the same.
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+)
+
+// eventTimeout is the time to wait for a watch event to be delivered.
+const eventTimeout = 5 * time.Second
+
+// Factory creates an empty repository for a single test. The factory is responsible for cleaning up
+// any resources using t.Cleanup.
+type Factory func(t *testing.T) users.Repository
+
+// Run runs the conformance test suite against the repositories created by the factory.
+func Run(t *testing.T, newRepository Factory) {
+	t.Run("AddUser", func(t *testing.T) { testAddUser(t, open(t, newRepository)) })
+	t.Run("AddUserDuplicateEmail", func(t *testing.T) { testAddUserDuplicateEmail(t, open(t, newRepository)) })
+	t.Run("GetUserNotFound", func(t *testing.T) { testGetUserNotFound(t, open(t, newRepository)) })
+	t.Run("UpdateUser", func(t *testing.T) { testUpdateUser(t, open(t, newRepository)) })
+	t.Run("UpdateUserNotFound", func(t *testing.T) { testUpdateUserNotFound(t, open(t, newRepository)) })
+	t.Run("UpdateUserDuplicateEmail", func(t *testing.T) { testUpdateUserDuplicateEmail(t, open(t, newRepository)) })
+	t.Run("DeleteUser", func(t *testing.T) { testDeleteUser(t, open(t, newRepository)) })
+	t.Run("DeleteUserNotFound", func(t *testing.T) { testDeleteUserNotFound(t, open(t, newRepository)) })
+	t.Run("GetUsersFilters", func(t *testing.T) { testGetUsersFilters(t, open(t, newRepository)) })
+	t.Run("GetUsersPagination", func(t *testing.T) { testGetUsersPagination(t, open(t, newRepository)) })
+	t.Run("GetUsersTimeRanges", func(t *testing.T) { testGetUsersTimeRanges(t, open(t, newRepository)) })
+	t.Run("GetUsersOrdering", func(t *testing.T) { testGetUsersOrdering(t, open(t, newRepository)) })
+	t.Run("SearchUsersPrefix", func(t *testing.T) { testSearchUsersPrefix(t, open(t, newRepository)) })
+	t.Run("SearchUsersFullText", func(t *testing.T) { testSearchUsersFullText(t, open(t, newRepository)) })
+	t.Run("SearchUsersFuzzy", func(t *testing.T) { testSearchUsersFuzzy(t, open(t, newRepository)) })
+	t.Run("GetUserStats", func(t *testing.T) { testGetUserStats(t, open(t, newRepository)) })
+	t.Run("Watch", func(t *testing.T) { testWatch(t, open(t, newRepository)) })
+	t.Run("WatchFiltered", func(t *testing.T) { testWatchFiltered(t, open(t, newRepository)) })
+	t.Run("WatchResume", func(t *testing.T) { testWatchResume(t, open(t, newRepository)) })
+	t.Run("Snapshot", func(t *testing.T) { testSnapshot(t, open(t, newRepository)) })
+	t.Run("Close", func(t *testing.T) { testClose(t, newRepository(t)) })
+}
+
+// open creates the repository for a single test, closing it once the test is done.
+func open(t *testing.T, newRepository Factory) users.Repository {
+	repository := newRepository(t)
+	t.Cleanup(func() {
+		assert.NoError(t, repository.Close(context.Background()))
+	})
+
+	return repository
+}
+
+func newUser(nickname, country string) *users.User {
+	return &users.User{
+		FirstName: "First " + nickname,
+		LastName:  "Last " + nickname,
+		Nickname:  nickname,
+		Email:     nickname + "@faceit.com",
+		Country:   country,
+	}
+}
+
+func assertSameUser(t *testing.T, expected, actual users.User) {
+	t.Helper()
+
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.FirstName, actual.FirstName)
+	assert.Equal(t, expected.LastName, actual.LastName)
+	assert.Equal(t, expected.Nickname, actual.Nickname)
+	assert.Equal(t, expected.Email, actual.Email)
+	assert.Equal(t, expected.Country, actual.Country)
+}
+
+func assertUserIDs(t *testing.T, expected []*users.User, actual []users.User) {
+	t.Helper()
+
+	expectedIDs := make([]string, len(expected))
+	for i, user := range expected {
+		expectedIDs[i] = user.ID
+	}
+
+	assert.Equal(t, expectedIDs, userIDs(actual))
+}
+
+func userIDs(list []users.User) []string {
+	ids := make([]string, len(list))
+	for i, user := range list {
+		ids[i] = user.ID
+	}
+
+	return ids
+}
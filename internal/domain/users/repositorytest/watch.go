@@ -0,0 +1,261 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+)
+
+func testWatch(t *testing.T, repository users.Repository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := repository.Watch(ctx, users.WatchQuery{})
+	require.NoError(t, err)
+
+	user := newUser("s1mple", "UA")
+	require.NoError(t, repository.AddUser(ctx, user))
+
+	event := receiveEvent(t, events)
+	assert.Equal(t, "insert", event.ChangeType)
+	assertSameUser(t, *user, event.User)
+	require.NotNil(t, event.After)
+	assertSameUser(t, *user, *event.After)
+
+	// Times are stored with a millisecond precision, so the update changes the update time
+	time.Sleep(10 * time.Millisecond)
+
+	update := *user
+	update.Country = "DE"
+	_, err = repository.UpdateUser(ctx, update)
+	require.NoError(t, err)
+
+	event = receiveEvent(t, events)
+	assert.Equal(t, "update", event.ChangeType)
+	assert.Equal(t, user.ID, event.User.ID)
+	assert.Equal(t, []string{"country", "updated_at"}, event.ChangedFields)
+	assert.Subset(t, event.UpdatedFields, []string{"country", "updated_at"})
+	assert.Empty(t, event.RemovedFields)
+
+	// MongoDB only provides the snapshots with the change stream images enabled
+	if event.Before != nil {
+		assert.Equal(t, "UA", event.Before.Country)
+		assert.Equal(t, "DE", event.After.Country)
+	}
+
+	require.NoError(t, repository.DeleteUser(ctx, user.ID))
+
+	event = receiveEvent(t, events)
+	assert.Equal(t, "delete", event.ChangeType)
+	assert.Equal(t, user.ID, event.User.ID)
+	assert.Nil(t, event.After)
+	if event.Before != nil {
+		assertSameUser(t, update, *event.Before)
+	}
+
+	// The channel is closed once the context is done
+	cancel()
+	select {
+	case _, ok := <-events:
+		for ok {
+			_, ok = <-events
+		}
+	case <-time.After(eventTimeout):
+		t.Fatal("the events channel was not closed after the context was canceled")
+	}
+}
+
+func testWatchFiltered(t *testing.T, repository users.Repository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	zywoo := newUser("zywoo", "FR")
+	require.NoError(t, repository.AddUser(ctx, zywoo))
+
+	emailChanges, err := repository.Watch(ctx, users.WatchQuery{WatchFilter: users.WatchFilter{ChangeTypes: []string{users.ChangeUpdate}, ChangedFields: []string{users.FieldEmail}}})
+	require.NoError(t, err)
+
+	ukrainians, err := repository.Watch(ctx, users.WatchQuery{WatchFilter: users.WatchFilter{Countries: []string{"UA"}}})
+	require.NoError(t, err)
+
+	watchedUser, err := repository.Watch(ctx, users.WatchQuery{WatchFilter: users.WatchFilter{UserIDs: []string{zywoo.ID}}})
+	require.NoError(t, err)
+
+	s1mple := newUser("s1mple", "UA")
+	require.NoError(t, repository.AddUser(ctx, s1mple))
+
+	update := *s1mple
+	update.Nickname = "s1mple2"
+	_, err = repository.UpdateUser(ctx, update)
+	require.NoError(t, err)
+
+	update.Email = "s1mple2@faceit.com"
+	_, err = repository.UpdateUser(ctx, update)
+	require.NoError(t, err)
+
+	zywooUpdate := *zywoo
+	zywooUpdate.Email = "zywoo2@faceit.com"
+	_, err = repository.UpdateUser(ctx, zywooUpdate)
+	require.NoError(t, err)
+
+	require.NoError(t, repository.DeleteUser(ctx, s1mple.ID))
+
+	assertEvents := func(t *testing.T, events <-chan users.UserEvent, expected ...users.UserEvent) {
+		t.Helper()
+
+		for _, expectedEvent := range expected {
+			event := receiveEvent(t, events)
+			assert.Equal(t, expectedEvent.ChangeType, event.ChangeType)
+			assert.Equal(t, expectedEvent.User.ID, event.User.ID)
+		}
+
+		// Events are delivered in order, so any unexpected event would follow the expected ones
+		select {
+		case event := <-events:
+			t.Errorf("unexpected %s event of %s", event.ChangeType, event.User.ID)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	t.Run("Changed fields", func(t *testing.T) {
+		assertEvents(t, emailChanges,
+			users.UserEvent{ChangeType: users.ChangeUpdate, User: users.User{ID: s1mple.ID}},
+			users.UserEvent{ChangeType: users.ChangeUpdate, User: users.User{ID: zywoo.ID}},
+		)
+	})
+
+	t.Run("Countries", func(t *testing.T) {
+		assertEvents(t, ukrainians,
+			users.UserEvent{ChangeType: users.ChangeInsert, User: users.User{ID: s1mple.ID}},
+			users.UserEvent{ChangeType: users.ChangeUpdate, User: users.User{ID: s1mple.ID}},
+			users.UserEvent{ChangeType: users.ChangeUpdate, User: users.User{ID: s1mple.ID}},
+		)
+	})
+
+	t.Run("User IDs", func(t *testing.T) {
+		assertEvents(t, watchedUser, users.UserEvent{ChangeType: users.ChangeUpdate, User: users.User{ID: zywoo.ID}})
+	})
+}
+
+func testWatchResume(t *testing.T, repository users.Repository) {
+	ctx := context.Background()
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	events, err := repository.Watch(watchCtx, users.WatchQuery{})
+	require.NoError(t, err)
+
+	s1mple := newUser("s1mple", "UA")
+	require.NoError(t, repository.AddUser(ctx, s1mple))
+
+	inserted := receiveEvent(t, events)
+	require.NotEmpty(t, inserted.ResumeToken)
+	assert.False(t, inserted.Time.IsZero())
+	cancel()
+
+	// The changes made while disconnected are delivered after resuming
+	time.Sleep(10 * time.Millisecond)
+
+	update := *s1mple
+	update.Nickname = "s1mple2"
+	_, err = repository.UpdateUser(ctx, update)
+	require.NoError(t, err)
+
+	zywoo := newUser("zywoo", "FR")
+	require.NoError(t, repository.AddUser(ctx, zywoo))
+
+	assertResumed := func(t *testing.T, query users.WatchQuery, expected ...users.UserEvent) {
+		t.Helper()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		events, err := repository.Watch(ctx, query)
+		require.NoError(t, err)
+
+		for _, expectedEvent := range expected {
+			event := receiveEvent(t, events)
+			assert.Equal(t, expectedEvent.ChangeType, event.ChangeType)
+			assert.Equal(t, expectedEvent.User.ID, event.User.ID)
+		}
+	}
+
+	t.Run("Resume token", func(t *testing.T) {
+		assertResumed(t, users.WatchQuery{ResumeToken: inserted.ResumeToken},
+			users.UserEvent{ChangeType: users.ChangeUpdate, User: users.User{ID: s1mple.ID}},
+			users.UserEvent{ChangeType: users.ChangeInsert, User: users.User{ID: zywoo.ID}},
+		)
+	})
+
+	t.Run("Resume token with a filter", func(t *testing.T) {
+		assertResumed(t, users.WatchQuery{ResumeToken: inserted.ResumeToken, WatchFilter: users.WatchFilter{Countries: []string{"FR"}}},
+			users.UserEvent{ChangeType: users.ChangeInsert, User: users.User{ID: zywoo.ID}},
+		)
+	})
+
+	t.Run("Start time", func(t *testing.T) {
+		query := users.WatchQuery{StartAt: &inserted.Time, WatchFilter: users.WatchFilter{UserIDs: []string{s1mple.ID, zywoo.ID}}}
+		assertResumed(t, query,
+			users.UserEvent{ChangeType: users.ChangeInsert, User: users.User{ID: s1mple.ID}},
+			users.UserEvent{ChangeType: users.ChangeUpdate, User: users.User{ID: s1mple.ID}},
+			users.UserEvent{ChangeType: users.ChangeInsert, User: users.User{ID: zywoo.ID}},
+		)
+	})
+
+	t.Run("Invalid resume token", func(t *testing.T) {
+		_, err := repository.Watch(ctx, users.WatchQuery{ResumeToken: "not a token"})
+		assert.ErrorIs(t, err, users.ErrInvalidResumeToken)
+	})
+}
+
+func testClose(t *testing.T, repository users.Repository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := repository.Watch(ctx, users.WatchQuery{})
+	require.NoError(t, err)
+
+	require.NoError(t, repository.AddUser(ctx, newUser("s1mple", "UA")))
+	event := receiveEvent(t, events)
+
+	// The watchers resuming after a change are stopped as well
+	resumed, err := repository.Watch(ctx, users.WatchQuery{ResumeToken: event.ResumeToken})
+	require.NoError(t, err)
+
+	require.NoError(t, repository.Close(ctx))
+	assertClosed(t, events)
+	assertClosed(t, resumed)
+}
+
+func receiveEvent(t *testing.T, events <-chan users.UserEvent) users.UserEvent {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "the events channel was closed")
+		return event
+	case <-time.After(eventTimeout):
+		require.FailNow(t, "timed out waiting for a watch event")
+		return users.UserEvent{}
+	}
+}
+
+// assertClosed waits until the events channel is closed, discarding the remaining events.
+func assertClosed(t *testing.T, events <-chan users.UserEvent) {
+	t.Helper()
+
+	timeout := time.After(eventTimeout)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			require.FailNow(t, "timed out waiting for the events channel to be closed")
+		}
+	}
+}
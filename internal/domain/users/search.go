@@ -0,0 +1,135 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type SearchMode string
+
+const (
+	// SearchPrefix matches the users with the first name, last name, nickname or email starting with the text,
+	// ignoring the case. The users are ordered the same way as when listing them.
+	SearchPrefix SearchMode = "prefix"
+
+	// SearchFullText matches the users with any of the words of the text in their first name, last name, nickname or
+	// email, ignoring the case. The users are ordered by relevance, the best matches first.
+	SearchFullText SearchMode = "full_text"
+
+	// SearchFuzzy matches the users with a nickname similar to the text, tolerating typos. The users are ordered by
+	// the similarity, the most similar first.
+	SearchFuzzy SearchMode = "fuzzy"
+
+	// FieldRelevance orders the full-text search results. It cannot be used in an OrderBy.
+	FieldRelevance = "relevance"
+)
+
+var ErrInvalidSearch = errors.New("invalid search")
+
+// relevanceOrdering is the ordering of the full-text search results.
+var relevanceOrdering = Ordering{{Field: FieldRelevance, Descending: true}}
+
+// SearchQuery searches for the users matching the text. The users are additionally filtered and paginated by the Query.
+type SearchQuery struct {
+	Query
+
+	Text string     `json:"text"`
+	Mode SearchMode `json:"mode"`
+
+	// Fuzzy configures the SearchFuzzy mode.
+	Fuzzy FuzzyOptions `json:"fuzzy,omitempty"`
+}
+
+// Validate checks the text, ordering and pagination of the search.
+func (q SearchQuery) Validate() error {
+	switch q.Mode {
+	case SearchPrefix:
+		if strings.TrimSpace(q.Text) == "" {
+			return fmt.Errorf("%w: the text must not be empty", ErrInvalidSearch)
+		}
+	case SearchFullText:
+		if len(q.Terms()) == 0 {
+			return fmt.Errorf("%w: the text must contain at least one word", ErrInvalidSearch)
+		}
+
+		if q.OrderBy != nil && *q.OrderBy != "" {
+			return fmt.Errorf("%w: full-text search results are ordered by relevance", ErrInvalidSearch)
+		}
+	case SearchFuzzy:
+		if len(q.Terms()) == 0 {
+			return fmt.Errorf("%w: the text must contain at least one letter or digit", ErrInvalidSearch)
+		}
+
+		if q.OrderBy != nil && *q.OrderBy != "" {
+			return fmt.Errorf("%w: fuzzy search results are ordered by similarity", ErrInvalidSearch)
+		}
+
+		distance := q.Fuzzy.maxDistance()
+		if distance < 0 || distance > MaxDistanceLimit {
+			return fmt.Errorf("%w: the max distance must be between 0 and %d", ErrInvalidSearch, MaxDistanceLimit)
+		}
+
+		similarity := q.Fuzzy.minSimilarity()
+		if similarity <= 0 || similarity > 1 {
+			return fmt.Errorf("%w: the min similarity must be greater than 0 and at most 1", ErrInvalidSearch)
+		}
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidSearch, q.Mode)
+	}
+
+	err := q.validateQuery()
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Cursor()
+	return err
+}
+
+// Prefix returns the lowercase prefix the users are searched by.
+func (q SearchQuery) Prefix() string {
+	return strings.ToLower(strings.TrimSpace(q.Text))
+}
+
+// Terms returns the lowercase words of the text, splitting it on anything other than letters and digits.
+func (q SearchQuery) Terms() []string {
+	return SearchTerms(q.Text)
+}
+
+// Ordering returns the relevance ordering for full-text and fuzzy searches, otherwise the ordering of the Query.
+func (q SearchQuery) Ordering() (Ordering, error) {
+	if q.Mode == SearchFullText || q.Mode == SearchFuzzy {
+		return relevanceOrdering, nil
+	}
+
+	return q.Query.Ordering()
+}
+
+// Cursor returns the decoded page token, or nil if the search starts from the first page.
+func (q SearchQuery) Cursor() (*Cursor, error) {
+	ordering, err := q.Ordering()
+	if err != nil {
+		return nil, err
+	}
+
+	return q.cursor(ordering)
+}
+
+// NewRelevanceCursor creates the cursor pointing at the user with the id, found by a full-text search with the score.
+func NewRelevanceCursor(id string, score float64) Cursor {
+	return Cursor{OrderBy: relevanceOrdering.String(), Values: []any{score}, ID: id}
+}
+
+// SearchTerms splits the text to lowercase words, the same way the stored users are split for the full-text search.
+func SearchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ByRelevance checks if the ordering sorts the full-text search results by relevance.
+func (o Ordering) ByRelevance() bool {
+	return len(o) == 1 && o[0].Field == FieldRelevance
+}
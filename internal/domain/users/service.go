@@ -3,9 +3,9 @@ package users
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/samber/lo"
 	"go.uber.org/zap"
 )
 
@@ -16,27 +16,34 @@ type Service interface {
 	UpdateUser(ctx context.Context, user UpdateUser) (*User, error)
 	GetUser(ctx context.Context, id string) (*User, error)
 	DeleteUser(ctx context.Context, id string) error
-	GetUsers(ctx context.Context, query Query) ([]User, error)
-	Watch(ctx context.Context) (<-chan UserEvent, error)
+	GetUsers(ctx context.Context, query Query) (*UserPage, error)
+	SearchUsers(ctx context.Context, query SearchQuery) (*UserPage, error)
+	GetUserStats(ctx context.Context, query StatsQuery) (*UserStats, error)
+	Watch(ctx context.Context, query WatchQuery) (<-chan UserEvent, error)
+	SyncUsers(ctx context.Context) (*Snapshot, <-chan UserEvent, error)
 }
 
 var validate = validator.New()
 
 type userServiceImpl struct {
 	repository Repository
+	statsCache *statsCache
 	logger     *zap.Logger
 }
 
-func NewUserService(repository Repository) *userServiceImpl {
+// NewUserService creates the user service. The user statistics are cached for the statsCacheInterval,
+// or not at all if it is zero.
+func NewUserService(repository Repository, statsCacheInterval time.Duration) *userServiceImpl {
 	return &userServiceImpl{
 		repository: repository,
+		statsCache: newStatsCache(statsCacheInterval),
 		logger:     zap.L().Named("user-service"),
 	}
 }
 
 // AddUser adds a new user to the database.
 func (s *userServiceImpl) AddUser(ctx context.Context, user NewUser) (*User, error) {
-	s.logger.Info("Adding a new user", zap.Any("user", user))
+	s.callerLogger(ctx).Info("Adding a new user", zap.Any("user", user))
 
 	// Validate the user
 	err := validate.Struct(user)
@@ -55,11 +62,12 @@ func (s *userServiceImpl) AddUser(ctx context.Context, user NewUser) (*User, err
 
 // UpdateUser updates a user in the database.
 func (s *userServiceImpl) UpdateUser(ctx context.Context, user UpdateUser) (*User, error) {
-	s.logger.Info("Updating a user", zap.Any("user", user))
+	s.callerLogger(ctx).Info("Updating a user", zap.Any("user", user))
 
 	// todo check if password is updated - we don't want to overwrite the password with the empty string
 
 	repoUser := User{
+		ID:        user.Id,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
 		Nickname:  user.Nickname,
@@ -76,30 +84,61 @@ func (s *userServiceImpl) UpdateUser(ctx context.Context, user UpdateUser) (*Use
 
 // DeleteUser deletes a user from the database.
 func (s *userServiceImpl) DeleteUser(ctx context.Context, id string) error {
-	s.logger.Info("Deleting a user", zap.String("id", id))
+	s.callerLogger(ctx).Info("Deleting a user", zap.String("id", id))
 
 	return s.repository.DeleteUser(ctx, id)
 }
 
-// GetUsers returns a list of users from the database.
-func (s *userServiceImpl) GetUsers(ctx context.Context, query Query) ([]User, error) {
-	s.logger.Info("Getting users", zap.Any("query", query))
+// GetUsers returns a page of users from the database.
+func (s *userServiceImpl) GetUsers(ctx context.Context, query Query) (*UserPage, error) {
+	s.callerLogger(ctx).Info("Getting users", zap.Any("query", query))
 
-	repoUsers, err := s.repository.GetUsers(ctx, query.FirstName, query.LastName, query.Nickname, query.Country, query.Email, query.Limit, query.Offset)
+	err := query.Validate()
 	if err != nil {
-		return nil, err
+		return nil, errors.Join(ErrValidation, err)
 	}
 
-	resp := lo.Map(repoUsers, func(item User, index int) User {
-		return item
-	})
+	return s.repository.GetUsers(ctx, query)
+}
+
+// SearchUsers returns a page of users matching the search.
+func (s *userServiceImpl) SearchUsers(ctx context.Context, query SearchQuery) (*UserPage, error) {
+	s.callerLogger(ctx).Info("Searching users", zap.Any("query", query))
+
+	err := query.Validate()
+	if err != nil {
+		return nil, errors.Join(ErrValidation, err)
+	}
 
-	return resp, nil
+	return s.repository.SearchUsers(ctx, query)
+}
+
+// GetUserStats returns the statistics of the users, computing them only if they are not cached.
+func (s *userServiceImpl) GetUserStats(ctx context.Context, query StatsQuery) (*UserStats, error) {
+	s.callerLogger(ctx).Info("Getting user statistics", zap.Any("query", query))
+
+	err := query.Validate()
+	if err != nil {
+		return nil, errors.Join(ErrValidation, err)
+	}
+
+	stats := s.statsCache.get(query)
+	if stats != nil {
+		return stats, nil
+	}
+
+	stats, err = s.repository.GetUserStats(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.statsCache.set(query, stats)
+	return stats, nil
 }
 
 // GetUser returns a user from the database.
 func (s *userServiceImpl) GetUser(ctx context.Context, id string) (*User, error) {
-	s.logger.Info("Getting users", zap.String("id", id))
+	s.callerLogger(ctx).Info("Getting users", zap.String("id", id))
 
 	repoUser, err := s.repository.GetUser(ctx, id)
 	if err != nil {
@@ -109,8 +148,44 @@ func (s *userServiceImpl) GetUser(ctx context.Context, id string) (*User, error)
 	return repoUser, nil
 }
 
-func (s *userServiceImpl) Watch(ctx context.Context) (<-chan UserEvent, error) {
-	return s.repository.Watch(ctx)
+// Watch returns the changes of the users matching the query.
+func (s *userServiceImpl) Watch(ctx context.Context, query WatchQuery) (<-chan UserEvent, error) {
+	s.callerLogger(ctx).Info("Watching users", zap.Any("query", query))
+
+	err := query.Validate()
+	if err != nil {
+		return nil, errors.Join(ErrValidation, err)
+	}
+
+	return s.repository.Watch(ctx, query)
+}
+
+// SyncUsers returns a consistent snapshot of all the users, along with the changes made after the snapshot,
+// so the users can be mirrored without missing or repeating any change.
+func (s *userServiceImpl) SyncUsers(ctx context.Context) (*Snapshot, <-chan UserEvent, error) {
+	s.callerLogger(ctx).Info("Syncing users")
+
+	snapshot, err := s.repository.Snapshot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes, err := s.repository.Watch(ctx, snapshot.WatchQuery())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return snapshot, changes, nil
+}
+
+// callerLogger returns the logger of the call, identifying the caller if the call was authenticated.
+func (s *userServiceImpl) callerLogger(ctx context.Context) *zap.Logger {
+	caller, ok := CallerFromContext(ctx)
+	if !ok {
+		return s.logger
+	}
+
+	return s.logger.With(zap.String("caller", caller.Subject))
 }
 
 func toUser(user *NewUser) *User {
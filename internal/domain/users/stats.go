@@ -0,0 +1,152 @@
+package users
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StatsInterval is the length of the sign-up histogram buckets.
+type StatsInterval string
+
+const (
+	StatsDay   = StatsInterval("day")
+	StatsWeek  = StatsInterval("week")
+	StatsMonth = StatsInterval("month")
+)
+
+// Truncate returns the start of the bucket containing the time. The buckets start at midnight UTC, weeks on Mondays.
+func (i StatsInterval) Truncate(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	switch i {
+	case StatsWeek:
+		// Weekdays start on Sunday, weeks on Monday
+		daysSinceMonday := (int(t.UTC().Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+	case StatsMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// StatsQuery limits the statistics to the users created in a time range and sets the histogram buckets.
+type StatsQuery struct {
+	// CreatedAfter and CreatedBefore limit the statistics to the users created in the time range, the same as in Query.
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+
+	// Interval of the sign-up histogram buckets, defaulting to days.
+	Interval StatsInterval `json:"interval,omitempty"`
+}
+
+// Validate checks the time range and the interval of the query.
+func (q StatsQuery) Validate() error {
+	if q.CreatedAfter != nil && q.CreatedBefore != nil && !q.CreatedAfter.Before(*q.CreatedBefore) {
+		return fmt.Errorf("created after must be before the created before")
+	}
+
+	switch q.Interval {
+	case "", StatsDay, StatsWeek, StatsMonth:
+		return nil
+	default:
+		return fmt.Errorf("unknown interval %q", q.Interval)
+	}
+}
+
+// BucketInterval returns the interval of the histogram buckets, defaulting to StatsDay.
+func (q StatsQuery) BucketInterval() StatsInterval {
+	if q.Interval == "" {
+		return StatsDay
+	}
+
+	return q.Interval
+}
+
+// UserStats are the statistics of the users matching a StatsQuery.
+type UserStats struct {
+	// Total is the number of the users.
+	Total int64 `json:"total"`
+
+	// Countries are the numbers of the users in each country, the most common country first.
+	Countries []CountryCount `json:"countries"`
+
+	// SignUps is the histogram of the users' creation times, from the oldest bucket. Buckets without any users are left out.
+	SignUps []SignUpBucket `json:"sign_ups"`
+}
+
+type CountryCount struct {
+	Country string `json:"country"`
+	Count   int64  `json:"count"`
+}
+
+type SignUpBucket struct {
+	// Start of the bucket, see StatsInterval.Truncate.
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// statsCache keeps the computed statistics for an interval, as aggregating all the users is expensive.
+type statsCache struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	entries map[string]statsCacheEntry
+}
+
+type statsCacheEntry struct {
+	stats     *UserStats
+	expiresAt time.Time
+}
+
+func newStatsCache(interval time.Duration) *statsCache {
+	return &statsCache{
+		interval: interval,
+		entries:  map[string]statsCacheEntry{},
+	}
+}
+
+// key identifies the query. The times are formatted, as equal times can have different locations.
+func (c *statsCache) key(query StatsQuery) string {
+	format := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+
+	return format(query.CreatedAfter) + "|" + format(query.CreatedBefore) + "|" + string(query.BucketInterval())
+}
+
+// get returns the cached statistics for the query, or nil if they are not cached or have expired.
+func (c *statsCache) get(query StatsQuery) *UserStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[c.key(query)]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil
+	}
+
+	return entry.stats
+}
+
+// set caches the statistics for the query, removing the expired entries. Does nothing if caching is disabled.
+func (c *statsCache) set(query StatsQuery, stats *UserStats) {
+	if c.interval <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.entries[c.key(query)] = statsCacheEntry{stats: stats, expiresAt: now.Add(c.interval)}
+}
@@ -0,0 +1,47 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsInterval_Truncate(t *testing.T) {
+	// A Sunday evening, already Monday in UTC+2
+	sunday := time.Date(2024, 3, 3, 23, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), StatsDay.Truncate(sunday))
+	assert.Equal(t, time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), StatsWeek.Truncate(sunday))
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), StatsMonth.Truncate(sunday))
+	assert.Equal(t, StatsDay.Truncate(sunday), StatsDay.Truncate(sunday.In(time.FixedZone("UTC+2", 2*60*60))))
+
+	monday := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, monday, StatsWeek.Truncate(monday))
+}
+
+func TestStatsQuery_Validate(t *testing.T) {
+	now := time.Now()
+
+	assert.NoError(t, StatsQuery{}.Validate())
+	assert.NoError(t, StatsQuery{Interval: StatsMonth}.Validate())
+	assert.Error(t, StatsQuery{Interval: "year"}.Validate())
+	assert.Error(t, StatsQuery{CreatedAfter: &now, CreatedBefore: &now}.Validate())
+}
+
+func TestStatsCache(t *testing.T) {
+	stats := &UserStats{Total: 1}
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cache := newStatsCache(time.Minute)
+	cache.set(StatsQuery{CreatedAfter: &after}, stats)
+
+	// The same time in a different location and the default interval
+	sameAfter := after.In(time.FixedZone("UTC+2", 2*60*60))
+	assert.Same(t, stats, cache.get(StatsQuery{CreatedAfter: &sameAfter, Interval: StatsDay}))
+	assert.Nil(t, cache.get(StatsQuery{CreatedAfter: &after, Interval: StatsWeek}))
+	assert.Nil(t, cache.get(StatsQuery{}))
+
+	expired := newStatsCache(-time.Minute)
+	expired.set(StatsQuery{}, stats)
+	assert.Nil(t, expired.get(StatsQuery{}))
+}
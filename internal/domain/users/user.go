@@ -1,5 +1,7 @@
 package users
 
+import "time"
+
 // NewUser is the struct used to create a new user.
 type NewUser struct {
 	// FirstName of the user.
@@ -48,9 +50,9 @@ type UpdateUser struct {
 type User struct {
 	ID string `json:"id"`
 
-	CreatedAt string `json:"created_at"`
+	CreatedAt time.Time `json:"created_at"`
 
-	UpdatedAt string `json:"updated_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 
 	// FirstName of the user.
 	FirstName string `json:"first_name"`
@@ -71,9 +73,30 @@ type User struct {
 type UserEvent struct {
 	ChangeType string `json:"change_type"`
 	User       User   `json:"user"`
+
+	// ChangedFields are the WatchableFields changed by an update.
+	ChangedFields []string `json:"changed_fields,omitempty"`
+
+	// UpdatedFields and RemovedFields are all the fields set and removed by an update, as named in the database.
+	UpdatedFields []string `json:"updated_fields,omitempty"`
+	RemovedFields []string `json:"removed_fields,omitempty"`
+
+	// Before and After are the snapshots of the user before and after the change, when the repository provides them.
+	Before *User `json:"before,omitempty"`
+	After  *User `json:"after,omitempty"`
+
+	// Time the change was made at.
+	Time time.Time `json:"time"`
+
+	// ResumeToken is the opaque position of the change, used to continue watching after it.
+	ResumeToken string `json:"resume_token"`
+
+	// Err is only set on the last event of a failed watch, after which the events channel is closed. Such event
+	// carries no change.
+	Err error `json:"-"`
 }
 
-// Query is a filter for the GetUsers method. Provides limit and offset for pagination.
+// Query is a filter for the GetUsers method. Provides limit and either a page token or an offset for pagination.
 type Query struct {
 	FirstName *string `json:"first_name,omitempty"`
 	LastName  *string `json:"last_name,omitempty"`
@@ -81,5 +104,32 @@ type Query struct {
 	Country   *string `json:"country,omitempty"`
 	Email     *string `json:"email,omitempty"`
 	Limit     *int64  `json:"limit,omitempty"`
-	Offset    *int64  `json:"offset,omitempty"`
+
+	// Countries match the users from any of the countries, ExcludedCountries the users from none of them.
+	Countries         []string `json:"countries,omitempty"`
+	ExcludedCountries []string `json:"excluded_countries,omitempty"`
+
+	// Filter is the filter expression parsed by ParseFilter, the users must match it in addition to the other fields.
+	Filter *string `json:"filter,omitempty"`
+
+	// Offset is kept for the clients paginating by skipping users, PageToken should be preferred.
+	Offset *int64 `json:"offset,omitempty"`
+
+	// PageToken is the NextPageToken of the previous page.
+	PageToken *string `json:"page_token,omitempty"`
+
+	// CreatedAfter and CreatedBefore match the users created in the time range. The range includes its start,
+	// but not its end, so consecutive ranges do not overlap.
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+
+	// UpdatedAfter and UpdatedBefore match the users last updated in the time range, the same as the created range.
+	UpdatedAfter  *time.Time `json:"updated_after,omitempty"`
+	UpdatedBefore *time.Time `json:"updated_before,omitempty"`
+
+	// OrderBy is the order by expression parsed by ParseOrderBy, defaulting to the DefaultOrderBy.
+	OrderBy *string `json:"order_by,omitempty"`
+
+	// IncludeTotalSize requests the number of users matching the query on all the pages.
+	IncludeTotalSize bool `json:"include_total_size,omitempty"`
 }
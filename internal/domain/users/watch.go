@@ -0,0 +1,167 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+const (
+	ChangeInsert = "insert"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+var (
+	ErrInvalidResumeToken = errors.New("invalid resume token")
+
+	// ErrResumeTokenExpired is returned when the changes after the resume token or the start time are no longer kept.
+	ErrResumeTokenExpired = errors.New("resume token expired")
+)
+
+// WatchableFields are the fields of the users whose changes are reported in the UserEvent.ChangedFields.
+var WatchableFields = []string{"first_name", "last_name", FieldNickname, FieldEmail, FieldCountry, FieldUpdatedAt}
+
+// WatchFilter limits the changes delivered to a watcher. Empty fields do not limit the changes.
+type WatchFilter struct {
+	// ChangeTypes are the types of the changes to watch - insert, update or delete.
+	ChangeTypes []string `json:"change_types,omitempty"`
+
+	// UserIDs are the users to watch.
+	UserIDs []string `json:"user_ids,omitempty"`
+
+	// Countries are the countries of the users to watch. Deleted users only carry their ID, so deletes
+	// never match the countries.
+	Countries []string `json:"countries,omitempty"`
+
+	// ChangedFields limit the updates to the ones changing any of the fields. Inserts and deletes are not limited.
+	ChangedFields []string `json:"changed_fields,omitempty"`
+}
+
+// WatchQuery is the filter of the watched changes, along with the position to start watching from. By default,
+// only the changes made after the watch starts are delivered.
+type WatchQuery struct {
+	WatchFilter
+
+	// ResumeToken is the UserEvent.ResumeToken of the last change received, continuing with the changes after it.
+	ResumeToken string `json:"resume_token,omitempty"`
+
+	// StartAt continues with the changes made at or after the time.
+	StartAt *time.Time `json:"start_at,omitempty"`
+}
+
+// Snapshot is a consistent view of all the users, along with the position of the changes made after it.
+type Snapshot struct {
+	Users []User `json:"users"`
+
+	// ResumeToken continues watching with the first change made after the snapshot. When there is no change to
+	// resume after, it is empty and the changes are watched from the Time instead.
+	ResumeToken string `json:"resume_token,omitempty"`
+
+	// Time the snapshot was read at.
+	Time time.Time `json:"time"`
+}
+
+// WatchQuery returns the query watching the changes made after the snapshot.
+func (s Snapshot) WatchQuery() WatchQuery {
+	if s.ResumeToken == "" {
+		return WatchQuery{StartAt: &s.Time}
+	}
+
+	return WatchQuery{ResumeToken: s.ResumeToken}
+}
+
+// Validate checks the filter and the starting position of the query.
+func (q WatchQuery) Validate() error {
+	if q.ResumeToken != "" && q.StartAt != nil {
+		return fmt.Errorf("resume token cannot be combined with a start time")
+	}
+
+	return q.WatchFilter.Validate()
+}
+
+// Validate checks the change types and changed fields of the filter.
+func (f WatchFilter) Validate() error {
+	for _, changeType := range f.ChangeTypes {
+		if changeType != ChangeInsert && changeType != ChangeUpdate && changeType != ChangeDelete {
+			return fmt.Errorf("unknown change type %q", changeType)
+		}
+	}
+
+	for _, field := range f.ChangedFields {
+		if !slices.Contains(WatchableFields, field) {
+			return fmt.Errorf("cannot watch changes of %q", field)
+		}
+	}
+
+	return nil
+}
+
+// Matches checks if the event should be delivered to the watcher.
+func (f WatchFilter) Matches(event UserEvent) bool {
+	if len(f.ChangeTypes) > 0 && !slices.Contains(f.ChangeTypes, event.ChangeType) {
+		return false
+	}
+
+	if len(f.UserIDs) > 0 && !slices.Contains(f.UserIDs, event.User.ID) {
+		return false
+	}
+
+	if len(f.Countries) > 0 && (event.ChangeType == ChangeDelete || !slices.Contains(f.Countries, event.User.Country)) {
+		return false
+	}
+
+	if len(f.ChangedFields) > 0 && event.ChangeType == ChangeUpdate {
+		return lo.Some(event.ChangedFields, f.ChangedFields)
+	}
+
+	return true
+}
+
+// InsertEvent returns the event of inserting the user, for the repositories publishing their own changes.
+func InsertEvent(user User) UserEvent {
+	return UserEvent{ChangeType: ChangeInsert, User: user, After: &user}
+}
+
+// UpdateEvent returns the event of updating the user, for the repositories publishing their own changes. The
+// users have no optional fields, so no field is ever removed.
+func UpdateEvent(before, after User) UserEvent {
+	changed := ChangedFields(before, after)
+	return UserEvent{
+		ChangeType:    ChangeUpdate,
+		User:          after,
+		ChangedFields: changed,
+		UpdatedFields: changed,
+		Before:        &before,
+		After:         &after,
+	}
+}
+
+// DeleteEvent returns the event of deleting the user, for the repositories publishing their own changes. The same
+// as for the other repositories, the deleted user only carries the ID.
+func DeleteEvent(before User) UserEvent {
+	return UserEvent{ChangeType: ChangeDelete, User: User{ID: before.ID}, Before: &before}
+}
+
+// ChangedFields returns the WatchableFields with different values in the users.
+func ChangedFields(before, after User) []string {
+	changed := []string{}
+	for _, field := range WatchableFields {
+		if field == FieldUpdatedAt {
+			if !before.UpdatedAt.Equal(after.UpdatedAt) {
+				changed = append(changed, field)
+			}
+
+			continue
+		}
+
+		if fieldString(before, field) != fieldString(after, field) {
+			changed = append(changed, field)
+		}
+	}
+
+	return changed
+}
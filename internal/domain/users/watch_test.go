@@ -0,0 +1,67 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchFilter_Matches(t *testing.T) {
+	insert := UserEvent{ChangeType: ChangeInsert, User: User{ID: "1", Country: "UA"}}
+	update := UserEvent{ChangeType: ChangeUpdate, User: User{ID: "1", Country: "UA"}, ChangedFields: []string{FieldEmail, FieldUpdatedAt}}
+	deleted := UserEvent{ChangeType: ChangeDelete, User: User{ID: "1"}}
+
+	tests := []struct {
+		name     string
+		filter   WatchFilter
+		expected []bool
+	}{
+		{name: "No filter", filter: WatchFilter{}, expected: []bool{true, true, true}},
+		{name: "Change types", filter: WatchFilter{ChangeTypes: []string{ChangeInsert, ChangeDelete}}, expected: []bool{true, false, true}},
+		{name: "User IDs", filter: WatchFilter{UserIDs: []string{"2"}}, expected: []bool{false, false, false}},
+		{name: "Countries", filter: WatchFilter{Countries: []string{"UA"}}, expected: []bool{true, true, false}},
+		{name: "Changed fields", filter: WatchFilter{ChangedFields: []string{FieldEmail}}, expected: []bool{true, true, true}},
+		{name: "Other changed fields", filter: WatchFilter{ChangedFields: []string{FieldNickname}}, expected: []bool{true, false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, []bool{tt.filter.Matches(insert), tt.filter.Matches(update), tt.filter.Matches(deleted)})
+		})
+	}
+}
+
+func TestUpdateEvent(t *testing.T) {
+	before := User{ID: "1", Nickname: "s1mple", Country: "UA"}
+	after := User{ID: "1", Nickname: "s1mple", Country: "DE"}
+
+	event := UpdateEvent(before, after)
+	assert.Equal(t, ChangeUpdate, event.ChangeType)
+	assert.Equal(t, []string{FieldCountry}, event.ChangedFields)
+	assert.Equal(t, []string{FieldCountry}, event.UpdatedFields)
+	assert.Equal(t, before, *event.Before)
+	assert.Equal(t, after, *event.After)
+
+	deleted := DeleteEvent(after)
+	assert.Equal(t, User{ID: "1"}, deleted.User)
+	assert.Equal(t, after, *deleted.Before)
+	assert.Nil(t, deleted.After)
+}
+
+func TestWatchFilter_Validate(t *testing.T) {
+	assert.NoError(t, WatchFilter{ChangeTypes: []string{ChangeUpdate}, ChangedFields: []string{FieldEmail}}.Validate())
+	assert.Error(t, WatchFilter{ChangeTypes: []string{"replace"}}.Validate())
+	assert.Error(t, WatchFilter{ChangedFields: []string{"password"}}.Validate())
+}
+
+func TestChangedFields(t *testing.T) {
+	before := User{Nickname: "s1mple", Email: "s1mple@faceit.com", UpdatedAt: time.Now()}
+
+	after := before
+	after.Email = "s1mple2@faceit.com"
+	after.UpdatedAt = before.UpdatedAt.Add(time.Second)
+
+	assert.Equal(t, []string{FieldEmail, FieldUpdatedAt}, ChangedFields(before, after))
+	assert.Empty(t, ChangedFields(before, before))
+}
@@ -2,10 +2,14 @@ package entrypoint
 
 import (
 	"context"
+	"time"
 
 	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
 	grpc2 "github.com/xBlaz3kx/faceit-task/internal/grpc"
-	"github.com/xBlaz3kx/faceit-task/internal/mongo"
+	http2 "github.com/xBlaz3kx/faceit-task/internal/http"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/auth"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/cors"
 	"github.com/xBlaz3kx/faceit-task/internal/pkg/grpc"
 	"github.com/xBlaz3kx/faceit-task/internal/pkg/http"
 	"go.uber.org/zap"
@@ -15,38 +19,78 @@ type AppConfig struct {
 	// Server is the address the server will listen on
 	Server string `yaml:"server" json:"server" mapstructure:"server"`
 
-	// DatabaseCfg contains the connection URI for the database
-	DatabaseCfg mongo.Configuration `yaml:"database" json:"database" mapstructure:"database"`
+	// GrpcCfg configures the gRPC server
+	GrpcCfg grpc.Configuration `yaml:"grpc" json:"grpc" mapstructure:"grpc"`
+
+	// AuthCfg configures the authentication of the gRPC, gRPC-Web, Connect and HTTP calls
+	AuthCfg auth.Config `yaml:"auth" json:"auth" mapstructure:"auth"`
+
+	// CorsCfg configures the cross-origin requests of the browsers, shared by the HTTP, gRPC-Web and Connect APIs
+	CorsCfg cors.Config `yaml:"cors" json:"cors" mapstructure:"cors"`
+
+	// DatabaseCfg contains the repository type and the connection URI for the database
+	DatabaseCfg DatabaseConfig `yaml:"database" json:"database" mapstructure:"database"`
+
+	// StatsCfg configures the user statistics
+	StatsCfg StatsConfig `yaml:"stats" json:"stats" mapstructure:"stats"`
+
+	// WatchCfg configures the delivery of the user changes to the watchers. PostgreSQL listens for the changes
+	// separately for each watcher, so it is only used by the other repositories.
+	WatchCfg WatchConfig `yaml:"watch" json:"watch" mapstructure:"watch"`
 
 	// todo possible improvements:
 	// - add observability configuration (logs + tracing)
 }
 
+type StatsConfig struct {
+	// CacheInterval is how long the computed statistics are cached for, zero disables the caching
+	CacheInterval time.Duration `yaml:"cacheInterval" json:"cacheInterval" mapstructure:"cacheInterval" validate:"gte=0"`
+}
+
+type WatchConfig struct {
+	broadcast.Config `yaml:",inline" mapstructure:",squash"`
+
+	// HeartbeatInterval is how often the watchers receive a heartbeat, zero disables the heartbeats
+	HeartbeatInterval time.Duration `yaml:"heartbeatInterval" json:"heartbeatInterval" mapstructure:"heartbeatInterval" validate:"gte=0"`
+}
+
 func Run(ctx context.Context, cfg AppConfig) {
 	// Create a logger
 	logger := zap.L()
 	logger.Info("Starting the user service", zap.Any("configuration", cfg))
 
-	// Connect to the database
-	mongoHealthCheck := mongo.Connect(cfg.DatabaseCfg, logger)
-
 	// Create the repository
-	userRepository := mongo.NewUserRepository()
+	userRepository, healthChecks := newRepository(cfg.DatabaseCfg, cfg.WatchCfg.Config, logger)
 
 	// Create the user service
-	userService := users.NewUserService(userRepository)
+	userService := users.NewUserService(userRepository, cfg.StatsCfg.CacheInterval)
 
-	grpcServer := grpc.NewServer()
+	// Create the authenticator shared by the gRPC and HTTP servers
+	authenticator, err := auth.NewAuthenticator(cfg.AuthCfg)
+	if err != nil {
+		logger.Fatal("Failed to create the authenticator", zap.Error(err))
+	}
+
+	if authenticator == nil {
+		logger.Warn("The authentication is disabled, anyone can call the service")
+	}
+
+	grpcServer := grpc.NewServer(cfg.GrpcCfg, authenticator)
 
 	// Register handler
-	grpcUserHandler := grpc2.NewUserGrpcHandler(userService)
+	grpcUserHandler := grpc2.NewUserGrpcHandler(userService, cfg.WatchCfg.HeartbeatInterval)
 	grpc2.RegisterUserServer(grpcServer, grpcUserHandler)
 
-	grpcServer.Start(cfg.Server)
+	grpcServer.Start(cfg.Server, healthChecks...)
+	if cfg.GrpcCfg.Web.Address != "" {
+		grpcServer.StartWeb(cfg.GrpcCfg.Web.Address, cfg.CorsCfg)
+	}
 
 	// Create and start the HTTP server
-	httpServer := http.NewServer(":80", logger)
-	httpServer.Start(mongoHealthCheck)
+	httpServer := http.NewServer(":80", cfg.CorsCfg, authenticator, logger)
+	httpUserHandler := http2.NewUserHttpHandler(userService, cfg.WatchCfg.HeartbeatInterval)
+	httpUserHandler.RegisterRoutes(httpServer.Router)
+	httpServer.Start(healthChecks...)
 
 	// Wait for the interrupt signal
 	<-ctx.Done()
@@ -55,8 +99,17 @@ func Run(ctx context.Context, cfg AppConfig) {
 	grpcServer.Stop()
 
 	// Shutdown the HTTP server
-	err := httpServer.Shutdown()
+	err = httpServer.Shutdown()
 	if err != nil {
 		logger.Fatal("Failed to shutdown the HTTP server", zap.Error(err))
 	}
+
+	// Stop delivering the changes to the remaining watchers
+	closeCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = userRepository.Close(closeCtx)
+	if err != nil {
+		logger.Error("Failed to close the repository", zap.Error(err))
+	}
 }
@@ -0,0 +1,40 @@
+package entrypoint
+
+import (
+	"context"
+	"errors"
+
+	"github.com/xBlaz3kx/faceit-task/internal/mongo"
+	"go.uber.org/zap"
+)
+
+var ErrMigrationsNotSupported = errors.New("schema migrations are only managed for the mongo database, the SQL databases are migrated on startup")
+
+// MigrateUp upgrades all the stored users to the latest schema version.
+func MigrateUp(ctx context.Context, cfg AppConfig) error {
+	migrator, err := newMigrator(cfg.DatabaseCfg)
+	if err != nil {
+		return err
+	}
+
+	return migrator.Up(ctx)
+}
+
+// MigrationStatus returns the schema versions of the stored users and the applied migrations.
+func MigrationStatus(ctx context.Context, cfg AppConfig) (*mongo.MigrationStatus, error) {
+	migrator, err := newMigrator(cfg.DatabaseCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrator.Status(ctx)
+}
+
+func newMigrator(cfg DatabaseConfig) (*mongo.Migrator, error) {
+	if cfg.Type != RepositoryTypeMongo {
+		return nil, ErrMigrationsNotSupported
+	}
+
+	mongo.Connect(mongo.Configuration{URI: cfg.URI}, zap.L())
+	return mongo.NewMigrator(), nil
+}
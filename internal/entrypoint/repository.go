@@ -0,0 +1,84 @@
+package entrypoint
+
+import (
+	"context"
+	"time"
+
+	"github.com/tavsec/gin-healthcheck/checks"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/memory"
+	"github.com/xBlaz3kx/faceit-task/internal/mongo"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"github.com/xBlaz3kx/faceit-task/internal/postgres"
+	"github.com/xBlaz3kx/faceit-task/internal/sqlite"
+	"go.uber.org/zap"
+)
+
+type RepositoryType string
+
+const (
+	RepositoryTypeMongo    = RepositoryType("mongo")
+	RepositoryTypePostgres = RepositoryType("postgres")
+	RepositoryTypeSqlite   = RepositoryType("sqlite")
+	RepositoryTypeMemory   = RepositoryType("memory")
+)
+
+type DatabaseConfig struct {
+	// Type is the repository backend used to store the users
+	Type RepositoryType `yaml:"type" json:"type" mapstructure:"type" validate:"oneof=mongo postgres sqlite memory"`
+
+	// URI is the connection URI for the MongoDB or PostgreSQL database
+	URI string `yaml:"uri" json:"uri" mapstructure:"uri"`
+
+	// Path is the database file used by the SQLite backend
+	Path string `yaml:"path" json:"path" mapstructure:"path"`
+
+	// ChangeStreamImages adds the snapshots of the users before and after each change to the MongoDB changes
+	ChangeStreamImages bool `yaml:"changeStreamImages" json:"changeStreamImages" mapstructure:"changeStreamImages"`
+
+	// MaxConnections limits the connections opened to the PostgreSQL database, 0 does not limit them
+	MaxConnections int `yaml:"maxConnections" json:"maxConnections" mapstructure:"maxConnections" validate:"gte=0"`
+}
+
+// newRepository creates the user repository for the configured backend, along with the healthchecks for it.
+// The changes are delivered to the watchers following the watch configuration.
+func newRepository(cfg DatabaseConfig, watch broadcast.Config, logger *zap.Logger) (users.Repository, []checks.Check) {
+	switch cfg.Type {
+	case RepositoryTypePostgres:
+		db, postgresHealthCheck := postgres.Connect(postgres.Configuration{URI: cfg.URI, MaxConnections: cfg.MaxConnections}, logger)
+		return postgres.NewUserRepository(db, watch), []checks.Check{postgresHealthCheck}
+	case RepositoryTypeSqlite:
+		db, sqliteHealthCheck := sqlite.Connect(sqlite.Configuration{Path: cfg.Path}, logger)
+		return sqlite.NewUserRepository(db, watch), []checks.Check{sqliteHealthCheck}
+	case RepositoryTypeMemory:
+		logger.Warn("Using the in-memory repository, users will be lost on restart")
+		return memory.NewUserRepository(watch), nil
+	default:
+		// Connect to the database
+		mongoCfg := mongo.Configuration{URI: cfg.URI, ChangeStreamImages: cfg.ChangeStreamImages}
+		mongoHealthCheck := mongo.Connect(mongoCfg, logger)
+		checkSchemaVersions(logger)
+		return mongo.NewUserRepository(mongoCfg, watch), []checks.Check{mongoHealthCheck}
+	}
+}
+
+// checkSchemaVersions reports the users stored in the older schema versions. They are upgraded when read, but the
+// filters, the search and the ordering only match them once they are migrated, so the lists are incomplete until then.
+func checkSchemaVersions(logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	status, err := mongo.NewMigrator().Status(ctx)
+	if err != nil {
+		logger.Error("Unable to check the schema versions of the users", zap.Error(err))
+		return
+	}
+
+	outdated := status.Outdated()
+	if outdated > 0 {
+		logger.Error("Users in older schema versions are missing from the lists and searches, run `user migrate up`",
+			zap.Int64("documents", outdated),
+			zap.Int("latestVersion", status.LatestVersion),
+		)
+	}
+}
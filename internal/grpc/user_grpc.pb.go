@@ -11,7 +11,6 @@ import (
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
-	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 // This is a compile-time assertion to ensure that this generated file
@@ -33,10 +32,17 @@ type UserClient interface {
 	DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error)
 	// Request a list of users, with optional filters and pagination
 	GetUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error)
+	// Search for users by a prefix of their names, nickname or email, or using a full-text search
+	SearchUsers(ctx context.Context, in *SearchUsersRequest, opts ...grpc.CallOption) (*SearchUsersResponse, error)
+	// Get the number of users, grouped by country and by the time they signed up
+	GetUserStats(ctx context.Context, in *GetUserStatsRequest, opts ...grpc.CallOption) (*GetUserStatsResponse, error)
 	// Allowing external services to get changes to user entities
-	// This will emit changes for ALL entities.
-	// Possible improvement: Add a filter to only emit changes for a specific entity or action
-	Watch(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (User_WatchClient, error)
+	// The changes can be limited to some change types, users, countries or changed fields. Returns OUT_OF_RANGE
+	// when the changes after the resume token or the start time are no longer kept.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (User_WatchClient, error)
+	// Mirror the users - streams a consistent snapshot of all the users, followed by the snapshotEnd marker and the
+	// changes made after the snapshot, without missing or repeating any change.
+	SyncUsers(ctx context.Context, in *SyncUsersRequest, opts ...grpc.CallOption) (User_SyncUsersClient, error)
 }
 
 type userClient struct {
@@ -92,7 +98,25 @@ func (c *userClient) GetUsers(ctx context.Context, in *ListUsersRequest, opts ..
 	return out, nil
 }
 
-func (c *userClient) Watch(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (User_WatchClient, error) {
+func (c *userClient) SearchUsers(ctx context.Context, in *SearchUsersRequest, opts ...grpc.CallOption) (*SearchUsersResponse, error) {
+	out := new(SearchUsersResponse)
+	err := c.cc.Invoke(ctx, "/user.User/SearchUsers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) GetUserStats(ctx context.Context, in *GetUserStatsRequest, opts ...grpc.CallOption) (*GetUserStatsResponse, error) {
+	out := new(GetUserStatsResponse)
+	err := c.cc.Invoke(ctx, "/user.User/GetUserStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (User_WatchClient, error) {
 	stream, err := c.cc.NewStream(ctx, &User_ServiceDesc.Streams[0], "/user.User/Watch", opts...)
 	if err != nil {
 		return nil, err
@@ -124,6 +148,38 @@ func (x *userWatchClient) Recv() (*WatchStreamResponse, error) {
 	return m, nil
 }
 
+func (c *userClient) SyncUsers(ctx context.Context, in *SyncUsersRequest, opts ...grpc.CallOption) (User_SyncUsersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &User_ServiceDesc.Streams[1], "/user.User/SyncUsers", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &userSyncUsersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type User_SyncUsersClient interface {
+	Recv() (*SyncUsersResponse, error)
+	grpc.ClientStream
+}
+
+type userSyncUsersClient struct {
+	grpc.ClientStream
+}
+
+func (x *userSyncUsersClient) Recv() (*SyncUsersResponse, error) {
+	m := new(SyncUsersResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // UserServer is the server API for User service.
 // All implementations must embed UnimplementedUserServer
 // for forward compatibility
@@ -138,10 +194,17 @@ type UserServer interface {
 	DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error)
 	// Request a list of users, with optional filters and pagination
 	GetUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error)
+	// Search for users by a prefix of their names, nickname or email, or using a full-text search
+	SearchUsers(context.Context, *SearchUsersRequest) (*SearchUsersResponse, error)
+	// Get the number of users, grouped by country and by the time they signed up
+	GetUserStats(context.Context, *GetUserStatsRequest) (*GetUserStatsResponse, error)
 	// Allowing external services to get changes to user entities
-	// This will emit changes for ALL entities.
-	// Possible improvement: Add a filter to only emit changes for a specific entity or action
-	Watch(*emptypb.Empty, User_WatchServer) error
+	// The changes can be limited to some change types, users, countries or changed fields. Returns OUT_OF_RANGE
+	// when the changes after the resume token or the start time are no longer kept.
+	Watch(*WatchRequest, User_WatchServer) error
+	// Mirror the users - streams a consistent snapshot of all the users, followed by the snapshotEnd marker and the
+	// changes made after the snapshot, without missing or repeating any change.
+	SyncUsers(*SyncUsersRequest, User_SyncUsersServer) error
 	mustEmbedUnimplementedUserServer()
 }
 
@@ -164,9 +227,18 @@ func (UnimplementedUserServer) DeleteUser(context.Context, *DeleteUserRequest) (
 func (UnimplementedUserServer) GetUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUsers not implemented")
 }
-func (UnimplementedUserServer) Watch(*emptypb.Empty, User_WatchServer) error {
+func (UnimplementedUserServer) SearchUsers(context.Context, *SearchUsersRequest) (*SearchUsersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchUsers not implemented")
+}
+func (UnimplementedUserServer) GetUserStats(context.Context, *GetUserStatsRequest) (*GetUserStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserStats not implemented")
+}
+func (UnimplementedUserServer) Watch(*WatchRequest, User_WatchServer) error {
 	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
 }
+func (UnimplementedUserServer) SyncUsers(*SyncUsersRequest, User_SyncUsersServer) error {
+	return status.Errorf(codes.Unimplemented, "method SyncUsers not implemented")
+}
 func (UnimplementedUserServer) mustEmbedUnimplementedUserServer() {}
 
 // UnsafeUserServer may be embedded to opt out of forward compatibility for this service.
@@ -270,8 +342,44 @@ func _User_GetUsers_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _User_SearchUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).SearchUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/user.User/SearchUsers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).SearchUsers(ctx, req.(*SearchUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_GetUserStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).GetUserStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/user.User/GetUserStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).GetUserStats(ctx, req.(*GetUserStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _User_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(emptypb.Empty)
+	m := new(WatchRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
@@ -291,6 +399,27 @@ func (x *userWatchServer) Send(m *WatchStreamResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _User_SyncUsers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SyncUsersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UserServer).SyncUsers(m, &userSyncUsersServer{stream})
+}
+
+type User_SyncUsersServer interface {
+	Send(*SyncUsersResponse) error
+	grpc.ServerStream
+}
+
+type userSyncUsersServer struct {
+	grpc.ServerStream
+}
+
+func (x *userSyncUsersServer) Send(m *SyncUsersResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // User_ServiceDesc is the grpc.ServiceDesc for User service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -318,6 +447,14 @@ var User_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUsers",
 			Handler:    _User_GetUsers_Handler,
 		},
+		{
+			MethodName: "SearchUsers",
+			Handler:    _User_SearchUsers_Handler,
+		},
+		{
+			MethodName: "GetUserStats",
+			Handler:    _User_GetUserStats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -325,6 +462,11 @@ var User_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _User_Watch_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "SyncUsers",
+			Handler:       _User_SyncUsers_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "user.proto",
 }
@@ -3,26 +3,30 @@ package grpc
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/samber/lo"
 	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type UserGrpcHandler struct {
 	UnimplementedUserServer
-	userService users.Service
-	logger      *zap.Logger
+	userService       users.Service
+	heartbeatInterval time.Duration
+	logger            *zap.Logger
 }
 
-func NewUserGrpcHandler(userService users.Service) *UserGrpcHandler {
+// NewUserGrpcHandler creates the handler of the user service. The idle watchers receive a heartbeat every
+// heartbeatInterval, or never if it is zero.
+func NewUserGrpcHandler(userService users.Service, heartbeatInterval time.Duration) *UserGrpcHandler {
 	return &UserGrpcHandler{
-		userService: userService,
-		logger:      zap.L().Named("user-grpc-handler"),
+		userService:       userService,
+		heartbeatInterval: heartbeatInterval,
+		logger:            zap.L().Named("user-grpc-handler"),
 	}
 }
 
@@ -60,8 +64,8 @@ func (s *UserGrpcHandler) GetUser(ctx context.Context, request *GetUserRequest)
 		}, nil
 	case errors.Is(err, users.ErrUserNotFound):
 		return nil, status.Errorf(codes.NotFound, "user with id %s not found", request.GetId())
-	case errors.Is(err, primitive.ErrInvalidHex):
-		return nil, status.Errorf(codes.InvalidArgument, "the provided id is not a valid hex string")
+	case errors.Is(err, users.ErrInvalidID):
+		return nil, status.Errorf(codes.InvalidArgument, "the provided id %s is not a valid user id", request.GetId())
 	default:
 		return nil, status.Error(codes.Internal, "unknown error occurred while getting the user")
 	}
@@ -86,8 +90,8 @@ func (s *UserGrpcHandler) UpdateUser(ctx context.Context, request *UpdateUserReq
 		}, nil
 	case errors.Is(err, users.ErrUserNotFound):
 		return nil, status.Errorf(codes.NotFound, "user with id %s not found", request.GetId())
-	case errors.Is(err, primitive.ErrInvalidHex):
-		return nil, status.Errorf(codes.InvalidArgument, "the provided id is not a valid hex string")
+	case errors.Is(err, users.ErrInvalidID):
+		return nil, status.Errorf(codes.InvalidArgument, "the provided id %s is not a valid user id", request.GetId())
 	default:
 		return nil, status.Error(codes.Internal, "unknown error occurred while updating the user")
 	}
@@ -100,8 +104,8 @@ func (s *UserGrpcHandler) DeleteUser(ctx context.Context, request *DeleteUserReq
 		return &DeleteUserResponse{
 			Status: DeleteStatus_OK,
 		}, nil
-	case errors.Is(err, primitive.ErrInvalidHex):
-		return nil, status.Errorf(codes.InvalidArgument, "the provided id is not a valid hex string")
+	case errors.Is(err, users.ErrInvalidID):
+		return nil, status.Errorf(codes.InvalidArgument, "the provided id %s is not a valid user id", request.GetId())
 	case errors.Is(err, users.ErrUserNotFound):
 		return nil, status.Errorf(codes.NotFound, "user with id %s not found", request.GetId())
 	default:
@@ -110,20 +114,117 @@ func (s *UserGrpcHandler) DeleteUser(ctx context.Context, request *DeleteUserReq
 }
 
 func (s *UserGrpcHandler) GetUsers(ctx context.Context, request *ListUsersRequest) (*ListUsersResponse, error) {
-	getUsers, err := s.userService.GetUsers(ctx, toQuery(request))
-	if err != nil {
+	page, err := s.userService.GetUsers(ctx, toQuery(request))
+	switch {
+	case err == nil:
+	case errors.Is(err, users.ErrValidation):
+		return nil, status.Errorf(codes.InvalidArgument, "invalid query: %v", err.Error())
+	default:
 		return nil, status.Error(codes.Internal, "unknown error occurred while getting the users")
 	}
 
-	userList := lo.Map(getUsers, func(item users.User, index int) *UserModel {
+	userList := lo.Map(page.Users, func(item users.User, index int) *UserModel {
 		return toGrpcUser(&item)
 	})
 
 	return &ListUsersResponse{
-		Users: userList,
+		Users:         userList,
+		NextPageToken: page.NextPageToken,
+		TotalSize:     page.TotalSize,
 	}, nil
 }
 
+func (s *UserGrpcHandler) SearchUsers(ctx context.Context, request *SearchUsersRequest) (*SearchUsersResponse, error) {
+	query := users.SearchQuery{
+		Query: users.Query{
+			Limit:     request.Limit,
+			PageToken: request.PageToken,
+			OrderBy:   request.OrderBy,
+
+			IncludeTotalSize: request.GetIncludeTotalSize(),
+		},
+		Text: request.GetQuery(),
+		Mode: toSearchMode(request.GetMode()),
+		Fuzzy: users.FuzzyOptions{
+			MinSimilarity: request.MinSimilarity,
+		},
+	}
+
+	if request.MaxDistance != nil {
+		query.Fuzzy.MaxDistance = lo.ToPtr(int(request.GetMaxDistance()))
+	}
+
+	page, err := s.userService.SearchUsers(ctx, query)
+	switch {
+	case err == nil:
+	case errors.Is(err, users.ErrValidation):
+		return nil, status.Errorf(codes.InvalidArgument, "invalid search: %v", err.Error())
+	default:
+		return nil, status.Error(codes.Internal, "unknown error occurred while searching the users")
+	}
+
+	userList := lo.Map(page.Users, func(item users.User, index int) *UserModel {
+		return toGrpcUser(&item)
+	})
+
+	return &SearchUsersResponse{
+		Users:         userList,
+		NextPageToken: page.NextPageToken,
+		TotalSize:     page.TotalSize,
+	}, nil
+}
+
+func (s *UserGrpcHandler) GetUserStats(ctx context.Context, request *GetUserStatsRequest) (*GetUserStatsResponse, error) {
+	query := users.StatsQuery{
+		CreatedAfter:  toTime(request.GetCreatedAfter()),
+		CreatedBefore: toTime(request.GetCreatedBefore()),
+		Interval:      toStatsInterval(request.GetInterval()),
+	}
+
+	stats, err := s.userService.GetUserStats(ctx, query)
+	switch {
+	case err == nil:
+	case errors.Is(err, users.ErrValidation):
+		return nil, status.Errorf(codes.InvalidArgument, "invalid query: %v", err.Error())
+	default:
+		return nil, status.Error(codes.Internal, "unknown error occurred while getting the user statistics")
+	}
+
+	return &GetUserStatsResponse{
+		Total: stats.Total,
+		Countries: lo.Map(stats.Countries, func(item users.CountryCount, index int) *CountryCount {
+			return &CountryCount{Country: item.Country, Count: item.Count}
+		}),
+		SignUps: lo.Map(stats.SignUps, func(item users.SignUpBucket, index int) *SignUpBucket {
+			return &SignUpBucket{Start: timestamppb.New(item.Start), Count: item.Count}
+		}),
+	}, nil
+}
+
+func toStatsInterval(interval StatsInterval) users.StatsInterval {
+	switch interval {
+	case StatsInterval_WEEK:
+		return users.StatsWeek
+	case StatsInterval_MONTH:
+		return users.StatsMonth
+	default:
+		return users.StatsDay
+	}
+}
+
+func toSearchMode(mode SearchMode) users.SearchMode {
+	switch mode {
+	case SearchMode_PREFIX:
+		return users.SearchPrefix
+	case SearchMode_FULL_TEXT:
+		return users.SearchFullText
+	case SearchMode_FUZZY:
+		return users.SearchFuzzy
+	default:
+		return users.SearchMode(mode.String())
+	}
+}
+
 func toQuery(request *ListUsersRequest) users.Query {
 	return users.Query{
 		FirstName: request.FirstName,
@@ -133,34 +234,140 @@ func toQuery(request *ListUsersRequest) users.Query {
 		Email:     request.Email,
 		Limit:     request.Limit,
 		Offset:    request.Page,
+		PageToken: request.PageToken,
+		OrderBy:   request.OrderBy,
+		Filter:    request.Filter,
+
+		Countries:         request.GetCountries(),
+		ExcludedCountries: request.GetExcludedCountries(),
+
+		CreatedAfter:  toTime(request.GetCreatedAfter()),
+		CreatedBefore: toTime(request.GetCreatedBefore()),
+		UpdatedAfter:  toTime(request.GetUpdatedAfter()),
+		UpdatedBefore: toTime(request.GetUpdatedBefore()),
+
+		IncludeTotalSize: request.GetIncludeTotalSize(),
 	}
 }
 
-func (s *UserGrpcHandler) Watch(_ *emptypb.Empty, server User_WatchServer) error {
+// toTime converts the optional timestamp to a time, returning nil if the timestamp is not set.
+func toTime(timestamp *timestamppb.Timestamp) *time.Time {
+	if timestamp == nil {
+		return nil
+	}
 
-	changeStream, err := s.userService.Watch(server.Context())
-	if err != nil {
+	return lo.ToPtr(timestamp.AsTime())
+}
+
+func (s *UserGrpcHandler) Watch(request *WatchRequest, server User_WatchServer) error {
+	query := users.WatchQuery{
+		WatchFilter: users.WatchFilter{
+			ChangeTypes: lo.Map(request.GetChangeTypes(), func(item ChangeType, index int) string {
+				return fromChangeType(item)
+			}),
+			UserIDs:       request.GetUserIds(),
+			Countries:     request.GetCountries(),
+			ChangedFields: request.GetChangedFields(),
+		},
+		ResumeToken: request.GetResumeToken(),
+		StartAt:     toTime(request.GetStartAt()),
+	}
+
+	changeStream, err := s.userService.Watch(server.Context(), query)
+	switch {
+	case err == nil:
+	case errors.Is(err, users.ErrValidation):
+		return status.Errorf(codes.InvalidArgument, "invalid filter: %v", err.Error())
+	case errors.Is(err, users.ErrInvalidResumeToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, users.ErrResumeTokenExpired):
+		return status.Errorf(codes.OutOfRange, "the changes to resume from are no longer available, watch without the resume token or start time: %v", err.Error())
+	default:
 		s.logger.Error("Failed to watch the users", zap.Error(err))
 		return status.Error(codes.Internal, "unknown error occurred while watching the users")
 	}
 
+	return s.sendChanges(server.Context(), changeStream, request.GetResumeToken(), server.Send)
+}
+
+// SyncUsers sends the snapshot of the users, followed by the changes made after it.
+func (s *UserGrpcHandler) SyncUsers(_ *SyncUsersRequest, server User_SyncUsersServer) error {
+	snapshot, changeStream, err := s.userService.SyncUsers(server.Context())
+	switch {
+	case err == nil:
+	case errors.Is(err, users.ErrResumeTokenExpired):
+		return status.Errorf(codes.Unavailable, "the changes after the snapshot are no longer available, sync again: %v", err.Error())
+	default:
+		s.logger.Error("Failed to sync the users", zap.Error(err))
+		return status.Error(codes.Internal, "unknown error occurred while syncing the users")
+	}
+
+	for _, user := range snapshot.Users {
+		err := server.Send(&SyncUsersResponse{Message: &SyncUsersResponse_User{User: toGrpcUser(&user)}})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = server.Send(&SyncUsersResponse{Message: &SyncUsersResponse_SnapshotEnd{SnapshotEnd: &SnapshotEnd{
+		Count:       int64(len(snapshot.Users)),
+		ResumeToken: snapshot.ResumeToken,
+		Time:        timestamppb.New(snapshot.Time),
+	}}})
+	if err != nil {
+		return err
+	}
+
+	return s.sendChanges(server.Context(), changeStream, snapshot.ResumeToken, func(response *WatchStreamResponse) error {
+		return server.Send(&SyncUsersResponse{Message: &SyncUsersResponse_Change{Change: response}})
+	})
+}
+
+// sendChanges sends the changes, along with the heartbeats while there are no changes, until the change stream
+// fails or the client disconnects. The heartbeats carry the resumeToken until the first change is sent.
+func (s *UserGrpcHandler) sendChanges(ctx context.Context, changeStream <-chan users.UserEvent, resumeToken string, send func(*WatchStreamResponse) error) error {
+	// The heartbeats keep the proxies and load balancers from closing the stream while there are no changes
+	var heartbeats <-chan time.Time
+	if s.heartbeatInterval > 0 {
+		ticker := time.NewTicker(s.heartbeatInterval)
+		defer ticker.Stop()
+		heartbeats = ticker.C
+	}
+
 	for {
 		select {
 		case changeEvent, ok := <-changeStream:
 			if !ok {
-				s.logger.Error("Change stream closed")
-				return nil
+				// The watcher was disconnected for falling behind, or the change stream failed
+				s.logger.Warn("Change stream closed")
+				return status.Error(codes.Unavailable, "the change stream was closed, watch again with the last resume token")
+			}
+
+			if changeEvent.Err != nil {
+				return toWatchStatus(changeEvent.Err)
 			}
 
-			response := toStreamResponse(changeEvent)
-			err := server.Send(response)
+			err := send(toStreamResponse(changeEvent))
 			if err != nil {
 				return err
 			}
 
-		case <-server.Context().Done():
+			resumeToken = changeEvent.ResumeToken
+
+		case <-heartbeats:
+			// The heartbeat carries the position of the last change sent, so the client can resume after it
+			err := send(&WatchStreamResponse{
+				ChangeType:  ChangeType_HEARTBEAT,
+				ResumeToken: resumeToken,
+				Time:        timestamppb.Now(),
+			})
+			if err != nil {
+				return err
+			}
+
+		case <-ctx.Done():
 			// Check if the client has disconnected or the context has been canceled
-			err := server.Context().Err()
+			err := ctx.Err()
 			if errors.Is(err, context.Canceled) {
 				s.logger.Info("Client disconnected")
 			} else {
@@ -172,29 +379,70 @@ func (s *UserGrpcHandler) Watch(_ *emptypb.Empty, server User_WatchServer) error
 	}
 }
 
+// toWatchStatus converts the error the watch failed with to the status the stream ends with.
+func toWatchStatus(err error) error {
+	switch {
+	case errors.Is(err, users.ErrResumeTokenExpired):
+		return status.Errorf(codes.OutOfRange, "some changes are no longer available, watch again without the resume token: %v", err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Errorf(codes.Unavailable, "the change stream failed, watch again with the last resume token: %v", err.Error())
+	}
+}
+
 func (s *UserGrpcHandler) mustEmbedUnimplementedUserServer() {
 }
 
 func toStreamResponse(change users.UserEvent) *WatchStreamResponse {
-	return &WatchStreamResponse{
-		ChangeType: toChangeType(change.ChangeType),
-		User:       toGrpcUser(&change.User),
+	response := &WatchStreamResponse{
+		ChangeType:    toChangeType(change.ChangeType),
+		User:          toGrpcUser(&change.User),
+		ChangedFields: change.ChangedFields,
+		ResumeToken:   change.ResumeToken,
+		Time:          timestamppb.New(change.Time),
+		UserId:        change.User.ID,
+		UpdatedFields: change.UpdatedFields,
+		RemovedFields: change.RemovedFields,
+	}
+
+	if change.Before != nil {
+		response.Before = toGrpcUser(change.Before)
 	}
+
+	if change.After != nil {
+		response.After = toGrpcUser(change.After)
+	}
+
+	return response
 }
 
 func toChangeType(opType string) ChangeType {
 	switch opType {
-	case "insert":
+	case users.ChangeInsert:
 		return ChangeType_INSERT
-	case "update":
+	case users.ChangeUpdate:
 		return ChangeType_UPDATE
-	case "delete":
+	case users.ChangeDelete:
 		return ChangeType_DELETE
 	default:
 		return -1
 	}
 }
 
+func fromChangeType(changeType ChangeType) string {
+	switch changeType {
+	case ChangeType_INSERT:
+		return users.ChangeInsert
+	case ChangeType_UPDATE:
+		return users.ChangeUpdate
+	case ChangeType_DELETE:
+		return users.ChangeDelete
+	default:
+		return changeType.String()
+	}
+}
+
 func toGrpcUser(user *users.User) *UserModel {
 	return &UserModel{
 		Id:       user.ID,
@@ -203,5 +451,17 @@ func toGrpcUser(user *users.User) *UserModel {
 		Nickname: user.Nickname,
 		Email:    user.Email,
 		Country:  user.Country,
+
+		CreatedAt: toTimestamp(user.CreatedAt),
+		UpdatedAt: toTimestamp(user.UpdatedAt),
+	}
+}
+
+// toTimestamp converts the time to a timestamp, leaving it unset for the users without the time (e.g. deleted users).
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
 	}
+
+	return timestamppb.New(t)
 }
@@ -0,0 +1,51 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/memory"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+)
+
+// TestUserGrpcHandler_GetUsersWithoutLimit checks the requests of the clients older than the page tokens, which
+// page with the offset and leave the limit unset or zero.
+func TestUserGrpcHandler_GetUsersWithoutLimit(t *testing.T) {
+	ctx := context.Background()
+
+	repository := memory.NewUserRepository(broadcast.Config{BufferSize: 100})
+	t.Cleanup(func() {
+		_ = repository.Close(context.Background())
+	})
+
+	for i := 0; i < 35; i++ {
+		user := &users.User{Nickname: fmt.Sprintf("player%d", i), Email: fmt.Sprintf("player%d@faceit.com", i), Country: "DE"}
+		require.NoError(t, repository.AddUser(ctx, user))
+	}
+
+	handler := NewUserGrpcHandler(users.NewUserService(repository, 0), 0)
+
+	tests := []struct {
+		name     string
+		request  *ListUsersRequest
+		expected int
+	}{
+		{name: "No limit", request: &ListUsersRequest{}, expected: users.DefaultPageSize},
+		{name: "Zero limit", request: &ListUsersRequest{Limit: lo.ToPtr(int64(0))}, expected: users.DefaultPageSize},
+		{name: "Offset without a limit", request: &ListUsersRequest{Page: lo.ToPtr(int64(10))}, expected: 25},
+		{name: "Offset with a zero limit", request: &ListUsersRequest{Page: lo.ToPtr(int64(30)), Limit: lo.ToPtr(int64(0))}, expected: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := handler.GetUsers(ctx, tt.request)
+			require.NoError(t, err)
+			assert.Len(t, response.GetUsers(), tt.expected)
+		})
+	}
+}
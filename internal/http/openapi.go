@@ -0,0 +1,150 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+)
+
+// openAPIDocument describes the REST API. The schemas of the bodies and the parameters are generated from the
+// types the handlers bind and respond with, so the document follows their changes.
+func openAPIDocument() (*openapi3.T, error) {
+	schemas := openapi3.Schemas{}
+	for name, value := range map[string]any{
+		"User":      users.User{},
+		"NewUser":   users.NewUser{},
+		"UserPatch": userPatch{},
+		"UserPage":  users.UserPage{},
+		"UserEvent": users.UserEvent{},
+		"Error":     errorResponse{},
+	} {
+		schema, err := openapi3gen.NewSchemaRefForValue(value, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		schemas[name] = schema
+	}
+
+	listParameters, err := queryParameters(listParams{})
+	if err != nil {
+		return nil, err
+	}
+
+	watchParameters, err := queryParameters(watchParams{})
+	if err != nil {
+		return nil, err
+	}
+
+	lastEventID := openapi3.NewHeaderParameter("Last-Event-ID").
+		WithDescription("Resume token of the last received change, set by the browsers when reconnecting.").
+		WithSchema(openapi3.NewStringSchema())
+	watchParameters = append(watchParameters, &openapi3.ParameterRef{Value: lastEventID})
+
+	idParameter := &openapi3.ParameterRef{Value: openapi3.NewPathParameter("id").WithSchema(openapi3.NewStringSchema())}
+
+	users := &openapi3.PathItem{
+		Get: operation("Lists a page of the users matching the query parameters", listParameters, nil,
+			response(http.StatusOK, "The page of the users", "UserPage"),
+			response(http.StatusBadRequest, "Invalid query", "Error"),
+		),
+		Post: operation("Creates a user", nil, requestBody("NewUser"),
+			response(http.StatusCreated, "The created user", "User"),
+			response(http.StatusBadRequest, "Invalid user", "Error"),
+			response(http.StatusConflict, "A user with the email already exists", "Error"),
+		),
+	}
+
+	user := &openapi3.PathItem{
+		Parameters: openapi3.Parameters{idParameter},
+		Get: operation("Gets the user", nil, nil,
+			response(http.StatusOK, "The user", "User"),
+			response(http.StatusBadRequest, "Invalid ID", "Error"),
+			response(http.StatusNotFound, "The user does not exist", "Error"),
+		),
+		Patch: operation("Updates the fields set in the body, keeping the other fields", nil, requestBody("UserPatch"),
+			response(http.StatusOK, "The updated user", "User"),
+			response(http.StatusBadRequest, "Invalid ID or user", "Error"),
+			response(http.StatusNotFound, "The user does not exist", "Error"),
+		),
+		Delete: operation("Deletes the user", nil, nil,
+			openapi3.WithStatus(http.StatusNoContent, &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("The user was deleted")}),
+			response(http.StatusBadRequest, "Invalid ID", "Error"),
+			response(http.StatusNotFound, "The user does not exist", "Error"),
+		),
+	}
+
+	events := openapi3.NewResponse().
+		WithDescription("Server-Sent Events of the changes, or JSON messages when upgraded to a WebSocket").
+		WithContent(openapi3.Content{"text/event-stream": openapi3.NewMediaType().WithSchemaRef(schemaRef("UserEvent"))})
+	watch := &openapi3.PathItem{
+		Get: operation("Watches the changes of the users", watchParameters, nil,
+			openapi3.WithStatus(http.StatusOK, &openapi3.ResponseRef{Value: events}),
+			response(http.StatusBadRequest, "Invalid filter or resume token", "Error"),
+			response(http.StatusGone, "The changes to resume from are no longer kept", "Error"),
+		),
+	}
+
+	return &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "User service",
+			Version: "v1",
+		},
+		Paths: openapi3.NewPaths(
+			openapi3.WithPath("/v1/users", users),
+			openapi3.WithPath("/v1/users/watch", watch),
+			openapi3.WithPath("/v1/users/{id}", user),
+		),
+		Components: &openapi3.Components{Schemas: schemas},
+	}, nil
+}
+
+// queryParameters generates the query parameters of the struct's fields with a form tag.
+func queryParameters(params any) (openapi3.Parameters, error) {
+	parameters := openapi3.Parameters{}
+
+	paramsType := reflect.TypeOf(params)
+	for i := 0; i < paramsType.NumField(); i++ {
+		field := paramsType.Field(i)
+		name := field.Tag.Get("form")
+		if name == "" {
+			continue
+		}
+
+		schema, err := openapi3gen.NewSchemaRefForValue(reflect.Zero(field.Type).Interface(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		parameters = append(parameters, &openapi3.ParameterRef{Value: openapi3.NewQueryParameter(name).WithSchema(schema.Value)})
+	}
+
+	return parameters, nil
+}
+
+func operation(summary string, parameters openapi3.Parameters, body *openapi3.RequestBodyRef, responses ...openapi3.NewResponsesOption) *openapi3.Operation {
+	return &openapi3.Operation{
+		Summary:     summary,
+		Parameters:  parameters,
+		RequestBody: body,
+		Responses:   openapi3.NewResponses(responses...),
+	}
+}
+
+func requestBody(schema string) *openapi3.RequestBodyRef {
+	return &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchemaRef(schemaRef(schema))}
+}
+
+func response(status int, description, schema string) openapi3.NewResponsesOption {
+	return openapi3.WithStatus(status, &openapi3.ResponseRef{
+		Value: openapi3.NewResponse().WithDescription(description).WithJSONSchemaRef(schemaRef(schema)),
+	})
+}
+
+func schemaRef(name string) *openapi3.SchemaRef {
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, nil)
+}
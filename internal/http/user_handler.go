@@ -0,0 +1,237 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"go.uber.org/zap"
+)
+
+type UserHttpHandler struct {
+	userService       users.Service
+	heartbeatInterval time.Duration
+	upgrader          websocket.Upgrader
+	logger            *zap.Logger
+}
+
+// NewUserHttpHandler creates the HTTP handler of the user service. The idle watchers receive a heartbeat every
+// heartbeatInterval, or never if it is zero.
+func NewUserHttpHandler(userService users.Service, heartbeatInterval time.Duration) *UserHttpHandler {
+	return &UserHttpHandler{
+		userService:       userService,
+		heartbeatInterval: heartbeatInterval,
+		logger:            zap.L().Named("user-http-handler"),
+	}
+}
+
+// RegisterRoutes adds the routes of the user service to the router, along with the OpenAPI document describing them.
+func (h *UserHttpHandler) RegisterRoutes(router gin.IRouter) {
+	router.POST("/v1/users", h.CreateUser)
+	router.GET("/v1/users", h.GetUsers)
+	router.GET("/v1/users/watch", h.Watch)
+	router.GET("/v1/users/:id", h.GetUser)
+	router.PATCH("/v1/users/:id", h.UpdateUser)
+	router.DELETE("/v1/users/:id", h.DeleteUser)
+
+	document, err := openAPIDocument()
+	if err != nil {
+		h.logger.Panic("Cannot generate the OpenAPI document", zap.Error(err))
+	}
+
+	router.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, document)
+	})
+}
+
+// errorResponse is the body of all the error responses.
+type errorResponse struct {
+	// Code is the machine-readable kind of the error, e.g. not_found.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// userPatch is the body of the partial update, only the set fields are updated.
+type userPatch struct {
+	FirstName *string `json:"first_name,omitempty"`
+	LastName  *string `json:"last_name,omitempty"`
+	Nickname  *string `json:"nickname,omitempty"`
+	Email     *string `json:"email,omitempty"`
+	Password  *string `json:"password,omitempty"`
+	Country   *string `json:"country,omitempty"`
+}
+
+// listParams are the query parameters of the listed users, matching the fields of the gRPC ListUsersRequest.
+type listParams struct {
+	FirstName         *string   `form:"first_name"`
+	LastName          *string   `form:"last_name"`
+	Nickname          *string   `form:"nickname"`
+	Email             *string   `form:"email"`
+	Country           *string   `form:"country"`
+	Countries         []string  `form:"countries"`
+	ExcludedCountries []string  `form:"excluded_countries"`
+	Filter            *string   `form:"filter"`
+	Limit             *int64    `form:"limit"`
+	Offset            *int64    `form:"offset"`
+	PageToken         *string   `form:"page_token"`
+	OrderBy           *string   `form:"order_by"`
+	IncludeTotalSize  bool      `form:"include_total_size"`
+	CreatedAfter      time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore     time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedAfter      time.Time `form:"updated_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedBefore     time.Time `form:"updated_before" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+func (h *UserHttpHandler) CreateUser(c *gin.Context) {
+	newUser := users.NewUser{}
+	err := c.ShouldBindJSON(&newUser)
+	if err != nil {
+		h.fail(c, errors.Join(users.ErrValidation, err))
+		return
+	}
+
+	user, err := h.userService.AddUser(c.Request.Context(), newUser)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+func (h *UserHttpHandler) GetUser(c *gin.Context) {
+	user, err := h.userService.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUser updates the fields set in the patch, keeping the other fields of the user.
+func (h *UserHttpHandler) UpdateUser(c *gin.Context) {
+	patch := userPatch{}
+	err := c.ShouldBindJSON(&patch)
+	if err != nil {
+		h.fail(c, errors.Join(users.ErrValidation, err))
+		return
+	}
+
+	user, err := h.userService.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	update := users.UpdateUser{
+		Id:        user.ID,
+		FirstName: valueOr(patch.FirstName, user.FirstName),
+		LastName:  valueOr(patch.LastName, user.LastName),
+		Nickname:  valueOr(patch.Nickname, user.Nickname),
+		Email:     valueOr(patch.Email, user.Email),
+		Password:  valueOr(patch.Password, ""),
+		Country:   valueOr(patch.Country, user.Country),
+	}
+
+	updated, err := h.userService.UpdateUser(c.Request.Context(), update)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *UserHttpHandler) DeleteUser(c *gin.Context) {
+	err := h.userService.DeleteUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetUsers returns a page of the users matching the query parameters.
+func (h *UserHttpHandler) GetUsers(c *gin.Context) {
+	params := listParams{}
+	err := c.ShouldBindQuery(&params)
+	if err != nil {
+		h.fail(c, errors.Join(users.ErrValidation, err))
+		return
+	}
+
+	page, err := h.userService.GetUsers(c.Request.Context(), users.Query{
+		FirstName:         params.FirstName,
+		LastName:          params.LastName,
+		Nickname:          params.Nickname,
+		Email:             params.Email,
+		Country:           params.Country,
+		Countries:         params.Countries,
+		ExcludedCountries: params.ExcludedCountries,
+		Filter:            params.Filter,
+		Limit:             params.Limit,
+		Offset:            params.Offset,
+		PageToken:         params.PageToken,
+		OrderBy:           params.OrderBy,
+		IncludeTotalSize:  params.IncludeTotalSize,
+		CreatedAfter:      toTime(params.CreatedAfter),
+		CreatedBefore:     toTime(params.CreatedBefore),
+		UpdatedAfter:      toTime(params.UpdatedAfter),
+		UpdatedBefore:     toTime(params.UpdatedBefore),
+	})
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// fail responds with the status and the error body matching the error of the service.
+func (h *UserHttpHandler) fail(c *gin.Context, err error) {
+	status, response := toErrorResponse(err)
+	if status == http.StatusInternalServerError {
+		h.logger.Error("Request failed", zap.String("path", c.FullPath()), zap.Error(err))
+	}
+
+	c.JSON(status, response)
+}
+
+// toErrorResponse converts the error of the service to the status and the body of the response.
+func toErrorResponse(err error) (int, errorResponse) {
+	switch {
+	case errors.Is(err, users.ErrUserNotFound):
+		return http.StatusNotFound, errorResponse{Code: "not_found", Message: err.Error()}
+	case errors.Is(err, users.ErrUserAlreadyExists):
+		return http.StatusConflict, errorResponse{Code: "already_exists", Message: err.Error()}
+	case errors.Is(err, users.ErrValidation), errors.Is(err, users.ErrInvalidResumeToken):
+		return http.StatusBadRequest, errorResponse{Code: "invalid_argument", Message: err.Error()}
+	case errors.Is(err, users.ErrInvalidID):
+		return http.StatusBadRequest, errorResponse{Code: "invalid_argument", Message: "the provided id is not a valid user id"}
+	case errors.Is(err, users.ErrResumeTokenExpired):
+		return http.StatusGone, errorResponse{Code: "resume_token_expired", Message: "the changes to resume from are no longer available: " + err.Error()}
+	default:
+		return http.StatusInternalServerError, errorResponse{Code: "internal", Message: "unknown error occurred"}
+	}
+}
+
+func valueOr(value *string, fallback string) string {
+	if value == nil {
+		return fallback
+	}
+
+	return *value
+}
+
+func toTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
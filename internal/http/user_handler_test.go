@@ -0,0 +1,353 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/memory"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+)
+
+const eventTimeout = 5 * time.Second
+
+func newTestServer(t *testing.T) (*httptest.Server, users.Service) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	repository := memory.NewUserRepository(broadcast.Config{BufferSize: 100})
+	t.Cleanup(func() {
+		_ = repository.Close(context.Background())
+	})
+	service := users.NewUserService(repository, 0)
+
+	router := gin.New()
+	NewUserHttpHandler(service, time.Hour).RegisterRoutes(router)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return server, service
+}
+
+func addUser(t *testing.T, service users.Service, nickname, country string) *users.User {
+	t.Helper()
+
+	user, err := service.AddUser(context.Background(), users.NewUser{
+		FirstName: "First " + nickname,
+		LastName:  "Last " + nickname,
+		Nickname:  nickname,
+		Email:     nickname + "@faceit.com",
+		Password:  "password",
+		Country:   country,
+	})
+	require.NoError(t, err)
+
+	return user
+}
+
+// sseEvent is a parsed Server-Sent Event.
+type sseEvent struct {
+	event string
+	id    string
+	data  users.UserEvent
+}
+
+// readEvents parses the Server-Sent Events of the response.
+func readEvents(body *bufio.Reader) <-chan sseEvent {
+	events := make(chan sseEvent)
+	go func() {
+		defer close(events)
+
+		event := sseEvent{}
+		for {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			field, value, _ := strings.Cut(strings.TrimRight(line, "\n"), ":")
+			switch field {
+			case "event":
+				event.event = value
+			case "id":
+				event.id = value
+			case "data":
+				_ = json.Unmarshal([]byte(value), &event.data)
+			case "":
+				events <- event
+				event = sseEvent{}
+			}
+		}
+	}()
+
+	return events
+}
+
+func receive[T any](t *testing.T, events <-chan T) T {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "the stream was closed")
+		return event
+	case <-time.After(eventTimeout):
+		require.FailNow(t, "timed out waiting for an event")
+		var event T
+		return event
+	}
+}
+
+func watchEvents(t *testing.T, ctx context.Context, url string, lastEventID string) <-chan sseEvent {
+	t.Helper()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = response.Body.Close()
+	})
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	return readEvents(bufio.NewReader(response.Body))
+}
+
+func TestUserHttpHandler_WatchEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, service := newTestServer(t)
+	events := watchEvents(t, ctx, server.URL+"/v1/users/watch?countries=FR", "")
+
+	// Give the watcher time to subscribe
+	time.Sleep(50 * time.Millisecond)
+	s1mple := addUser(t, service, "s1mple", "UA")
+	zywoo := addUser(t, service, "zywoo", "FR")
+
+	event := receive(t, events)
+	assert.Equal(t, users.ChangeInsert, event.event)
+	assert.Equal(t, zywoo.ID, event.data.User.ID)
+	assert.Equal(t, event.data.ResumeToken, event.id)
+
+	t.Run("Last event id", func(t *testing.T) {
+		update := users.UpdateUser{Id: s1mple.ID, Nickname: "s1mple2", Email: s1mple.Email, Country: "FR"}
+		_, err := service.UpdateUser(ctx, update)
+		require.NoError(t, err)
+
+		// Reconnecting continues after the last received event
+		resumed := watchEvents(t, ctx, server.URL+"/v1/users/watch?countries=FR", event.id)
+
+		event := receive(t, resumed)
+		assert.Equal(t, users.ChangeUpdate, event.event)
+		assert.Equal(t, s1mple.ID, event.data.User.ID)
+	})
+
+	t.Run("Invalid filter", func(t *testing.T) {
+		response, err := http.Get(server.URL + "/v1/users/watch?change_types=upsert")
+		require.NoError(t, err)
+		defer response.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("Invalid last event id", func(t *testing.T) {
+		response, err := http.Get(server.URL + "/v1/users/watch?last_event_id=token")
+		require.NoError(t, err)
+		defer response.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
+
+func TestUserHttpHandler_WatchWebSocket(t *testing.T) {
+	server, service := newTestServer(t)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/users/watch"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?change_types=insert", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	messages := make(chan users.UserEvent)
+	go func() {
+		defer close(messages)
+		for {
+			event := users.UserEvent{}
+			if err := conn.ReadJSON(&event); err != nil {
+				return
+			}
+
+			messages <- event
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	s1mple := addUser(t, service, "s1mple", "UA")
+
+	inserted := receive(t, messages)
+	assert.Equal(t, users.ChangeInsert, inserted.ChangeType)
+	assert.Equal(t, s1mple.ID, inserted.User.ID)
+	assert.NotEmpty(t, inserted.ResumeToken)
+
+	t.Run("Last event id", func(t *testing.T) {
+		zywoo := addUser(t, service, "zywoo", "FR")
+
+		resumed, _, err := websocket.DefaultDialer.Dial(url+"?last_event_id="+inserted.ResumeToken, nil)
+		require.NoError(t, err)
+		defer resumed.Close()
+
+		_ = resumed.SetReadDeadline(time.Now().Add(eventTimeout))
+		event := users.UserEvent{}
+		require.NoError(t, resumed.ReadJSON(&event))
+		assert.Equal(t, zywoo.ID, event.User.ID)
+	})
+}
+
+// do sends the request with the JSON body, decoding the JSON response into the result.
+func do(t *testing.T, method, url string, body any, result any) int {
+	t.Helper()
+
+	encoded := []byte{}
+	if body != nil {
+		var err error
+		encoded, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+
+	request, err := http.NewRequest(method, url, bytes.NewReader(encoded))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	if result != nil && response.StatusCode != http.StatusNoContent {
+		require.NoError(t, json.NewDecoder(response.Body).Decode(result))
+	}
+
+	return response.StatusCode
+}
+
+func TestUserHttpHandler_Users(t *testing.T) {
+	server, _ := newTestServer(t)
+	url := server.URL + "/v1/users"
+
+	newUser := users.NewUser{
+		FirstName: "Oleksandr",
+		LastName:  "Kostyliev",
+		Nickname:  "s1mple",
+		Email:     "s1mple@faceit.com",
+		Password:  "password",
+		Country:   "UA",
+	}
+
+	created := users.User{}
+	require.Equal(t, http.StatusCreated, do(t, http.MethodPost, url, newUser, &created))
+	assert.NotEmpty(t, created.ID)
+
+	failure := errorResponse{}
+	assert.Equal(t, http.StatusConflict, do(t, http.MethodPost, url, newUser, &failure))
+	assert.Equal(t, "already_exists", failure.Code)
+
+	user := users.User{}
+	require.Equal(t, http.StatusOK, do(t, http.MethodGet, url+"/"+created.ID, nil, &user))
+	assert.Equal(t, "s1mple", user.Nickname)
+
+	// Only the fields set in the patch are updated
+	updated := users.User{}
+	require.Equal(t, http.StatusOK, do(t, http.MethodPatch, url+"/"+created.ID, map[string]string{"nickname": "s1mple2"}, &updated))
+	assert.Equal(t, "s1mple2", updated.Nickname)
+	assert.Equal(t, "Oleksandr", updated.FirstName)
+	assert.Equal(t, "UA", updated.Country)
+
+	zywoo := newUser
+	zywoo.Nickname = "zywoo"
+	zywoo.Email = "zywoo@faceit.com"
+	zywoo.Country = "FR"
+	require.Equal(t, http.StatusCreated, do(t, http.MethodPost, url, zywoo, nil))
+
+	page := users.UserPage{}
+	require.Equal(t, http.StatusOK, do(t, http.MethodGet, url+"?country=UA&include_total_size=true", nil, &page))
+	require.Len(t, page.Users, 1)
+	assert.Equal(t, created.ID, page.Users[0].ID)
+	require.NotNil(t, page.TotalSize)
+	assert.EqualValues(t, 1, *page.TotalSize)
+
+	require.Equal(t, http.StatusNoContent, do(t, http.MethodDelete, url+"/"+created.ID, nil, nil))
+
+	failure = errorResponse{}
+	assert.Equal(t, http.StatusNotFound, do(t, http.MethodGet, url+"/"+created.ID, nil, &failure))
+	assert.Equal(t, "not_found", failure.Code)
+
+	t.Run("Invalid requests", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			method string
+			url    string
+			body   any
+		}{
+			{name: "Invalid email", method: http.MethodPost, url: url, body: map[string]string{"email": "s1mple", "password": "password"}},
+			{name: "Invalid body", method: http.MethodPost, url: url, body: "user"},
+			{name: "Invalid filter", method: http.MethodGet, url: url + "?filter=country%20=="},
+			{name: "Invalid limit", method: http.MethodGet, url: url + "?limit=ten"},
+			{name: "Invalid time", method: http.MethodGet, url: url + "?created_after=yesterday"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				failure := errorResponse{}
+				assert.Equal(t, http.StatusBadRequest, do(t, tt.method, tt.url, tt.body, &failure))
+				assert.Equal(t, "invalid_argument", failure.Code)
+				assert.NotEmpty(t, failure.Message)
+			})
+		}
+	})
+}
+
+func TestUserHttpHandler_OpenAPI(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	response, err := http.Get(server.URL + "/openapi.json")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	document, err := openapi3.NewLoader().LoadFromData(mustReadAll(t, response))
+	require.NoError(t, err)
+	require.NoError(t, document.Validate(context.Background()))
+
+	list := document.Paths.Find("/v1/users").Get
+	require.NotNil(t, list)
+	assert.NotNil(t, list.Parameters.GetByInAndName(openapi3.ParameterInQuery, "country"))
+	assert.NotNil(t, document.Paths.Find("/v1/users/{id}").Patch)
+	assert.Contains(t, document.Components.Schemas, "User")
+}
+
+func mustReadAll(t *testing.T, response *http.Response) []byte {
+	t.Helper()
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	return body
+}
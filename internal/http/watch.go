@@ -0,0 +1,221 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/samber/lo"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"go.uber.org/zap"
+)
+
+const (
+	// eventHeartbeat and eventError are the types of the messages sent along with the changes.
+	eventHeartbeat = "heartbeat"
+	eventError     = "error"
+
+	// closeResumeTokenExpired is the WebSocket close code of the watchers whose changes are no longer kept.
+	closeResumeTokenExpired = 4410
+
+	// writeTimeout is how long a WebSocket message can take to be written before the watcher is disconnected.
+	writeTimeout = 10 * time.Second
+)
+
+// errWatchClosed is returned when the watcher was disconnected for falling behind.
+var errWatchClosed = errors.New("the change stream was closed, watch again with the last event id")
+
+// watchParams are the query parameters of the watch, matching the fields of the gRPC WatchRequest.
+type watchParams struct {
+	ChangeTypes   []string  `form:"change_types"`
+	UserIDs       []string  `form:"user_ids"`
+	Countries     []string  `form:"countries"`
+	ChangedFields []string  `form:"changed_fields"`
+	ResumeToken   string    `form:"resume_token"`
+	LastEventID   string    `form:"last_event_id"`
+	StartAt       time.Time `form:"start_at" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// heartbeat is sent while there are no changes, carrying the resume token of the last change sent.
+type heartbeat struct {
+	ChangeType  string    `json:"change_type"`
+	ResumeToken string    `json:"resume_token"`
+	Time        time.Time `json:"time"`
+}
+
+// message is a change, a heartbeat or an error sent to the watcher.
+type message struct {
+	event string
+	id    string
+	data  any
+}
+
+// Watch streams the changes of the users as Server-Sent Events, or over a WebSocket when upgrading the connection.
+// The id of each event is its resume token, so the EventSource reconnecting with the Last-Event-ID header continues
+// after the last received change. The WebSockets can pass the last_event_id query parameter instead.
+func (h *UserHttpHandler) Watch(c *gin.Context) {
+	params := watchParams{}
+	err := c.ShouldBindQuery(&params)
+	if err != nil {
+		h.fail(c, errors.Join(users.ErrValidation, err))
+		return
+	}
+
+	query := users.WatchQuery{
+		WatchFilter: users.WatchFilter{
+			ChangeTypes:   params.ChangeTypes,
+			UserIDs:       params.UserIDs,
+			Countries:     params.Countries,
+			ChangedFields: params.ChangedFields,
+		},
+		// The browsers set the Last-Event-ID header when reconnecting, taking precedence over the initial position
+		ResumeToken: lo.CoalesceOrEmpty(c.GetHeader("Last-Event-ID"), params.LastEventID, params.ResumeToken),
+	}
+
+	if !params.StartAt.IsZero() && query.ResumeToken == "" {
+		query.StartAt = &params.StartAt
+	}
+
+	// The changes are watched before upgrading the connection, so the invalid watches get a proper status
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	changes, err := h.userService.Watch(ctx, query)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		h.watchWebSocket(ctx, cancel, c, changes, query.ResumeToken)
+		return
+	}
+
+	h.watchEvents(ctx, c, changes, query.ResumeToken)
+}
+
+// watchEvents sends the changes as Server-Sent Events. Once the changes cannot be delivered, an error event is sent.
+func (h *UserHttpHandler) watchEvents(ctx context.Context, c *gin.Context, changes <-chan users.UserEvent, resumeToken string) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Disables the response buffering of the nginx proxies
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	send := func(msg message) error {
+		c.Render(-1, sse.Event{Event: msg.event, Id: msg.id, Data: msg.data})
+		c.Writer.Flush()
+		return c.Request.Context().Err()
+	}
+
+	err := h.stream(ctx, changes, resumeToken, send)
+	if err != nil && ctx.Err() == nil {
+		response := errorResponse{Code: "unavailable", Message: err.Error()}
+		if errors.Is(err, users.ErrResumeTokenExpired) {
+			_, response = toErrorResponse(err)
+		}
+
+		_ = send(message{event: eventError, data: response})
+	}
+}
+
+// watchWebSocket sends the changes as the JSON text messages of the WebSocket. Once the changes cannot be delivered,
+// the WebSocket is closed with closeResumeTokenExpired when the changes are no longer kept, otherwise as a server
+// error, so the watcher can reconnect with the last event id.
+func (h *UserHttpHandler) watchWebSocket(ctx context.Context, cancel context.CancelFunc, c *gin.Context, changes <-chan users.UserEvent, resumeToken string) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader already responded with an error
+		h.logger.Warn("Failed to upgrade the connection", zap.Error(err))
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// The messages of the watcher are discarded, but must be read to handle the pings and the close
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	err = h.stream(ctx, changes, resumeToken, func(msg message) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		return conn.WriteJSON(msg.data)
+	})
+
+	closeCode, reason := websocket.CloseNormalClosure, ""
+	switch {
+	case ctx.Err() != nil:
+		return
+	case errors.Is(err, users.ErrResumeTokenExpired):
+		closeCode, reason = closeResumeTokenExpired, "some changes are no longer available, watch again without the last event id"
+	case err != nil:
+		closeCode, reason = websocket.CloseInternalServerErr, err.Error()
+	}
+
+	// The close reason is limited to 123 bytes
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, reason), time.Now().Add(writeTimeout))
+}
+
+// stream sends the changes, along with the heartbeats while there are no changes, until the change stream fails or
+// the context is done. The heartbeats carry the resumeToken until the first change is sent.
+func (h *UserHttpHandler) stream(ctx context.Context, changes <-chan users.UserEvent, resumeToken string, send func(message) error) error {
+	// The heartbeats keep the proxies and load balancers from closing the stream while there are no changes
+	var heartbeats <-chan time.Time
+	if h.heartbeatInterval > 0 {
+		ticker := time.NewTicker(h.heartbeatInterval)
+		defer ticker.Stop()
+		heartbeats = ticker.C
+	}
+
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				// The watcher was disconnected for falling behind, or the change stream failed
+				h.logger.Warn("Change stream closed")
+				return errWatchClosed
+			}
+
+			if change.Err != nil {
+				return change.Err
+			}
+
+			err := send(message{event: change.ChangeType, id: change.ResumeToken, data: change})
+			if err != nil {
+				return err
+			}
+
+			resumeToken = change.ResumeToken
+
+		case <-heartbeats:
+			err := send(message{
+				event: eventHeartbeat,
+				id:    resumeToken,
+				data:  heartbeat{ChangeType: eventHeartbeat, ResumeToken: resumeToken, Time: time.Now().UTC()},
+			})
+			if err != nil {
+				return err
+			}
+
+		case <-ctx.Done():
+			h.logger.Info("Client disconnected")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"go.uber.org/zap"
+)
+
+const (
+	// historySize is the number of the last events kept for resuming the watchers.
+	historySize = 1000
+)
+
+type userRepository struct {
+	logger *zap.Logger
+
+	// mu guards the users map.
+	mu    sync.RWMutex
+	users map[string]*users.User
+
+	changes *users.EventLog
+}
+
+// NewUserRepository creates a thread-safe users.Repository that keeps all the users in memory. The changes are
+// delivered to the watchers as configured.
+func NewUserRepository(watch broadcast.Config) users.Repository {
+	return &userRepository{
+		logger:  zap.L().Named("user-repository"),
+		users:   map[string]*users.User{},
+		changes: users.NewEventLog(historySize, watch),
+	}
+}
+
+func (u *userRepository) AddUser(ctx context.Context, user *users.User) error {
+	u.logger.Info("Adding user to the repository")
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	// Check if a user with this email already exists
+	if u.emailTaken(user.Email, "") {
+		return users.ErrUserAlreadyExists
+	}
+
+	now := now()
+
+	stored := *user
+	stored.ID = uuid.Must(uuid.NewV7()).String()
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+
+	u.users[stored.ID] = &stored
+
+	user.ID = stored.ID
+
+	u.changes.Publish(users.InsertEvent(stored))
+	return nil
+}
+
+func (u *userRepository) UpdateUser(ctx context.Context, user users.User) (*users.User, error) {
+	u.logger.Info("Updating user in the repository")
+
+	if _, err := uuid.Parse(user.ID); err != nil {
+		return nil, users.ErrInvalidID
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	stored, ok := u.users[user.ID]
+	if !ok {
+		return nil, users.ErrUserNotFound
+	}
+
+	if u.emailTaken(user.Email, user.ID) {
+		return nil, users.ErrUserAlreadyExists
+	}
+
+	before := *stored
+	stored.FirstName = user.FirstName
+	stored.LastName = user.LastName
+	stored.Nickname = user.Nickname
+	stored.Email = user.Email
+	stored.Country = user.Country
+	stored.UpdatedAt = now()
+
+	res := *stored
+
+	u.changes.Publish(users.UpdateEvent(before, res))
+	return &res, nil
+}
+
+func (u *userRepository) DeleteUser(ctx context.Context, id string) error {
+	u.logger.Info("Deleting user from the repository", zap.String("id", id))
+
+	if _, err := uuid.Parse(id); err != nil {
+		return users.ErrInvalidID
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	stored, ok := u.users[id]
+	if !ok {
+		return users.ErrUserNotFound
+	}
+
+	delete(u.users, id)
+
+	u.changes.Publish(users.DeleteEvent(*stored))
+	return nil
+}
+
+func (u *userRepository) GetUser(ctx context.Context, id string) (*users.User, error) {
+	u.logger.Info("Getting a user from the repository", zap.String("id", id))
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, users.ErrInvalidID
+	}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	stored, ok := u.users[id]
+	if !ok {
+		return nil, users.ErrUserNotFound
+	}
+
+	res := *stored
+	return &res, nil
+}
+
+func (u *userRepository) GetUsers(ctx context.Context, query users.Query) (*users.UserPage, error) {
+	u.logger.Info("Getting users from the repository", zap.Any("query", query))
+
+	ordering, err := query.Ordering()
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := query.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	return u.list(query, ordering, cursor, func(stored *users.User) (users.Cursor, bool) {
+		return ordering.NewCursor(*stored), true
+	})
+}
+
+func (u *userRepository) SearchUsers(ctx context.Context, query users.SearchQuery) (*users.UserPage, error) {
+	u.logger.Info("Searching users in the repository", zap.Any("query", query))
+
+	ordering, err := query.Ordering()
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := query.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	switch query.Mode {
+	case users.SearchPrefix:
+		prefix := query.Prefix()
+		return u.list(query.Query, ordering, cursor, func(stored *users.User) (users.Cursor, bool) {
+			matches := lo.SomeBy(searchedFields(*stored), func(field string) bool {
+				return strings.HasPrefix(strings.ToLower(field), prefix)
+			})
+			return ordering.NewCursor(*stored), matches
+		})
+	case users.SearchFullText:
+		terms := query.Terms()
+		return u.list(query.Query, ordering, cursor, func(stored *users.User) (users.Cursor, bool) {
+			score := relevance(*stored, terms)
+			return users.NewRelevanceCursor(stored.ID, score), score > 0
+		})
+	case users.SearchFuzzy:
+		expression, err := query.Expression()
+		if err != nil {
+			return nil, err
+		}
+
+		u.mu.RLock()
+		candidates := []users.User{}
+		for _, stored := range u.users {
+			if matchesQuery(query.Query, expression, *stored) {
+				candidates = append(candidates, *stored)
+			}
+		}
+		u.mu.RUnlock()
+
+		return query.FuzzyPage(candidates)
+	default:
+		return nil, users.ErrInvalidSearch
+	}
+}
+
+// list returns a page of the users matching the query filters. The position function returns the position of the
+// user in the ordering and whether the user should be listed at all.
+func (u *userRepository) list(query users.Query, ordering users.Ordering, cursor *users.Cursor, position func(stored *users.User) (users.Cursor, bool)) (*users.UserPage, error) {
+	expression, err := query.Expression()
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.RLock()
+	matches := make([]*users.User, 0, len(u.users))
+	positions := make(map[*users.User]users.Cursor, len(u.users))
+	for _, stored := range u.users {
+		if !matchesQuery(query, expression, *stored) {
+			continue
+		}
+
+		// Copy the user, as it might be updated after the lock is released
+		copied := *stored
+		storedPosition, ok := position(&copied)
+		if !ok {
+			continue
+		}
+
+		matches = append(matches, &copied)
+		positions[&copied] = storedPosition
+	}
+	u.mu.RUnlock()
+
+	// Sort the users by their position, which ends with the id as a tiebreaker
+	sort.Slice(matches, func(i, j int) bool {
+		return compare(ordering, positions[matches[i]], positions[matches[j]]) < 0
+	})
+
+	page := &users.UserPage{Users: []users.User{}}
+	if query.IncludeTotalSize {
+		page.TotalSize = lo.ToPtr(int64(len(matches)))
+	}
+
+	// Continue after the cursor or skip the offset
+	start := min(query.Skip(), int64(len(matches)))
+	if cursor != nil {
+		start = int64(sort.Search(len(matches), func(i int) bool {
+			return compare(ordering, positions[matches[i]], *cursor) > 0
+		}))
+	}
+
+	end := min(start+query.PageSize(), int64(len(matches)))
+	for _, stored := range matches[start:end] {
+		page.Users = append(page.Users, *stored)
+	}
+
+	if end < int64(len(matches)) && end > start {
+		page.NextPageToken = positions[matches[end-1]].Encode()
+	}
+
+	return page, nil
+}
+
+func (u *userRepository) GetUserStats(ctx context.Context, query users.StatsQuery) (*users.UserStats, error) {
+	u.logger.Info("Getting user statistics from the repository", zap.Any("query", query))
+
+	interval := query.BucketInterval()
+	countries := map[string]int64{}
+	signUps := map[time.Time]int64{}
+	stats := &users.UserStats{Countries: []users.CountryCount{}, SignUps: []users.SignUpBucket{}}
+
+	u.mu.RLock()
+	for _, stored := range u.users {
+		if !inRange(query.CreatedAfter, query.CreatedBefore, stored.CreatedAt) {
+			continue
+		}
+
+		stats.Total++
+		countries[stored.Country]++
+		signUps[interval.Truncate(stored.CreatedAt)]++
+	}
+	u.mu.RUnlock()
+
+	for country, count := range countries {
+		stats.Countries = append(stats.Countries, users.CountryCount{Country: country, Count: count})
+	}
+
+	// The most common country first, then alphabetically
+	sort.Slice(stats.Countries, func(i, j int) bool {
+		if stats.Countries[i].Count != stats.Countries[j].Count {
+			return stats.Countries[i].Count > stats.Countries[j].Count
+		}
+
+		return stats.Countries[i].Country < stats.Countries[j].Country
+	})
+
+	for start, count := range signUps {
+		stats.SignUps = append(stats.SignUps, users.SignUpBucket{Start: start, Count: count})
+	}
+
+	sort.Slice(stats.SignUps, func(i, j int) bool {
+		return stats.SignUps[i].Start.Before(stats.SignUps[j].Start)
+	})
+
+	return stats, nil
+}
+
+func (u *userRepository) Watch(ctx context.Context, query users.WatchQuery) (<-chan users.UserEvent, error) {
+	return u.changes.Watch(ctx, query)
+}
+
+// Snapshot copies all the users, sorted by their ID. The changes are published with the lock held, so none is
+// published while copying.
+func (u *userRepository) Snapshot(ctx context.Context) (*users.Snapshot, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	snapshot := &users.Snapshot{
+		Users:       make([]users.User, 0, len(u.users)),
+		ResumeToken: u.changes.Position(),
+		Time:        now(),
+	}
+	for _, user := range u.users {
+		snapshot.Users = append(snapshot.Users, *user)
+	}
+
+	sort.Slice(snapshot.Users, func(i, j int) bool {
+		return snapshot.Users[i].ID < snapshot.Users[j].ID
+	})
+
+	return snapshot, nil
+}
+
+// emailTaken checks if any user other than the excluded one has the email. Must be called with the lock held.
+func (u *userRepository) emailTaken(email, excludedID string) bool {
+	for id, stored := range u.users {
+		if id != excludedID && stored.Email == email {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compare compares the positions of two users in the ordering. It returns a negative number when the first user
+// is listed before the second one, and a positive number when it is listed after it.
+func compare(ordering users.Ordering, a, b users.Cursor) int {
+	for i, field := range ordering {
+		result := compareValues(a.Values[i], b.Values[i])
+		if result == 0 {
+			continue
+		}
+
+		if field.Descending {
+			return -result
+		}
+
+		return result
+	}
+
+	result := strings.Compare(a.ID, b.ID)
+	if ordering.IDDescending() {
+		return -result
+	}
+
+	return result
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	default:
+		return 0
+	}
+}
+
+// searchedFields are the fields of the user matched by the searches.
+func searchedFields(user users.User) []string {
+	return []string{user.FirstName, user.LastName, user.Nickname, user.Email}
+}
+
+// relevance scores how well the user matches the search terms, by counting the occurrences of the terms in the
+// searched fields. Shorter fields matching the terms are more relevant.
+func relevance(user users.User, terms []string) float64 {
+	score := 0.0
+	for _, field := range searchedFields(user) {
+		words := users.SearchTerms(field)
+		for _, word := range words {
+			if lo.Contains(terms, word) {
+				score += 1 / float64(len(words))
+			}
+		}
+	}
+
+	return score
+}
+
+// matchesQuery checks if the user matches all the query's fields, time ranges and the parsed filter expression.
+func matchesQuery(query users.Query, expression users.Expression, user users.User) bool {
+	return matchesField(query.FirstName, user.FirstName) &&
+		matchesField(query.LastName, user.LastName) &&
+		matchesField(query.Nickname, user.Nickname) &&
+		matchesField(query.Country, user.Country) &&
+		matchesField(query.Email, user.Email) &&
+		(len(query.Countries) == 0 || lo.Contains(query.Countries, user.Country)) &&
+		!lo.Contains(query.ExcludedCountries, user.Country) &&
+		inRange(query.CreatedAfter, query.CreatedBefore, user.CreatedAt) &&
+		inRange(query.UpdatedAfter, query.UpdatedBefore, user.UpdatedAt) &&
+		(expression == nil || expression.Matches(user))
+}
+
+// inRange checks if the time is in the range, including the start but not the end.
+func inRange(after, before *time.Time, value time.Time) bool {
+	return (after == nil || !value.Before(*after)) && (before == nil || value.Before(*before))
+}
+
+func matchesField(filter *string, value string) bool {
+	return filter == nil || *filter == value
+}
+
+// now returns the current time with the same precision as the time stored in MongoDB.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// Close stops delivering the changes to the watchers.
+func (u *userRepository) Close(ctx context.Context) error {
+	return u.changes.Close(ctx)
+}
@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users/repositorytest"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+)
+
+func TestUserRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) users.Repository {
+		return NewUserRepository(broadcast.Config{BufferSize: 100})
+	})
+}
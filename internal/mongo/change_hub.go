@@ -0,0 +1,293 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/kamva/mgm/v3"
+	"github.com/samber/lo"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// reconnectInterval is how long the hub waits before reopening a failed change stream.
+const reconnectInterval = time.Second
+
+// changeCursor is the part of the mongo.ChangeStream used to read the changes.
+type changeCursor interface {
+	Next(ctx context.Context) bool
+	Decode(val any) error
+	Err() error
+	ResumeToken() bson.Raw
+	Close(ctx context.Context) error
+}
+
+// changeHub shares a single change stream between all the watchers of the process. The change stream is opened
+// with the first watcher and closed once the last watcher stops watching.
+type changeHub struct {
+	logger  *zap.Logger
+	changes *broadcast.Broadcaster[users.UserEvent]
+	images  bool
+
+	// mu guards the change stream's lifecycle, so no change of a closed change stream is published to the new watchers.
+	mu          sync.Mutex
+	subscribers int
+	cancel      context.CancelFunc
+}
+
+func newChangeHub(cfg broadcast.Config, images bool, logger *zap.Logger) *changeHub {
+	return &changeHub{
+		logger:  logger.Named("change-hub"),
+		changes: broadcast.New[users.UserEvent](cfg),
+		images:  images,
+	}
+}
+
+// subscribe returns the changes matching the filter made after subscribing, until the context is done or the
+// watcher is disconnected for falling behind.
+func (h *changeHub) subscribe(ctx context.Context, filter users.WatchFilter) (<-chan users.UserEvent, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers == 0 {
+		// The first change stream is opened before subscribing, so no change made after subscribing is missed
+		streamCtx, cancel := context.WithCancel(context.Background())
+		changeStream, err := openChangeStream(streamCtx, watchPipeline(users.WatchFilter{}), options.ChangeStream(), h.images)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		h.cancel = cancel
+		go h.run(streamCtx, changeStream)
+	}
+
+	h.subscribers++
+	changes := h.changes.Subscribe(ctx)
+
+	events := make(chan users.UserEvent)
+	go func() {
+		defer close(events)
+		defer h.unsubscribe()
+
+		for event := range changes {
+			// The changes are shared by all the watchers, so they are filtered here
+			if event.Err == nil && !filter.Matches(event) {
+				continue
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+
+			// The watchers missed some changes, so they have to start over
+			if event.Err != nil {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// unsubscribe closes the change stream once there are no more watchers.
+func (h *changeHub) unsubscribe() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.subscribers--
+	if h.subscribers == 0 {
+		h.cancel()
+	}
+}
+
+// close stops delivering the changes to the watchers, which closes the change stream once they all stopped watching.
+func (h *changeHub) close(ctx context.Context) error {
+	return h.changes.Close(ctx)
+}
+
+// run publishes the changes until the context is canceled, reopening the change stream after the last received
+// change when it fails.
+func (h *changeHub) run(ctx context.Context, changeStream changeCursor) {
+	for {
+		err := readChanges(ctx, changeStream, h.images, h.logger, h.publish(ctx))
+		if err != nil {
+			h.logger.Error("Change stream failed, reconnecting", zap.Error(err))
+		}
+
+		resumeToken := changeStream.ResumeToken()
+		err = changeStream.Close(context.Background())
+		if err != nil {
+			h.logger.Warn("Error closing change stream", zap.Error(err))
+		}
+
+		changeStream = h.reopen(ctx, resumeToken)
+		if changeStream == nil {
+			return
+		}
+	}
+}
+
+// publish returns the function publishing the changes of the change stream, until the context is canceled.
+func (h *changeHub) publish(ctx context.Context) func(users.UserEvent) bool {
+	return func(event users.UserEvent) bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return false
+		}
+
+		h.changes.Publish(event)
+		return true
+	}
+}
+
+// reopen opens the change stream after the resume token until it succeeds. Returns nil once the context is canceled.
+func (h *changeHub) reopen(ctx context.Context, resumeToken bson.Raw) changeCursor {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectInterval):
+		}
+
+		opts := options.ChangeStream()
+		if resumeToken != nil {
+			opts.SetResumeAfter(resumeToken)
+		}
+
+		changeStream, err := openChangeStream(ctx, watchPipeline(users.WatchFilter{}), opts, h.images)
+		switch {
+		case err == nil:
+			return changeStream
+		case isHistoryLost(err):
+			// The changes since the failure are lost, so the current watchers are stopped
+			h.logger.Error("Unable to resume the change stream, changes were lost", zap.Error(err))
+			h.publish(ctx)(users.UserEvent{Err: toWatchError(err)})
+			resumeToken = nil
+		case ctx.Err() == nil:
+			h.logger.Error("Unable to reopen the change stream", zap.Error(err))
+		}
+	}
+}
+
+// openChangeStream watches the changes of the users collection matching the pipeline, along with the full documents
+// of the updated users. With the images, the changes carry the users at the time of the change, instead of looking
+// them up.
+func openChangeStream(ctx context.Context, pipeline mongo.Pipeline, opts *options.ChangeStreamOptions, images bool) (*mongo.ChangeStream, error) {
+	// The updated users are looked up, so they can be filtered by their country
+	if images {
+		opts.SetFullDocument(options.WhenAvailable).SetFullDocumentBeforeChange(options.WhenAvailable)
+	} else {
+		opts.SetFullDocument(options.UpdateLookup)
+	}
+
+	return mgm.Coll(&User{}).Watch(ctx, pipeline, opts)
+}
+
+// readChanges sends the changes of the change stream, blocking until the next change. Returns the error of the
+// change stream once it fails, or nil once the context is done or the change could not be sent.
+func readChanges(ctx context.Context, changeStream changeCursor, images bool, logger *zap.Logger, send func(users.UserEvent) bool) error {
+	for changeStream.Next(ctx) {
+		event, err := decodeChange(changeStream, images)
+		if err != nil {
+			logger.Error("Error decoding change stream", zap.Error(err))
+			continue
+		}
+
+		if !send(*event) {
+			return nil
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return changeStream.Err()
+}
+
+// decodeChange decodes the current change of the change stream. The snapshots of the updated users are only
+// decoded with the images, as otherwise the users are looked up after the change.
+func decodeChange(changeStream changeCursor, images bool) (*users.UserEvent, error) {
+	changeEvent := struct {
+		ResumeToken              bson.Raw            `bson:"_id"`
+		ClusterTime              primitive.Timestamp `bson:"clusterTime"`
+		WallTime                 time.Time           `bson:"wallTime"`
+		FullDocument             bson.Raw            `bson:"fullDocument"`
+		FullDocumentBeforeChange bson.Raw            `bson:"fullDocumentBeforeChange"`
+		OperationType            string              `bson:"operationType"`
+		DocumentKey              struct {
+			ID primitive.ObjectID `bson:"_id"`
+		} `bson:"documentKey"`
+		UpdateDescription struct {
+			UpdatedFields bson.Raw `bson:"updatedFields"`
+			RemovedFields []string `bson:"removedFields"`
+		} `bson:"updateDescription"`
+	}{}
+
+	if err := changeStream.Decode(&changeEvent); err != nil {
+		return nil, err
+	}
+
+	event := &users.UserEvent{
+		ChangeType:  changeEvent.OperationType,
+		Time:        changeEvent.WallTime.UTC(),
+		ResumeToken: base64.RawURLEncoding.EncodeToString(changeEvent.ResumeToken),
+	}
+
+	// The wall time is only set since MongoDB 6.0
+	if changeEvent.WallTime.IsZero() {
+		event.Time = time.Unix(int64(changeEvent.ClusterTime.T), 0).UTC()
+	}
+
+	if event.ChangeType == users.ChangeUpdate {
+		elements, err := changeEvent.UpdateDescription.UpdatedFields.Elements()
+		if err != nil {
+			return nil, err
+		}
+
+		event.UpdatedFields = lo.Map(elements, func(element bson.RawElement, _ int) string {
+			return element.Key()
+		})
+		event.RemovedFields = changeEvent.UpdateDescription.RemovedFields
+		event.ChangedFields = lo.Intersect(users.WatchableFields, event.UpdatedFields)
+	}
+
+	if changeEvent.FullDocumentBeforeChange != nil {
+		before, err := decodeUser(changeEvent.FullDocumentBeforeChange)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Before = toUser(before)
+	}
+
+	if changeEvent.FullDocument == nil {
+		// Only the id is known for deletes and updates
+		event.User = users.User{ID: changeEvent.DocumentKey.ID.Hex()}
+		return event, nil
+	}
+
+	user, err := decodeUser(changeEvent.FullDocument)
+	if err != nil {
+		return nil, err
+	}
+
+	event.User = *toUser(user)
+	if images || event.ChangeType == users.ChangeInsert {
+		event.After = lo.ToPtr(event.User)
+	}
+
+	return event, nil
+}
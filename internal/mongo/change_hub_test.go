@@ -0,0 +1,172 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// fakeCursor replays the changes in the process, failing with the err once all the changes were read.
+type fakeCursor struct {
+	changes []bson.Raw
+	current bson.Raw
+	err     error
+}
+
+func newFakeCursor(t *testing.T, err error, changes ...bson.M) *fakeCursor {
+	cursor := &fakeCursor{err: err}
+	for _, change := range changes {
+		raw, marshalErr := bson.Marshal(change)
+		require.NoError(t, marshalErr)
+		cursor.changes = append(cursor.changes, raw)
+	}
+
+	return cursor
+}
+
+func (c *fakeCursor) Next(ctx context.Context) bool {
+	if len(c.changes) == 0 || ctx.Err() != nil {
+		return false
+	}
+
+	c.current, c.changes = c.changes[0], c.changes[1:]
+	return true
+}
+
+func (c *fakeCursor) Decode(val any) error {
+	return bson.Unmarshal(c.current, val)
+}
+
+func (c *fakeCursor) Err() error {
+	return c.err
+}
+
+func (c *fakeCursor) ResumeToken() bson.Raw {
+	return c.current.Lookup("_id").Document()
+}
+
+func (c *fakeCursor) Close(context.Context) error {
+	return nil
+}
+
+func TestReadChanges(t *testing.T) {
+	id := primitive.NewObjectID()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	user := bson.M{
+		"_id":            id,
+		"schema_version": schemaVersion,
+		"first_name":     "Oleksandr",
+		"last_name":      "Kostyliev",
+		"nickname":       "s1mple",
+		"email":          "s1mple@faceit.com",
+		"country":        "UA",
+		"created_at":     now,
+		"updated_at":     now,
+	}
+	renamed := bson.M{}
+	for key, value := range user {
+		renamed[key] = value
+	}
+	renamed["first_name"] = "Sasha"
+
+	insert := bson.M{
+		"_id":           bson.M{"_data": "1"},
+		"operationType": users.ChangeInsert,
+		"wallTime":      now,
+		"documentKey":   bson.M{"_id": id},
+		"fullDocument":  user,
+	}
+	update := bson.M{
+		"_id":                      bson.M{"_data": "2"},
+		"operationType":            users.ChangeUpdate,
+		"clusterTime":              primitive.Timestamp{T: uint32(now.Unix())},
+		"documentKey":              bson.M{"_id": id},
+		"fullDocument":             renamed,
+		"fullDocumentBeforeChange": user,
+		"updateDescription": bson.M{
+			"updatedFields": bson.D{{Key: "first_name", Value: "Sasha"}, {Key: "nickname_trigrams", Value: bson.A{}}},
+			"removedFields": bson.A{"legacy"},
+		},
+	}
+	deleted := bson.M{
+		"_id":           bson.M{"_data": "3"},
+		"operationType": users.ChangeDelete,
+		"wallTime":      now,
+		"documentKey":   bson.M{"_id": id},
+	}
+
+	t.Run("Changes", func(t *testing.T) {
+		failure := errors.New("connection reset")
+		cursor := newFakeCursor(t, failure, insert, update, deleted)
+
+		events := []users.UserEvent{}
+		err := readChanges(context.Background(), cursor, false, zap.NewNop(), func(event users.UserEvent) bool {
+			events = append(events, event)
+			return true
+		})
+		assert.ErrorIs(t, err, failure)
+		require.Len(t, events, 3)
+
+		assert.Equal(t, users.ChangeInsert, events[0].ChangeType)
+		assert.Equal(t, id.Hex(), events[0].User.ID)
+		assert.Equal(t, "s1mple", events[0].User.Nickname)
+		assert.True(t, now.Equal(events[0].Time))
+		assert.NotEmpty(t, events[0].ResumeToken)
+		require.NotNil(t, events[0].After)
+		assert.Equal(t, "Oleksandr", events[0].After.FirstName)
+
+		assert.Equal(t, users.ChangeUpdate, events[1].ChangeType)
+		assert.Equal(t, "Sasha", events[1].User.FirstName)
+		assert.Equal(t, []string{"first_name"}, events[1].ChangedFields)
+		assert.Equal(t, []string{"first_name", "nickname_trigrams"}, events[1].UpdatedFields)
+		assert.Equal(t, []string{"legacy"}, events[1].RemovedFields)
+		assert.True(t, time.Unix(now.Unix(), 0).Equal(events[1].Time), "the cluster time is used without the wall time")
+		assert.Nil(t, events[1].After, "the looked up users are not snapshots of the change")
+		require.NotNil(t, events[1].Before)
+		assert.Equal(t, "Oleksandr", events[1].Before.FirstName)
+
+		assert.Equal(t, users.ChangeDelete, events[2].ChangeType)
+		assert.Equal(t, users.User{ID: id.Hex()}, events[2].User)
+	})
+
+	t.Run("Images", func(t *testing.T) {
+		cursor := newFakeCursor(t, nil, update)
+
+		var event users.UserEvent
+		err := readChanges(context.Background(), cursor, true, zap.NewNop(), func(change users.UserEvent) bool {
+			event = change
+			return true
+		})
+		require.NoError(t, err)
+		require.NotNil(t, event.After)
+		assert.Equal(t, "Sasha", event.After.FirstName)
+	})
+
+	t.Run("Stops sending", func(t *testing.T) {
+		cursor := newFakeCursor(t, errors.New("connection reset"), insert, update)
+
+		sent := 0
+		err := readChanges(context.Background(), cursor, false, zap.NewNop(), func(users.UserEvent) bool {
+			sent++
+			return false
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+	})
+
+	t.Run("History lost", func(t *testing.T) {
+		cursor := newFakeCursor(t, mongo.CommandError{Code: changeStreamHistoryLost, Message: "resume point may no longer be in the oplog"})
+
+		err := readChanges(context.Background(), cursor, false, zap.NewNop(), func(users.UserEvent) bool { return true })
+		assert.ErrorIs(t, toWatchError(err), users.ErrResumeTokenExpired)
+	})
+}
@@ -1,10 +1,14 @@
 package mongo
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/kamva/mgm/v3"
 	"github.com/tavsec/gin-healthcheck/checks"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readconcern"
 	"go.uber.org/zap"
@@ -14,6 +18,10 @@ var once sync.Once
 
 type Configuration struct {
 	URI string `yaml:"uri" json:"uri" mapstructure:"uri"`
+
+	// ChangeStreamImages enables the pre- and post-images of the users collection, so the changes carry the
+	// snapshots of the users before and after them. Requires MongoDB 6.0.
+	ChangeStreamImages bool `yaml:"changeStreamImages" json:"changeStreamImages" mapstructure:"changeStreamImages"`
 }
 
 // Connect connects to the database depending on the type.
@@ -32,8 +40,88 @@ func Connect(configuration Configuration, logger *zap.Logger) checks.Check {
 		}
 
 		logger.Info("Connected to the database")
+
+		err = ensureIndexes()
+		if err != nil {
+			logger.With(zap.Error(err)).Fatal("Unable to create the database indexes")
+		}
+
+		if configuration.ChangeStreamImages {
+			err = enableChangeStreamImages()
+			if err != nil {
+				logger.With(zap.Error(err)).Fatal("Unable to enable the change stream images")
+			}
+		}
 	})
 
 	_, mongoClient, _, _ := mgm.DefaultConfigs()
 	return checks.NewMongoCheck(10, mongoClient)
 }
+
+// enableChangeStreamImages stores the pre- and post-images of the changed users, which are read by the change streams.
+// The images are stored until the oplog entries expire.
+func enableChangeStreamImages() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, _, database, err := mgm.DefaultConfigs()
+	if err != nil {
+		return err
+	}
+
+	return database.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: mgm.Coll(&User{}).Name()},
+		{Key: "changeStreamPreAndPostImages", Value: bson.M{"enabled": true}},
+	}).Err()
+}
+
+// ensureIndexes creates the indexes the repository relies on. The unique email index guarantees users cannot
+// share an email, even when they are created concurrently. The users can only be ordered by the indexed fields.
+func ensureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := mgm.Coll(&User{}).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+		},
+		// Indexes of the other fields the users can be ordered by
+		{
+			Keys: bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "nickname", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "country", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		// Indexes of the orderings by multiple fields
+		{
+			Keys: bson.D{{Key: "country", Value: 1}, {Key: "nickname", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "country", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "nickname", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "nickname_trigrams", Value: 1}},
+		},
+		// The full-text search index, without any language specific stemming or stop words
+		{
+			Keys: bson.D{
+				{Key: "first_name", Value: "text"},
+				{Key: "last_name", Value: "text"},
+				{Key: "nickname", Value: "text"},
+				{Key: "email", Value: "text"},
+			},
+			Options: options.Index().SetName("search").SetDefaultLanguage("none"),
+		},
+	})
+	return err
+}
@@ -0,0 +1,124 @@
+package mongo
+
+import (
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Migration upgrades a single user document from the From version to the next one.
+type Migration struct {
+	// From is the schema version the migration upgrades from.
+	From int
+
+	// Description of the changes, recorded when the migration is applied.
+	Description string
+
+	// Up changes the document in place. It must not set the schema version, as it is set by the registry.
+	Up func(document bson.M) error
+}
+
+// MigrationRegistry holds the migrations upgrading the documents step by step to the latest schema version.
+type MigrationRegistry struct {
+	latest     int
+	migrations map[int]Migration
+}
+
+// NewMigrationRegistry creates a registry for the latest schema version. The migrations must upgrade the documents
+// from version 1 all the way to the latest version, one version at a time.
+func NewMigrationRegistry(latest int, migrations ...Migration) (*MigrationRegistry, error) {
+	registry := &MigrationRegistry{
+		latest:     latest,
+		migrations: map[int]Migration{},
+	}
+
+	for _, migration := range migrations {
+		if migration.From < 1 || migration.From >= latest {
+			return nil, fmt.Errorf("migration from version %d is outside of the schema versions 1 to %d", migration.From, latest)
+		}
+
+		if _, exists := registry.migrations[migration.From]; exists {
+			return nil, fmt.Errorf("duplicate migration from version %d", migration.From)
+		}
+
+		registry.migrations[migration.From] = migration
+	}
+
+	for version := 1; version < latest; version++ {
+		if _, exists := registry.migrations[version]; !exists {
+			return nil, fmt.Errorf("missing migration from version %d", version)
+		}
+	}
+
+	return registry, nil
+}
+
+// LatestVersion is the schema version the registry upgrades the documents to.
+func (r *MigrationRegistry) LatestVersion() int {
+	return r.latest
+}
+
+// Migrations returns all the migrations, ordered by the version they upgrade from.
+func (r *MigrationRegistry) Migrations() []Migration {
+	migrations := make([]Migration, 0, len(r.migrations))
+	for _, migration := range r.migrations {
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].From < migrations[j].From
+	})
+
+	return migrations
+}
+
+// Upgrade applies all the migrations needed to bring the document to the latest version. It returns false when
+// the document already is at the latest (or a newer) version.
+func (r *MigrationRegistry) Upgrade(document bson.M) (bool, error) {
+	version := documentVersion(document)
+	if version >= r.latest {
+		return false, nil
+	}
+
+	for ; version < r.latest; version++ {
+		err := r.Step(document, version)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Step applies the single migration upgrading the document from the version to the next one.
+func (r *MigrationRegistry) Step(document bson.M, from int) error {
+	migration, exists := r.migrations[from]
+	if !exists {
+		return fmt.Errorf("no migration from version %d", from)
+	}
+
+	err := migration.Up(document)
+	if err != nil {
+		return fmt.Errorf("unable to migrate from version %d: %w", from, err)
+	}
+
+	document["schema_version"] = from + 1
+	return nil
+}
+
+// documentVersion returns the schema version of the document. Documents without a version are at the first version.
+func documentVersion(document bson.M) int {
+	switch version := document["schema_version"].(type) {
+	case int32:
+		return int(version)
+	case int64:
+		return int(version)
+	case int:
+		return version
+	case float64:
+		return int(version)
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,103 @@
+package mongo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNewMigrationRegistry(t *testing.T) {
+	fromFirst := Migration{From: 1, Up: func(document bson.M) error { return nil }}
+	fromSecond := Migration{From: 2, Up: func(document bson.M) error { return nil }}
+
+	_, err := NewMigrationRegistry(3, fromSecond, fromFirst)
+	assert.NoError(t, err)
+
+	_, err = NewMigrationRegistry(3, fromSecond)
+	assert.ErrorContains(t, err, "missing migration from version 1")
+
+	_, err = NewMigrationRegistry(3, fromFirst, fromFirst, fromSecond)
+	assert.ErrorContains(t, err, "duplicate migration from version 1")
+
+	_, err = NewMigrationRegistry(2, fromFirst, fromSecond)
+	assert.ErrorContains(t, err, "outside of the schema versions")
+
+	_, err = NewMigrationRegistry(schemaVersion, userMigrations.Migrations()...)
+	assert.NoError(t, err, "the user migrations must cover all the schema versions")
+}
+
+func TestMigrationRegistry_Upgrade(t *testing.T) {
+	registry, err := NewMigrationRegistry(3,
+		Migration{
+			From: 1,
+			Up: func(document bson.M) error {
+				document["email"] = strings.ToLower(document["email"].(string))
+				return nil
+			},
+		},
+		Migration{
+			From: 2,
+			Up: func(document bson.M) error {
+				document["display_name"] = document["nickname"]
+				return nil
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	t.Run("Document without a version", func(t *testing.T) {
+		document := bson.M{"email": "S1mple@Faceit.com", "nickname": "s1mple"}
+
+		upgraded, err := registry.Upgrade(document)
+		require.NoError(t, err)
+		assert.True(t, upgraded)
+		assert.Equal(t, bson.M{"email": "s1mple@faceit.com", "nickname": "s1mple", "display_name": "s1mple", "schema_version": 3}, document)
+	})
+
+	t.Run("Document in an intermediate version", func(t *testing.T) {
+		document := bson.M{"email": "S1mple@Faceit.com", "nickname": "s1mple", "schema_version": int32(2)}
+
+		upgraded, err := registry.Upgrade(document)
+		require.NoError(t, err)
+		assert.True(t, upgraded)
+		assert.Equal(t, bson.M{"email": "S1mple@Faceit.com", "nickname": "s1mple", "display_name": "s1mple", "schema_version": 3}, document)
+	})
+
+	t.Run("Document in the latest version", func(t *testing.T) {
+		document := bson.M{"email": "S1mple@Faceit.com", "schema_version": int32(3)}
+
+		upgraded, err := registry.Upgrade(document)
+		require.NoError(t, err)
+		assert.False(t, upgraded)
+		assert.Equal(t, bson.M{"email": "S1mple@Faceit.com", "schema_version": int32(3)}, document)
+	})
+}
+
+func TestUserMigrations(t *testing.T) {
+	t.Run("Renamed names", func(t *testing.T) {
+		document := bson.M{"schema_version": 2, "firstname": "Oleksandr", "lastname": "Kostyliev", "nickname": "s1mple"}
+
+		_, err := userMigrations.Upgrade(document)
+		require.NoError(t, err)
+		assert.Equal(t, bson.M{"schema_version": schemaVersion, "first_name": "Oleksandr", "last_name": "Kostyliev", "nickname": "s1mple"}, document)
+	})
+
+	t.Run("Names set by an update", func(t *testing.T) {
+		document := bson.M{"schema_version": 2, "firstname": "Oleksandr", "first_name": "Sasha", "nickname": "s1mple"}
+
+		_, err := userMigrations.Upgrade(document)
+		require.NoError(t, err)
+		assert.Equal(t, bson.M{"schema_version": schemaVersion, "first_name": "Sasha", "nickname": "s1mple"}, document)
+	})
+}
+
+func TestMigrationStatus_Outdated(t *testing.T) {
+	status := MigrationStatus{LatestVersion: 3, Documents: map[int]int64{1: 2, 2: 5, 3: 10}}
+	assert.Equal(t, int64(7), status.Outdated())
+
+	status = MigrationStatus{LatestVersion: 3, Documents: map[int]int64{3: 10}}
+	assert.Zero(t, status.Outdated())
+}
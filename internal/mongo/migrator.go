@@ -0,0 +1,303 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kamva/mgm/v3"
+	"github.com/pkg/errors"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	// migrationsCollection records the applied migrations and holds the migration lock.
+	migrationsCollection = "migrations"
+
+	migrationLockID  = "lock"
+	migrationLockTTL = 5 * time.Minute
+
+	// migrationBatchSize is the number of documents upgraded before the lock is renewed.
+	migrationBatchSize = 500
+)
+
+var ErrMigrationLocked = errors.New("another migration is in progress")
+
+// userMigrations upgrade the user documents to the current schemaVersion. To change the schema of the users, bump
+// the schemaVersion and add a migration from the previous version.
+var userMigrations = mustMigrationRegistry(schemaVersion,
+	Migration{
+		From:        1,
+		Description: "Add the nickname trigrams for fuzzy searches",
+		Up: func(document bson.M) error {
+			nickname, _ := document["nickname"].(string)
+			document["nickname_trigrams"] = users.Trigrams(nickname)
+			return nil
+		},
+	},
+	Migration{
+		From:        2,
+		Description: "Rename the firstname and lastname to first_name and last_name",
+		Up: func(document bson.M) error {
+			// The updates already set the new fields, which are more recent than the old ones
+			for old, renamed := range map[string]string{"firstname": "first_name", "lastname": "last_name"} {
+				value, ok := document[old]
+				if _, updated := document[renamed]; ok && !updated {
+					document[renamed] = value
+				}
+
+				delete(document, old)
+			}
+
+			return nil
+		},
+	},
+)
+
+func mustMigrationRegistry(latest int, migrations ...Migration) *MigrationRegistry {
+	registry, err := NewMigrationRegistry(latest, migrations...)
+	if err != nil {
+		panic(err)
+	}
+
+	return registry
+}
+
+// AppliedMigration is the record of a migration applied to the users collection.
+type AppliedMigration struct {
+	// Version the documents were upgraded to.
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	Documents   int64     `bson:"documents"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+type MigrationStatus struct {
+	// LatestVersion is the schema version the service upgrades the documents to.
+	LatestVersion int
+
+	// Documents is the number of user documents in each of the schema versions.
+	Documents map[int]int64
+
+	// Applied are the migrations recorded as applied, ordered by version.
+	Applied []AppliedMigration
+}
+
+// Outdated returns the number of user documents in the schema versions older than the latest one.
+func (s *MigrationStatus) Outdated() int64 {
+	outdated := int64(0)
+	for version, documents := range s.Documents {
+		if version < s.LatestVersion {
+			outdated += documents
+		}
+	}
+
+	return outdated
+}
+
+// Migrator applies the migrations to all the user documents stored in the database.
+type Migrator struct {
+	logger   *zap.Logger
+	registry *MigrationRegistry
+	owner    string
+}
+
+func NewMigrator() *Migrator {
+	hostname, _ := os.Hostname()
+
+	return &Migrator{
+		logger:   zap.L().Named("migrator"),
+		registry: userMigrations,
+		owner:    fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), primitive.NewObjectID().Hex()),
+	}
+}
+
+// Status returns the number of documents in each schema version and the applied migrations.
+func (m *Migrator) Status(ctx context.Context) (*MigrationStatus, error) {
+	status := &MigrationStatus{
+		LatestVersion: m.registry.LatestVersion(),
+		Documents:     map[int]int64{},
+		Applied:       []AppliedMigration{},
+	}
+
+	groupStage := bson.D{{
+		Key: "$group",
+		Value: bson.D{
+			{Key: "_id", Value: "$schema_version"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		},
+	}}
+
+	cursor, err := mgm.Coll(&User{}).Aggregate(ctx, mongo.Pipeline{groupStage})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := []struct {
+		Version *int  `bson:"_id"`
+		Count   int64 `bson:"count"`
+	}{}
+	err = cursor.All(ctx, &counts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, count := range counts {
+		// Documents without a version are at the first version
+		version := 1
+		if count.Version != nil {
+			version = *count.Version
+		}
+
+		status.Documents[version] += count.Count
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err = mgm.CollectionByName(migrationsCollection).Find(ctx, bson.M{"_id": bson.M{"$ne": migrationLockID}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &status.Applied)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// Up upgrades all the documents to the latest schema version, one migration at a time. Only a single migration
+// can run at once, others fail with ErrMigrationLocked.
+func (m *Migrator) Up(ctx context.Context) error {
+	err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.unlock()
+
+	for _, migration := range m.registry.Migrations() {
+		m.logger.Info("Applying migration",
+			zap.Int("from", migration.From),
+			zap.Int("to", migration.From+1),
+			zap.String("description", migration.Description),
+		)
+
+		migrated, err := m.apply(ctx, migration)
+		if err != nil {
+			return errors.Wrapf(err, "unable to migrate from version %d", migration.From)
+		}
+
+		err = m.record(ctx, migration, migrated)
+		if err != nil {
+			return err
+		}
+
+		m.logger.Info("Applied migration", zap.Int("to", migration.From+1), zap.Int64("documents", migrated))
+	}
+
+	return nil
+}
+
+// apply upgrades the documents in the migration's version until there are none left.
+func (m *Migrator) apply(ctx context.Context, migration Migration) (int64, error) {
+	collection := mgm.Coll(&User{})
+	migrated := int64(0)
+
+	filter := bson.M{"schema_version": migration.From}
+	if migration.From == 1 {
+		filter = bson.M{"$or": bson.A{filter, bson.M{"schema_version": bson.M{"$exists": false}}}}
+	}
+
+	for {
+		documents := []bson.M{}
+		cursor, err := collection.Find(ctx, filter, options.Find().SetLimit(migrationBatchSize))
+		if err != nil {
+			return migrated, err
+		}
+
+		err = cursor.All(ctx, &documents)
+		if err != nil {
+			return migrated, err
+		}
+
+		if len(documents) == 0 {
+			return migrated, nil
+		}
+
+		for _, document := range documents {
+			// Replace the document only if it was not updated in the meantime, otherwise it is picked up again
+			replaceFilter := bson.M{"_id": document["_id"], "updated_at": document["updated_at"]}
+
+			err := m.registry.Step(document, migration.From)
+			if err != nil {
+				return migrated, err
+			}
+
+			res, err := collection.ReplaceOne(ctx, replaceFilter, document)
+			if err != nil {
+				return migrated, err
+			}
+
+			migrated += res.ModifiedCount
+		}
+
+		// Keep holding the lock while the migration is running
+		err = m.lock(ctx)
+		if err != nil {
+			return migrated, err
+		}
+	}
+}
+
+// record stores the migration as applied, accumulating the number of migrated documents over multiple runs.
+func (m *Migrator) record(ctx context.Context, migration Migration, migrated int64) error {
+	update := bson.M{
+		"$set":         bson.M{"description": migration.Description},
+		"$inc":         bson.M{"documents": migrated},
+		"$setOnInsert": bson.M{"applied_at": time.Now().UTC()},
+	}
+
+	_, err := mgm.CollectionByName(migrationsCollection).UpdateByID(ctx, migration.From+1, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// lock acquires or renews the migration lock. The lock expires, so a crashed migration does not block others forever.
+func (m *Migrator) lock(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	filter := bson.M{
+		"_id": migrationLockID,
+		"$or": bson.A{
+			bson.M{"owner": m.owner},
+			bson.M{"expires_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": m.owner, "expires_at": now.Add(migrationLockTTL)}}
+
+	// If the lock is held by someone else, the upsert fails as the lock already exists
+	_, err := mgm.CollectionByName(migrationsCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	switch {
+	case err == nil:
+		return nil
+	case mongo.IsDuplicateKeyError(err):
+		return ErrMigrationLocked
+	default:
+		return err
+	}
+}
+
+func (m *Migrator) unlock() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := mgm.CollectionByName(migrationsCollection).DeleteOne(ctx, bson.M{"_id": migrationLockID, "owner": m.owner})
+	if err != nil {
+		m.logger.Error("Unable to release the migration lock", zap.Error(err))
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 
 	"github.com/kamva/mgm/v3"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
 	"golang.org/x/crypto/bcrypt"
 )
 
-const schemaVersion = 1
+// schemaVersion is the current version of the user documents. The version 2 added the nickname trigrams, the
+// version 3 renamed the names to first_name and last_name, the same as they are queried by.
+const schemaVersion = 3
 
 type User struct {
 	// DefaultModels contains the id, created and updated at fields for the model.
@@ -16,14 +19,17 @@ type User struct {
 	SchemaVersion int `json:"schema_version" bson:"schema_version"`
 
 	// FirstName of the user.
-	FirstName string `json:"first_name"`
+	FirstName string `json:"first_name" bson:"first_name"`
 
 	// LastName of the user.
-	LastName string `json:"last_name"`
+	LastName string `json:"last_name" bson:"last_name"`
 
 	// Nickname is the nickname of the user.
 	Nickname string `json:"nickname"`
 
+	// NicknameTrigrams are the trigrams of the nickname, used to find the users for fuzzy searches.
+	NicknameTrigrams []string `json:"nickname_trigrams" bson:"nickname_trigrams"`
+
 	// Email of the user.
 	Email string `json:"email"`
 
@@ -37,13 +43,14 @@ type User struct {
 // NewUser creates a new user with the given parameters.
 func NewUser(firstName, lastName, nickname, email, password, country string) *User {
 	return &User{
-		SchemaVersion: schemaVersion,
-		FirstName:     firstName,
-		LastName:      lastName,
-		Nickname:      nickname,
-		Email:         email,
-		Password:      password,
-		Country:       country,
+		SchemaVersion:    schemaVersion,
+		FirstName:        firstName,
+		LastName:         lastName,
+		Nickname:         nickname,
+		NicknameTrigrams: users.Trigrams(nickname),
+		Email:            email,
+		Password:         password,
+		Country:          country,
 	}
 }
 
@@ -2,12 +2,18 @@ package mongo
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/kamva/mgm/v3"
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -15,53 +21,95 @@ import (
 	"go.uber.org/zap"
 )
 
+// Error codes of the change streams which can no longer be resumed.
+const (
+	changeStreamFatalError  = 280
+	changeStreamHistoryLost = 286
+)
+
+// maxFuzzyCandidates is the largest number of users compared with the text of a fuzzy search.
+const maxFuzzyCandidates = 1000
+
 type userRepository struct {
 	logger *zap.Logger
+	hub    *changeHub
+	images bool
+
+	// closed is canceled once the repository is closed, stopping the watchers with their own change streams, which
+	// are tracked until they close their change streams.
+	closed   context.Context
+	stop     context.CancelFunc
+	watchers sync.WaitGroup
 }
 
-func NewUserRepository() users.Repository {
+// NewUserRepository creates the users.Repository backed by MongoDB. The changes are delivered to the watchers as
+// configured, along with the snapshots of the users if the change stream images are enabled.
+func NewUserRepository(configuration Configuration, watch broadcast.Config) users.Repository {
+	logger := zap.L().Named("user-repository")
+	closed, stop := context.WithCancel(context.Background())
 	return &userRepository{
-		logger: zap.L().Named("user-repository"),
+		logger: logger,
+		hub:    newChangeHub(watch, configuration.ChangeStreamImages, logger),
+		images: configuration.ChangeStreamImages,
+		closed: closed,
+		stop:   stop,
 	}
 }
 
 func (u *userRepository) AddUser(ctx context.Context, user *users.User) error {
 	u.logger.Info("Adding user to the database")
-	userCollection := mgm.Coll(&User{})
 
-	// Check if a user with this email already exists
-	cursor, err := userCollection.Find(ctx, bson.M{"email": user.Email})
-	if err != nil {
+	userEntity := toEntity(user)
+	err := mgm.Coll(&User{}).CreateWithCtx(ctx, &userEntity)
+	switch {
+	case err == nil:
+		user.ID = userEntity.ID.Hex()
+		return nil
+	case mongo.IsDuplicateKeyError(err):
+		// A user with this email already exists
+		return users.ErrUserAlreadyExists
+	default:
 		return err
 	}
+}
 
-	if cursor.RemainingBatchLength() > 0 {
-		return users.ErrUserAlreadyExists
-	}
+func (u *userRepository) UpdateUser(ctx context.Context, user users.User) (*users.User, error) {
+	u.logger.Info("Updating user in the database")
 
-	userEntity := toEntity(user)
-	err = mgm.Coll(&User{}).CreateWithCtx(ctx, &userEntity)
+	hex, err := primitive.ObjectIDFromHex(user.ID)
 	if err != nil {
-		return err
+		return nil, users.ErrInvalidID
 	}
 
-	user.ID = userEntity.ID.Hex()
-
-	return nil
+	// Only set the user's fields, keeping the creation time and the password
+	update := bson.M{
+		"$set": bson.M{
+			"first_name": user.FirstName,
+			"last_name":  user.LastName,
+			"nickname":   user.Nickname,
+			"email":      user.Email,
+
+			"nickname_trigrams": users.Trigrams(user.Nickname),
+			"country":           user.Country,
+			"updated_at":        time.Now().UTC(),
+		},
+	}
 
-}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
-func (u *userRepository) UpdateUser(ctx context.Context, user users.User) (*users.User, error) {
-	u.logger.Info("Updating user in the database")
-
-	userEntity := toEntity(&user)
-	err := mgm.Coll(&User{}).UpdateWithCtx(ctx, &userEntity)
+	raw, err := mgm.Coll(&User{}).FindOneAndUpdate(ctx, bson.M{"_id": hex}, update, opts).Raw()
 	switch {
 	case err == nil:
-		res := toUser(&userEntity)
-		return res, nil
+		userEntity, err := decodeUser(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return toUser(userEntity), nil
 	case errors.Is(err, mongo.ErrNoDocuments):
 		return nil, users.ErrUserNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return nil, users.ErrUserAlreadyExists
 	default:
 		return nil, err
 	}
@@ -72,7 +120,7 @@ func (u *userRepository) DeleteUser(ctx context.Context, id string) error {
 
 	hex, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return err
+		return users.ErrInvalidID
 	}
 
 	one, err := mgm.Coll(&User{}).DeleteOne(ctx, bson.M{"_id": hex})
@@ -93,10 +141,19 @@ func (u *userRepository) DeleteUser(ctx context.Context, id string) error {
 func (u *userRepository) GetUser(ctx context.Context, id string) (*users.User, error) {
 	u.logger.Info("Getting a user from the database", zap.String("id", id))
 
-	user := &User{}
-	err := mgm.Coll(&User{}).FindByIDWithCtx(ctx, id, user)
+	hex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, users.ErrInvalidID
+	}
+
+	raw, err := mgm.Coll(&User{}).FindOne(ctx, bson.M{"_id": hex}).Raw()
 	switch {
 	case err == nil:
+		user, err := decodeUser(raw)
+		if err != nil {
+			return nil, err
+		}
+
 		return toUser(user), nil
 	case errors.Is(err, mongo.ErrNoDocuments):
 		return nil, users.ErrUserNotFound
@@ -105,142 +162,681 @@ func (u *userRepository) GetUser(ctx context.Context, id string) (*users.User, e
 	}
 }
 
-func (u *userRepository) GetUsers(ctx context.Context, firstName, lastName, nickname, country, email *string, limit, offset *int64) ([]users.User, error) {
-	// Create a query
-	query := bson.M{}
-	if firstName != nil {
-		query["first_name"] = *firstName
+func (u *userRepository) GetUsers(ctx context.Context, query users.Query) (*users.UserPage, error) {
+	ordering, err := query.Ordering()
+	if err != nil {
+		return nil, err
 	}
 
-	if lastName != nil {
-		query["last_name"] = *lastName
+	// Continue after the last user of the previous page
+	cursor, err := query.Cursor()
+	if err != nil {
+		return nil, err
 	}
 
-	if nickname != nil {
-		query["nickname"] = *nickname
+	filter, err := toFilter(query)
+	if err != nil {
+		return nil, err
 	}
 
-	if country != nil {
-		query["country"] = *country
+	u.logger.Info("Getting users from the database")
+	return u.find(ctx, query, ordering, cursor, filter)
+}
+
+func (u *userRepository) SearchUsers(ctx context.Context, query users.SearchQuery) (*users.UserPage, error) {
+	ordering, err := query.Ordering()
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := query.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	u.logger.Info("Searching users in the database", zap.String("mode", string(query.Mode)))
+
+	filter, err := toFilter(query.Query)
+	if err != nil {
+		return nil, err
 	}
 
-	if email != nil {
-		query["email"] = *email
+	switch query.Mode {
+	case users.SearchPrefix:
+		// Case-insensitive expressions cannot fully use the indexes, but anchoring them limits the scanned keys
+		pattern := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(query.Prefix()), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"first_name": pattern},
+			bson.M{"last_name": pattern},
+			bson.M{"nickname": pattern},
+			bson.M{"email": pattern},
+		}
+
+		return u.find(ctx, query.Query, ordering, cursor, filter)
+	case users.SearchFullText:
+		// The terms only contain letters and digits, so they cannot be negated or quoted
+		filter["$text"] = bson.M{"$search": strings.Join(query.Terms(), " ")}
+		return u.searchText(ctx, query.Query, ordering, cursor, filter)
+	case users.SearchFuzzy:
+		return u.searchFuzzy(ctx, query, filter)
+	default:
+		return nil, users.ErrInvalidSearch
 	}
+}
 
-	u.logger.Info("Getting users from the database", zap.Any("query", query))
+// find returns a page of the users matching the filter, sorted in the ordering.
+func (u *userRepository) find(ctx context.Context, query users.Query, ordering users.Ordering, cursor *users.Cursor, filter bson.M) (*users.UserPage, error) {
+	page := &users.UserPage{Users: []users.User{}}
+	if query.IncludeTotalSize {
+		total, err := mgm.Coll(&User{}).CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
 
-	// Set default limit and offset for pagination
-	if limit == nil {
-		defaultLimit := int64(30)
-		limit = &defaultLimit
+		page.TotalSize = &total
 	}
 
-	if offset == nil {
-		defaultOffset := int64(0)
-		offset = &defaultOffset
+	if cursor != nil {
+		after, err := afterCursor(ordering, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		filter = bson.M{"$and": bson.A{filter, after}}
 	}
 
+	u.logger.Debug("Finding users", zap.Any("filter", filter))
+
+	// Fetching an extra user to know if there is a next page
+	pageSize := query.PageSize()
 	opts := &options.FindOptions{
-		Limit: lo.ToPtr(*limit),
-		Skip:  lo.ToPtr(*offset),
-		// Sorting by created_at in descending order
-		Sort: bson.D{{"created_at", -1}},
+		Limit: lo.ToPtr(pageSize + 1),
+		Skip:  lo.ToPtr(query.Skip()),
+		Sort:  toSort(ordering),
+	}
+
+	results, err := mgm.Coll(&User{}).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close(ctx)
+
+	err = readPage(ctx, results, page, pageSize, func(_ bson.Raw, user *User) users.Cursor {
+		return ordering.NewCursor(*toUser(user))
+	})
+	return page, err
+}
+
+func (u *userRepository) GetUserStats(ctx context.Context, query users.StatsQuery) (*users.UserStats, error) {
+	filter, err := toFilter(users.Query{CreatedAfter: query.CreatedAfter, CreatedBefore: query.CreatedBefore})
+	if err != nil {
+		return nil, err
 	}
 
-	dbUsers := []User{}
-	err := mgm.Coll(&User{}).SimpleFindWithCtx(ctx, &dbUsers, query, opts)
+	u.logger.Info("Aggregating user statistics", zap.String("interval", string(query.BucketInterval())))
+
+	// All the statistics are computed in a single pass over the matching users
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: bson.M{
+			"total": bson.A{
+				bson.M{"$count": "count"},
+			},
+			"countries": bson.A{
+				bson.M{"$group": bson.M{"_id": "$country", "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			},
+			"sign_ups": bson.A{
+				bson.M{"$group": bson.M{
+					"_id": bson.M{"$dateTrunc": bson.M{
+						"date":        "$created_at",
+						"unit":        string(query.BucketInterval()),
+						"timezone":    "UTC",
+						"startOfWeek": "monday",
+					}},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$sort": bson.M{"_id": 1}},
+			},
+		}}},
+	}
+
+	results, err := mgm.Coll(&User{}).Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
+	defer results.Close(ctx)
+
+	facets := []struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Countries []struct {
+			Country string `bson:"_id"`
+			Count   int64  `bson:"count"`
+		} `bson:"countries"`
+		SignUps []struct {
+			Start time.Time `bson:"_id"`
+			Count int64     `bson:"count"`
+		} `bson:"sign_ups"`
+	}{}
+
+	err = results.All(ctx, &facets)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &users.UserStats{Countries: []users.CountryCount{}, SignUps: []users.SignUpBucket{}}
+	if len(facets) == 0 {
+		return stats, nil
+	}
 
-	response := make([]users.User, len(dbUsers))
-	for i, user := range dbUsers {
-		response[i] = *toUser(&user)
+	// The count stage returns no documents when no users match
+	if len(facets[0].Total) > 0 {
+		stats.Total = facets[0].Total[0].Count
 	}
 
-	return response, nil
+	for _, country := range facets[0].Countries {
+		stats.Countries = append(stats.Countries, users.CountryCount{Country: country.Country, Count: country.Count})
+	}
+
+	for _, bucket := range facets[0].SignUps {
+		stats.SignUps = append(stats.SignUps, users.SignUpBucket{Start: bucket.Start.UTC(), Count: bucket.Count})
+	}
+
+	return stats, nil
 }
 
-func (u *userRepository) Watch(ctx context.Context) (<-chan users.UserEvent, error) {
-	_, _, database, err := mgm.DefaultConfigs()
+// searchText returns a page of the users matching the text search filter, the most relevant first.
+func (u *userRepository) searchText(ctx context.Context, query users.Query, ordering users.Ordering, cursor *users.Cursor, filter bson.M) (*users.UserPage, error) {
+	page := &users.UserPage{Users: []users.User{}}
+	if query.IncludeTotalSize {
+		total, err := mgm.Coll(&User{}).CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		page.TotalSize = &total
+	}
+
+	// The score is added as the relevance field, so it can be compared to the cursor
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$addFields", Value: bson.M{users.FieldRelevance: bson.M{"$meta": "textScore"}}}},
+	}
+
+	if cursor != nil {
+		after, err := afterCursor(ordering, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: after}})
+	}
+
+	// Fetching an extra user to know if there is a next page
+	pageSize := query.PageSize()
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: toSort(ordering)}},
+		bson.D{{Key: "$skip", Value: query.Skip()}},
+		bson.D{{Key: "$limit", Value: pageSize + 1}},
+	)
+
+	results, err := mgm.Coll(&User{}).Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
+	defer results.Close(ctx)
 
-	// Assuming no other service will be writing to the database, we can use the change stream to get the changes
-	matchStage := bson.D{{
-		"$match",
-		bson.D{{
-			"operationType",
-			bson.D{{"$in", bson.A{"insert", "update", "delete"}}},
-		},
-		},
-	}}
+	err = readPage(ctx, results, page, pageSize, func(raw bson.Raw, user *User) users.Cursor {
+		return users.NewRelevanceCursor(user.ID.Hex(), raw.Lookup(users.FieldRelevance).Double())
+	})
+	return page, err
+}
 
-	opts := options.ChangeStream()
-	changeStream, err := database.Watch(ctx, mongo.Pipeline{matchStage}, opts)
+// searchFuzzy returns a page of the users with a nickname similar to the searched text. The candidates sharing the
+// most trigrams with the text are found using the index of the trigrams, and then compared with the text.
+func (u *userRepository) searchFuzzy(ctx context.Context, query users.SearchQuery, filter bson.M) (*users.UserPage, error) {
+	trigrams := users.Trigrams(query.Text)
+	filter["nickname_trigrams"] = bson.M{"$in": trigrams}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$addFields", Value: bson.M{
+			"shared_trigrams": bson.M{"$size": bson.M{"$setIntersection": bson.A{"$nickname_trigrams", trigrams}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "shared_trigrams", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$limit", Value: maxFuzzyCandidates}},
+	}
+
+	results, err := mgm.Coll(&User{}).Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
+	defer results.Close(ctx)
+
+	candidates := []users.User{}
+	for results.Next(ctx) {
+		user, err := decodeUser(results.Current)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, *toUser(user))
+	}
+
+	if results.Err() != nil {
+		return nil, results.Err()
+	}
+
+	return query.FuzzyPage(candidates)
+}
+
+// readPage reads the users from the results into the page. The results contain an extra user if there is a next
+// page, the position returns the cursor pointing at the user.
+func readPage(ctx context.Context, results *mongo.Cursor, page *users.UserPage, pageSize int64, position func(raw bson.Raw, user *User) users.Cursor) error {
+	last := users.Cursor{}
+	for results.Next(ctx) {
+		user, err := decodeUser(results.Current)
+		if err != nil {
+			return err
+		}
+
+		// The extra user only signals that there is a next page
+		if int64(len(page.Users)) == pageSize {
+			page.NextPageToken = last.Encode()
+			break
+		}
+
+		page.Users = append(page.Users, *toUser(user))
+		last = position(results.Current, user)
+	}
+
+	return results.Err()
+}
+
+// Watch returns the changes from the change stream shared by all the watchers. Watchers resuming after a resume
+// token or from a start time open their own change stream, as the shared one only carries the new changes.
+func (u *userRepository) Watch(ctx context.Context, query users.WatchQuery) (<-chan users.UserEvent, error) {
+	opts := options.ChangeStream()
+	switch {
+	case query.ResumeToken != "":
+		token, err := base64.RawURLEncoding.DecodeString(query.ResumeToken)
+		if err != nil || bson.Raw(token).Validate() != nil {
+			return nil, users.ErrInvalidResumeToken
+		}
+
+		// The snapshots are positioned at a cluster time rather than after a change
+		if t, i, ok := bson.Raw(token).Lookup("clusterTime").TimestampOK(); ok {
+			opts.SetStartAtOperationTime(&primitive.Timestamp{T: t, I: i})
+		} else {
+			opts.SetResumeAfter(bson.Raw(token))
+		}
+	case query.StartAt != nil:
+		opts.SetStartAtOperationTime(&primitive.Timestamp{T: uint32(query.StartAt.Unix())})
+	default:
+		return u.hub.subscribe(ctx, query.WatchFilter)
+	}
+
+	// The watcher is stopped when the repository is closed
+	ctx, cancel := context.WithCancel(ctx)
+	stopWatching := context.AfterFunc(u.closed, cancel)
+
+	changeStream, err := openChangeStream(ctx, watchPipeline(query.WatchFilter), opts, u.images)
+	if err != nil {
+		stopWatching()
+		cancel()
+		return nil, toWatchError(err)
+	}
 
 	userChan := make(chan users.UserEvent)
 
 	// Stream to goroutine
+	u.watchers.Add(1)
 	go func() {
-		for {
+		defer u.watchers.Done()
+		defer close(userChan)
+		defer cancel()
+		defer stopWatching()
+		defer func() {
+			err := changeStream.Close(context.Background())
+			if err != nil {
+				u.logger.Error("Error closing change stream", zap.Error(err))
+			}
+		}()
+
+		err := readChanges(ctx, changeStream, u.images, u.logger, func(event users.UserEvent) bool {
 			select {
+			case userChan <- event:
+				return true
 			case <-ctx.Done():
-				close(userChan)
-
-				err := changeStream.Close(context.Background())
-				if err != nil {
-					u.logger.Error("Error closing change stream", zap.Error(err))
-				}
-
-				return
-			default:
-				if changeStream.Next(ctx) {
-					changeEvent := struct {
-						FullDocument  *User  `bson:"fullDocument"`
-						OperationType string `bson:"operationType"`
-					}{}
-
-					if err := changeStream.Decode(&changeEvent); err != nil {
-						u.logger.Error("Error decoding change stream", zap.Error(err))
-						continue
-					}
-
-					event := users.UserEvent{
-						ChangeType: changeEvent.OperationType,
-					}
-					if changeEvent.FullDocument == nil {
-						event.User = users.User{}
-					} else {
-						event.User = *toUser(changeEvent.FullDocument)
-					}
-
-					userChan <- event
-				}
-
-				time.Sleep(time.Millisecond * 100)
+				return false
 			}
+		})
+		if err == nil {
+			return
+		}
+
+		u.logger.Error("Change stream failed", zap.Error(err))
+		select {
+		case userChan <- users.UserEvent{Err: toWatchError(err)}:
+		case <-ctx.Done():
 		}
 	}()
 
 	return userChan, nil
 }
 
+// Snapshot reads all the users, sorted by their ID, at a single cluster time using a snapshot session. The resume
+// token of the snapshot continues with the changes made after the cluster time. Requires MongoDB 5.0.
+func (u *userRepository) Snapshot(ctx context.Context) (*users.Snapshot, error) {
+	coll := mgm.Coll(&User{})
+	session, err := coll.Database().Client().StartSession(options.Session().SetSnapshot(true))
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(context.Background())
+
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	results, err := coll.Find(sessionCtx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close(context.Background())
+
+	snapshot := &users.Snapshot{Users: []users.User{}}
+	for results.Next(sessionCtx) {
+		user, err := decodeUser(results.Current)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot.Users = append(snapshot.Users, *toUser(user))
+	}
+
+	if err := results.Err(); err != nil {
+		return nil, err
+	}
+
+	// The snapshot reads are made at the operation time of the session
+	clusterTime := session.OperationTime()
+	if clusterTime == nil {
+		return nil, errors.New("snapshot was read without a cluster time")
+	}
+
+	snapshot.Time = time.Unix(int64(clusterTime.T), 0).UTC()
+	snapshot.ResumeToken, err = clusterTimeToken(*clusterTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// clusterTimeToken returns the resume token of the changes made after the cluster time. The changes made at the
+// cluster time are already part of the snapshot, so the token starts at the next operation.
+func clusterTimeToken(clusterTime primitive.Timestamp) (string, error) {
+	next := primitive.Timestamp{T: clusterTime.T, I: clusterTime.I + 1}
+	if next.I == 0 {
+		next = primitive.Timestamp{T: clusterTime.T + 1}
+	}
+
+	token, err := bson.Marshal(bson.D{{Key: "clusterTime", Value: next}})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// toWatchError marks the change stream errors caused by the lost changes with the users.ErrResumeTokenExpired.
+func toWatchError(err error) error {
+	if isHistoryLost(err) {
+		return fmt.Errorf("%w: %v", users.ErrResumeTokenExpired, err)
+	}
+
+	return err
+}
+
+// isHistoryLost checks if the change stream could not be resumed, as the oplog no longer contains the changes.
+func isHistoryLost(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && (serverErr.HasErrorCode(changeStreamHistoryLost) || serverErr.HasErrorCode(changeStreamFatalError))
+}
+
+// watchPipeline returns the change stream pipeline matching the changes of the users delivered to the watcher.
+func watchPipeline(filter users.WatchFilter) mongo.Pipeline {
+	// Assuming no other service will be writing to the database, we can use the change stream to get the changes
+	changeTypes := filter.ChangeTypes
+	if len(changeTypes) == 0 {
+		changeTypes = []string{users.ChangeInsert, users.ChangeUpdate, users.ChangeDelete}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": changeTypes}}}},
+	}
+
+	if len(filter.UserIDs) > 0 {
+		// Malformed IDs cannot belong to any user
+		ids := bson.A{}
+		for _, id := range filter.UserIDs {
+			hex, err := primitive.ObjectIDFromHex(id)
+			if err == nil {
+				ids = append(ids, hex)
+			}
+		}
+
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"documentKey._id": bson.M{"$in": ids}}}})
+	}
+
+	if len(filter.Countries) > 0 {
+		// The deletes have no full document, so they never match
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"fullDocument.country": bson.M{"$in": filter.Countries}}}})
+	}
+
+	if len(filter.ChangedFields) > 0 {
+		alternatives := bson.A{bson.M{"operationType": bson.M{"$ne": users.ChangeUpdate}}}
+		for _, field := range filter.ChangedFields {
+			alternatives = append(alternatives, bson.M{"updateDescription.updatedFields." + field: bson.M{"$exists": true}})
+		}
+
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": alternatives}}})
+	}
+
+	return pipeline
+}
+
+// toFilter returns the filter matching the query's fields and time ranges.
+func toFilter(query users.Query) (bson.M, error) {
+	filter := bson.M{}
+	if query.FirstName != nil {
+		filter["first_name"] = *query.FirstName
+	}
+
+	if query.LastName != nil {
+		filter["last_name"] = *query.LastName
+	}
+
+	if query.Nickname != nil {
+		filter["nickname"] = *query.Nickname
+	}
+
+	country := bson.M{}
+	if query.Country != nil {
+		country["$eq"] = *query.Country
+	}
+
+	if len(query.Countries) > 0 {
+		country["$in"] = query.Countries
+	}
+
+	if len(query.ExcludedCountries) > 0 {
+		country["$nin"] = query.ExcludedCountries
+	}
+
+	if len(country) > 0 {
+		filter["country"] = country
+	}
+
+	if query.Email != nil {
+		filter["email"] = *query.Email
+	}
+
+	// The time ranges include their start, but not their end
+	addTimeRange := func(key string, after, before *time.Time) {
+		timeRange := bson.M{}
+		if after != nil {
+			timeRange["$gte"] = *after
+		}
+
+		if before != nil {
+			timeRange["$lt"] = *before
+		}
+
+		if len(timeRange) > 0 {
+			filter[key] = timeRange
+		}
+	}
+
+	addTimeRange("created_at", query.CreatedAfter, query.CreatedBefore)
+	addTimeRange("updated_at", query.UpdatedAfter, query.UpdatedBefore)
+
+	expression, err := query.Expression()
+	if err != nil {
+		return nil, err
+	}
+
+	// Nested in $and, so the expression can be combined with the other operators of the filter
+	if expression != nil {
+		filter["$and"] = bson.A{toExpressionFilter(expression)}
+	}
+
+	return filter, nil
+}
+
+// toExpressionFilter compiles the filter expression to a query. The filterable fields are the names of the fields
+// of the documents.
+func toExpressionFilter(expression users.Expression) bson.M {
+	switch expression := expression.(type) {
+	case users.AndExpression:
+		return bson.M{"$and": lo.Map(expression.Expressions, func(nested users.Expression, _ int) bson.M {
+			return toExpressionFilter(nested)
+		})}
+	case users.OrExpression:
+		return bson.M{"$or": lo.Map(expression.Expressions, func(nested users.Expression, _ int) bson.M {
+			return toExpressionFilter(nested)
+		})}
+	case users.NotExpression:
+		return bson.M{"$nor": bson.A{toExpressionFilter(expression.Expression)}}
+	case users.Comparison:
+		operators := map[users.Operator]string{
+			users.OperatorEqual:          "$eq",
+			users.OperatorNotEqual:       "$ne",
+			users.OperatorLess:           "$lt",
+			users.OperatorLessOrEqual:    "$lte",
+			users.OperatorGreater:        "$gt",
+			users.OperatorGreaterOrEqual: "$gte",
+		}
+
+		return bson.M{expression.Field: bson.M{operators[expression.Operator]: expression.Value}}
+	default:
+		// Matches no users
+		return bson.M{"_id": bson.M{"$exists": false}}
+	}
+}
+
+// toSort returns the sort document for the ordering, using the id as a tiebreaker.
+func toSort(ordering users.Ordering) bson.D {
+	sort := bson.D{}
+	for _, field := range ordering {
+		sort = append(sort, bson.E{Key: field.Field, Value: direction(field.Descending)})
+	}
+
+	return append(sort, bson.E{Key: "_id", Value: direction(ordering.IDDescending())})
+}
+
+// afterCursor returns the filter matching the users listed after the cursor. The users are after the cursor if any
+// of the ordered fields is after the cursor's value, while the previous fields are equal.
+func afterCursor(ordering users.Ordering, cursor *users.Cursor) (bson.M, error) {
+	id, err := primitive.ObjectIDFromHex(cursor.ID)
+	if err != nil {
+		return nil, users.ErrInvalidPageToken
+	}
+
+	alternatives := bson.A{}
+	equal := bson.M{}
+
+	addField := func(key string, descending bool, value any) {
+		operator := "$gt"
+		if descending {
+			operator = "$lt"
+		}
+
+		after := bson.M{key: bson.M{operator: value}}
+		for equalKey, equalValue := range equal {
+			after[equalKey] = equalValue
+		}
+
+		alternatives = append(alternatives, after)
+		equal[key] = value
+	}
+
+	for i, field := range ordering {
+		addField(field.Field, field.Descending, cursor.Values[i])
+	}
+	addField("_id", ordering.IDDescending(), id)
+
+	return bson.M{"$or": alternatives}, nil
+}
+
+func direction(descending bool) int {
+	if descending {
+		return -1
+	}
+
+	return 1
+}
+
+// decodeUser decodes the user document, upgrading documents in an older schema version on read.
+// The upgraded documents are not written back, that is done by migrating the database.
+func decodeUser(raw bson.Raw) (*User, error) {
+	user := &User{}
+
+	version, ok := raw.Lookup("schema_version").AsInt64OK()
+	if ok && version >= schemaVersion {
+		return user, bson.Unmarshal(raw, user)
+	}
+
+	document := bson.M{}
+	err := bson.Unmarshal(raw, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = userMigrations.Upgrade(document)
+	if err != nil {
+		return nil, err
+	}
+
+	upgraded, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, bson.Unmarshal(upgraded, user)
+}
+
 func toEntity(user *users.User) User {
 	hex, _ := primitive.ObjectIDFromHex(user.ID)
 
 	entity := User{
-		SchemaVersion: schemaVersion,
-		FirstName:     user.FirstName,
-		LastName:      user.LastName,
-		Nickname:      user.Nickname,
-		Email:         user.Email,
-		Country:       user.Country,
+		SchemaVersion:    schemaVersion,
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		Nickname:         user.Nickname,
+		NicknameTrigrams: users.Trigrams(user.Nickname),
+		Email:            user.Email,
+		Country:          user.Country,
 	}
 	entity.SetID(hex)
 	return entity
@@ -249,8 +845,8 @@ func toEntity(user *users.User) User {
 func toUser(user *User) *users.User {
 	return &users.User{
 		ID:        user.ID.Hex(),
-		CreatedAt: user.CreatedAt.String(),
-		UpdatedAt: user.UpdatedAt.String(),
+		CreatedAt: user.CreatedAt.UTC(),
+		UpdatedAt: user.UpdatedAt.UTC(),
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
 		Nickname:  user.Nickname,
@@ -258,3 +854,27 @@ func toUser(user *User) *users.User {
 		Country:   user.Country,
 	}
 }
+
+// Close stops delivering the changes of the shared change stream and stops the watchers with their own change
+// streams, waiting until they closed their change streams.
+func (u *userRepository) Close(ctx context.Context) error {
+	u.stop()
+
+	err := u.hub.close(ctx)
+	if err != nil {
+		return err
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		u.watchers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
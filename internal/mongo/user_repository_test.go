@@ -0,0 +1,62 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/kamva/mgm/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users/repositorytest"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// TestUserRepository runs against the replica set in USER_TEST_MONGO_URI, emptying the users collection before each test.
+func TestUserRepository(t *testing.T) {
+	uri := os.Getenv("USER_TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("USER_TEST_MONGO_URI is not set")
+	}
+
+	configuration := Configuration{URI: uri}
+	Connect(configuration, zap.NewNop())
+
+	repositorytest.Run(t, func(t *testing.T) users.Repository {
+		_, err := mgm.Coll(&User{}).DeleteMany(context.Background(), bson.M{})
+		require.NoError(t, err)
+
+		return NewUserRepository(configuration, broadcast.Config{BufferSize: 100})
+	})
+}
+
+func TestClusterTimeToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		clusterTime primitive.Timestamp
+		expected    primitive.Timestamp
+	}{
+		{name: "Next operation", clusterTime: primitive.Timestamp{T: 100, I: 3}, expected: primitive.Timestamp{T: 100, I: 4}},
+		{name: "Next second", clusterTime: primitive.Timestamp{T: 100, I: math.MaxUint32}, expected: primitive.Timestamp{T: 101}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := clusterTimeToken(tt.clusterTime)
+			require.NoError(t, err)
+
+			raw, err := base64.RawURLEncoding.DecodeString(token)
+			require.NoError(t, err)
+
+			ts, i, ok := bson.Raw(raw).Lookup("clusterTime").TimestampOK()
+			require.True(t, ok)
+			assert.Equal(t, tt.expected, primitive.Timestamp{T: ts, I: i})
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+)
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid bearer token")
+)
+
+type Config struct {
+	// Enabled requires a valid bearer token on all the calls, except the public methods and paths
+	Enabled bool `yaml:"enabled" json:"enabled" mapstructure:"enabled"`
+
+	// Issuer is the required issuer of the tokens, empty accepts any issuer
+	Issuer string `yaml:"issuer" json:"issuer" mapstructure:"issuer"`
+
+	// Audience is the audience the tokens must be issued for, empty accepts any audience
+	Audience string `yaml:"audience" json:"audience" mapstructure:"audience"`
+
+	// Keys are the static keys verifying the tokens
+	Keys []Key `yaml:"keys" json:"keys" mapstructure:"keys" validate:"dive"`
+
+	// JWKSFile is the path to a local JSON Web Key Set with the keys verifying the tokens
+	JWKSFile string `yaml:"jwksFile" json:"jwksFile" mapstructure:"jwksFile"`
+
+	// Leeway is the allowed clock skew when checking the expiry of the tokens
+	Leeway time.Duration `yaml:"leeway" json:"leeway" mapstructure:"leeway" validate:"gte=0"`
+
+	// PublicMethods are the gRPC methods which can be called without a token, e.g. /grpc.health.v1.Health/Check
+	PublicMethods []string `yaml:"publicMethods" json:"publicMethods" mapstructure:"publicMethods"`
+
+	// PublicPaths are the HTTP paths which can be requested without a token, e.g. /healthz
+	PublicPaths []string `yaml:"publicPaths" json:"publicPaths" mapstructure:"publicPaths"`
+}
+
+type Key struct {
+	// ID is the key ID matching the kid header of the tokens, required when there are multiple keys
+	ID string `yaml:"id" json:"id" mapstructure:"id"`
+
+	// Secret is the secret of the HMAC signed tokens
+	Secret string `yaml:"secret" json:"secret" mapstructure:"secret"`
+
+	// PublicKey is the PEM encoded RSA, ECDSA or Ed25519 public key of the signed tokens
+	PublicKey string `yaml:"publicKey" json:"publicKey" mapstructure:"publicKey"`
+}
+
+// MarshalJSON hides the secret, so the key is not revealed when the configuration is logged.
+func (k Key) MarshalJSON() ([]byte, error) {
+	// The alias does not have the method, so it is marshaled as usual
+	type key Key
+
+	redacted := key(k)
+	if redacted.Secret != "" {
+		redacted.Secret = "REDACTED"
+	}
+
+	return json.Marshal(redacted)
+}
+
+// Authenticator verifies the bearer tokens of the callers.
+type Authenticator struct {
+	config Config
+	parser *jwt.Parser
+
+	// keys are the verification keys by their IDs
+	keys map[string]any
+}
+
+// NewAuthenticator creates the authenticator with the configured keys, or returns nil if the authentication is disabled.
+func NewAuthenticator(config Config) (*Authenticator, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	keys := map[string]any{}
+	for _, key := range config.Keys {
+		verificationKey, err := parseKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.ID, err)
+		}
+
+		keys[key.ID] = verificationKey
+	}
+
+	if config.JWKSFile != "" {
+		data, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+
+		jwks, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS file %s: %w", config.JWKSFile, err)
+		}
+
+		for id, key := range jwks {
+			keys[id] = key
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no keys to verify the tokens with")
+	}
+
+	if _, ok := keys[""]; ok && len(keys) > 1 {
+		return nil, errors.New("all the keys need an ID when there are multiple keys")
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA", "HS256", "HS384", "HS512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	return &Authenticator{
+		config: config,
+		parser: jwt.NewParser(options...),
+		keys:   keys,
+	}, nil
+}
+
+// Authenticate verifies the signature, the issuer, the audience and the expiry of the token, returning the caller
+// it was issued to.
+func (a *Authenticator) Authenticate(token string) (users.Caller, error) {
+	if token == "" {
+		return users.Caller{}, ErrMissingToken
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(token, claims, a.key)
+	if err != nil {
+		return users.Caller{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	subject, _ := claims.GetSubject()
+	issuer, _ := claims.GetIssuer()
+	return users.Caller{Subject: subject, Issuer: issuer, Claims: claims}, nil
+}
+
+// PublicMethod returns whether the gRPC method can be called without a token.
+func (a *Authenticator) PublicMethod(fullMethod string) bool {
+	return slices.Contains(a.config.PublicMethods, fullMethod)
+}
+
+// PublicPath returns whether the HTTP path can be requested without a token.
+func (a *Authenticator) PublicPath(path string) bool {
+	return slices.Contains(a.config.PublicPaths, path)
+}
+
+// key returns the key verifying the token, selected by the kid header when there are multiple keys. The signing
+// method is checked against the type of the key when verifying the signature.
+func (a *Authenticator) key(token *jwt.Token) (any, error) {
+	id, _ := token.Header["kid"].(string)
+	if key, ok := a.keys[id]; ok {
+		return key, nil
+	}
+
+	// A single key verifies the tokens without a key ID, or all the tokens if the key has no ID
+	for keyID, key := range a.keys {
+		if len(a.keys) == 1 && (id == "" || keyID == "") {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown key %q", id)
+}
+
+// BearerToken returns the token of the Authorization header value, or an empty string if it has no bearer token.
+func BearerToken(authorization string) string {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+func parseKey(key Key) (any, error) {
+	switch {
+	case key.Secret != "" && key.PublicKey != "":
+		return nil, errors.New("either the secret or the public key must be set")
+	case key.Secret != "":
+		return []byte(key.Secret), nil
+	case key.PublicKey != "":
+		block, _ := pem.Decode([]byte(key.PublicKey))
+		if block == nil {
+			return nil, errors.New("the public key is not PEM encoded")
+		}
+
+		if block.Type == "RSA PUBLIC KEY" {
+			return x509.ParsePKCS1PublicKey(block.Bytes)
+		}
+
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, errors.New("the secret or the public key must be set")
+	}
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const secret = "d7c8a1b0e5f94c3a8b6d2e1f0a9c8b7d"
+
+func claims(modify func(claims jwt.MapClaims)) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"sub": "faceit-admin",
+		"iss": "https://auth.faceit.com",
+		"aud": "user-service",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if modify != nil {
+		modify(claims)
+	}
+
+	return claims
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key any) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	return signed
+}
+
+func TestAuthenticator(t *testing.T) {
+	authenticator, err := NewAuthenticator(Config{
+		Enabled:  true,
+		Issuer:   "https://auth.faceit.com",
+		Audience: "user-service",
+		Keys:     []Key{{Secret: secret}},
+	})
+	require.NoError(t, err)
+
+	caller, err := authenticator.Authenticate(sign(t, jwt.SigningMethodHS256, "", claims(nil), []byte(secret)))
+	require.NoError(t, err)
+	assert.Equal(t, "faceit-admin", caller.Subject)
+	assert.Equal(t, "https://auth.faceit.com", caller.Issuer)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "Expired", token: sign(t, jwt.SigningMethodHS256, "", claims(func(claims jwt.MapClaims) {
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		}), []byte(secret))},
+		{name: "No expiry", token: sign(t, jwt.SigningMethodHS256, "", claims(func(claims jwt.MapClaims) {
+			delete(claims, "exp")
+		}), []byte(secret))},
+		{name: "Other issuer", token: sign(t, jwt.SigningMethodHS256, "", claims(func(claims jwt.MapClaims) {
+			claims["iss"] = "https://auth.example.com"
+		}), []byte(secret))},
+		{name: "Other audience", token: sign(t, jwt.SigningMethodHS256, "", claims(func(claims jwt.MapClaims) {
+			claims["aud"] = "match-service"
+		}), []byte(secret))},
+		{name: "Other secret", token: sign(t, jwt.SigningMethodHS256, "", claims(nil), []byte("secret"))},
+		{name: "Other key type", token: sign(t, jwt.SigningMethodRS256, "", claims(nil), rsaKey)},
+		{name: "Malformed", token: "token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := authenticator.Authenticate(tt.token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+
+	t.Run("Missing token", func(t *testing.T) {
+		_, err := authenticator.Authenticate("")
+		assert.ErrorIs(t, err, ErrMissingToken)
+	})
+}
+
+func TestAuthenticator_Keys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	encoded, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	rsaPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: encoded}))
+
+	// The JWKS file contains the EC key and a HMAC secret
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kid": "ec", "kty": "EC", "crv": "P-256", "x": encodeInt(ecKey.X), "y": encodeInt(ecKey.Y), "use": "sig"},
+		{"kid": "hmac", "kty": "oct", "k": base64.RawURLEncoding.EncodeToString([]byte(secret))},
+		{"kid": "encryption", "kty": "RSA", "use": "enc"},
+	}})
+	require.NoError(t, err)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0o600))
+
+	authenticator, err := NewAuthenticator(Config{
+		Enabled:  true,
+		Keys:     []Key{{ID: "rsa", PublicKey: rsaPEM}},
+		JWKSFile: jwksFile,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "RSA", token: sign(t, jwt.SigningMethodRS256, "rsa", claims(nil), rsaKey), valid: true},
+		{name: "EC", token: sign(t, jwt.SigningMethodES256, "ec", claims(nil), ecKey), valid: true},
+		{name: "HMAC", token: sign(t, jwt.SigningMethodHS256, "hmac", claims(nil), []byte(secret)), valid: true},
+		{name: "Other key", token: sign(t, jwt.SigningMethodRS256, "ec", claims(nil), rsaKey)},
+		{name: "Unknown key", token: sign(t, jwt.SigningMethodRS256, "unknown", claims(nil), rsaKey)},
+		{name: "No key ID", token: sign(t, jwt.SigningMethodRS256, "", claims(nil), rsaKey)},
+		{name: "Encryption key", token: sign(t, jwt.SigningMethodRS256, "encryption", claims(nil), rsaKey)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := authenticator.Authenticate(tt.token)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	authenticator, err := NewAuthenticator(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, authenticator, "the authentication is disabled")
+
+	_, err = NewAuthenticator(Config{Enabled: true})
+	assert.Error(t, err, "no keys")
+
+	_, err = NewAuthenticator(Config{Enabled: true, Keys: []Key{{PublicKey: "key"}}})
+	assert.Error(t, err, "not PEM encoded")
+
+	_, err = NewAuthenticator(Config{Enabled: true, Keys: []Key{{Secret: secret}, {ID: "other", Secret: secret}}})
+	assert.Error(t, err, "missing key ID")
+
+	_, err = NewAuthenticator(Config{Enabled: true, JWKSFile: filepath.Join(t.TempDir(), "jwks.json")})
+	assert.Error(t, err, "missing JWKS file")
+}
+
+func TestKey_MarshalJSON(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(output), zap.InfoLevel))
+
+	logger.Info("Loaded the configuration", zap.Any("config", Config{Keys: []Key{{ID: "hmac", Secret: secret}, {ID: "rsa", PublicKey: "key"}}}))
+	assert.NotContains(t, output.String(), secret)
+	assert.Contains(t, output.String(), `{"id":"hmac","secret":"REDACTED","publicKey":""}`)
+	assert.Contains(t, output.String(), `{"id":"rsa","secret":"","publicKey":"key"}`)
+}
+
+func TestBearerToken(t *testing.T) {
+	assert.Equal(t, "token", BearerToken("Bearer token"))
+	assert.Equal(t, "token", BearerToken("bearer token"))
+	assert.Empty(t, BearerToken("Basic dXNlcjpwYXNzd29yZA=="))
+	assert.Empty(t, BearerToken("token"))
+}
+
+func encodeInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
@@ -0,0 +1,257 @@
+package broadcast
+
+import (
+	"context"
+	"sync"
+)
+
+// queueSize is the number of published values waiting to be delivered, before publishing waits for the deliveries.
+const queueSize = 1024
+
+// Policy decides what happens to a subscriber whose buffer is full when a value is delivered.
+type Policy string
+
+const (
+	// PolicyBlock waits until the subscriber receives the value, holding up the deliveries to all the subscribers.
+	PolicyBlock = Policy("block")
+
+	// PolicyDrop skips the value for the subscriber.
+	PolicyDrop = Policy("drop")
+
+	// PolicyDisconnect removes the subscriber and closes its channel.
+	PolicyDisconnect = Policy("disconnect")
+)
+
+type Config struct {
+	// BufferSize is the number of values buffered for each subscriber
+	BufferSize int `yaml:"bufferSize" json:"bufferSize" mapstructure:"bufferSize" validate:"gte=0"`
+
+	// SlowConsumerPolicy decides what happens to the subscribers with a full buffer, blocking by default
+	SlowConsumerPolicy Policy `yaml:"slowConsumerPolicy" json:"slowConsumerPolicy" mapstructure:"slowConsumerPolicy" validate:"omitempty,oneof=block drop disconnect"`
+}
+
+type subscriber[T any] struct {
+	ctx context.Context
+
+	// mu guards the events channel, so it is not closed while an event is being sent.
+	mu     sync.Mutex
+	events chan T
+	closed bool
+}
+
+// delivery is a published value, along with the subscribers at the time it was published.
+type delivery[T any] struct {
+	value       T
+	subscribers []*subscriber[T]
+}
+
+// Broadcaster delivers published values to all the subscribers in the order they were published.
+// The values are queued and delivered by a single dispatching goroutine, so publishing only waits once
+// the queue is full, which only happens while the subscribers are holding up the deliveries with the PolicyBlock.
+// Values are only delivered to the subscribers subscribed before the value was published.
+type Broadcaster[T any] struct {
+	bufferSize int
+	policy     Policy
+
+	// mu guards the queue of values waiting to be delivered, while the slots limit its length.
+	mu    sync.Mutex
+	queue []delivery[T]
+	slots chan struct{}
+	wake  chan struct{}
+
+	// done is closed once the broadcaster is closed, stopping the dispatching goroutine.
+	done      chan struct{}
+	closeOnce sync.Once
+	stopped   chan struct{}
+
+	subscribersMu sync.Mutex
+	subscribers   map[*subscriber[T]]struct{}
+}
+
+// New creates a Broadcaster, buffering up to the configured number of values for each subscriber. Once a
+// subscriber's buffer is full, the delivery follows the SlowConsumerPolicy.
+func New[T any](cfg Config) *Broadcaster[T] {
+	policy := cfg.SlowConsumerPolicy
+	if policy == "" {
+		policy = PolicyBlock
+	}
+
+	b := &Broadcaster[T]{
+		bufferSize:  cfg.BufferSize,
+		policy:      policy,
+		slots:       make(chan struct{}, queueSize),
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+		subscribers: map[*subscriber[T]]struct{}{},
+	}
+
+	go b.dispatch()
+
+	return b
+}
+
+// Subscribe registers a new subscriber, which is removed and its channel closed when the context is done or the
+// broadcaster is closed. With the PolicyDisconnect, the channel is also closed once the subscriber falls behind.
+func (b *Broadcaster[T]) Subscribe(ctx context.Context) <-chan T {
+	sub := &subscriber[T]{
+		ctx:    ctx,
+		events: make(chan T, b.bufferSize),
+	}
+
+	b.subscribersMu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.subscribersMu.Unlock()
+	subscribersGauge.Inc()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-b.done:
+		}
+
+		b.unsubscribe(sub)
+	}()
+
+	return sub.events
+}
+
+// unsubscribe removes the subscriber and closes its channel, unless it was closed already.
+func (b *Broadcaster[T]) unsubscribe(sub *subscriber[T]) {
+	b.subscribersMu.Lock()
+	_, subscribed := b.subscribers[sub]
+	delete(b.subscribers, sub)
+	b.subscribersMu.Unlock()
+
+	if subscribed {
+		subscribersGauge.Dec()
+	}
+
+	sub.mu.Lock()
+	if !sub.closed {
+		sub.closed = true
+		close(sub.events)
+	}
+	sub.mu.Unlock()
+}
+
+// Publish queues the value for delivery to all the current subscribers, waiting while the queue is full.
+// The values published after closing the broadcaster are discarded.
+func (b *Broadcaster[T]) Publish(value T) {
+	select {
+	case b.slots <- struct{}{}:
+	case <-b.done:
+		return
+	}
+
+	b.subscribersMu.Lock()
+	subs := make([]*subscriber[T], 0, len(b.subscribers))
+	for sub := range b.subscribers {
+		subs = append(subs, sub)
+	}
+	b.subscribersMu.Unlock()
+
+	b.mu.Lock()
+	b.queue = append(b.queue, delivery[T]{value: value, subscribers: subs})
+	b.mu.Unlock()
+
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops delivering the values and closes the channels of all the subscribers. The values which were not
+// delivered yet are discarded. Returns the context's error if the delivery in progress did not stop in time.
+func (b *Broadcaster[T]) Close(ctx context.Context) error {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+
+	select {
+	case <-b.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Broadcaster[T]) dispatch() {
+	defer close(b.stopped)
+
+	for {
+		select {
+		case <-b.wake:
+		case <-b.done:
+			return
+		}
+
+		for {
+			b.mu.Lock()
+			batch := b.queue
+			b.queue = nil
+			b.mu.Unlock()
+
+			if len(batch) == 0 {
+				break
+			}
+
+			for _, delivery := range batch {
+				select {
+				case <-b.done:
+					return
+				default:
+				}
+
+				b.deliver(delivery)
+				<-b.slots
+			}
+		}
+	}
+}
+
+func (b *Broadcaster[T]) deliver(delivery delivery[T]) {
+	for _, sub := range delivery.subscribers {
+		if !b.send(sub, delivery.value) {
+			// The subscriber fell behind the other subscribers
+			disconnectedCounter.Inc()
+			b.unsubscribe(sub)
+		}
+	}
+}
+
+// send delivers the value to the subscriber following the policy. Returns false if the subscriber should be disconnected.
+func (b *Broadcaster[T]) send(sub *subscriber[T], value T) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if sub.closed {
+		return true
+	}
+
+	defer func() {
+		bufferDepthHistogram.Observe(float64(len(sub.events)))
+	}()
+
+	select {
+	case sub.events <- value:
+		return true
+	default:
+	}
+
+	switch b.policy {
+	case PolicyDrop:
+		droppedCounter.Inc()
+		return true
+	case PolicyDisconnect:
+		return false
+	default:
+		select {
+		case sub.events <- value:
+		case <-sub.ctx.Done():
+		case <-b.done:
+		}
+
+		return true
+	}
+}
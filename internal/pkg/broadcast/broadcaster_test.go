@@ -0,0 +1,94 @@
+package broadcast
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcaster_SlowConsumerPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		expected []int
+	}{
+		{name: "Block", policy: PolicyBlock, expected: []int{1, 2, 3}},
+		{name: "Drop", policy: PolicyDrop, expected: []int{1}},
+		{name: "Disconnect", policy: PolicyDisconnect, expected: []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			b := New[int](Config{BufferSize: 1, SlowConsumerPolicy: tt.policy})
+			defer b.Close(context.Background())
+
+			values := b.Subscribe(ctx)
+
+			// Only the first value fits in the buffer until the subscriber starts receiving
+			b.Publish(1)
+			b.Publish(2)
+			b.Publish(3)
+			time.Sleep(100 * time.Millisecond)
+
+			received := []int{}
+			for len(received) < len(tt.expected) {
+				select {
+				case value := <-values:
+					received = append(received, value)
+				case <-time.After(time.Second):
+					t.Fatalf("timed out waiting for a value, received %v", received)
+				}
+			}
+			assert.Equal(t, tt.expected, received)
+
+			select {
+			case value, ok := <-values:
+				if tt.policy == PolicyDisconnect {
+					assert.False(t, ok, "the subscriber was not disconnected")
+				} else {
+					t.Errorf("unexpected value %d", value)
+				}
+			case <-time.After(100 * time.Millisecond):
+				assert.NotEqual(t, PolicyDisconnect, tt.policy, "the subscriber was not disconnected")
+			}
+		})
+	}
+}
+
+func TestBroadcaster_Close(t *testing.T) {
+	b := New[int](Config{BufferSize: 1})
+	values := b.Subscribe(context.Background())
+
+	// The subscriber never receives, so the deliveries are held up until the queue is full
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < queueSize+10; i++ {
+			b.Publish(i)
+		}
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("publishing did not wait for the full queue")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(t, b.Close(context.Background()))
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publishing did not stop after closing")
+	}
+
+	// The buffered value is still received before the channel is closed
+	<-values
+	_, ok := <-values
+	assert.False(t, ok, "the subscriber was not closed")
+}
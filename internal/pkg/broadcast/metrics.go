@@ -0,0 +1,30 @@
+package broadcast
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The metrics are shared by all the broadcasters of the process.
+var (
+	subscribersGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "broadcast_subscribers",
+		Help: "Number of the current subscribers.",
+	})
+
+	bufferDepthHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "broadcast_buffer_depth",
+		Help:    "Number of the values in a subscriber's buffer after a delivery.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 11),
+	})
+
+	droppedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "broadcast_dropped_values_total",
+		Help: "Number of the values dropped for the subscribers with a full buffer.",
+	})
+
+	disconnectedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "broadcast_disconnected_subscribers_total",
+		Help: "Number of the subscribers disconnected because of a full buffer.",
+	})
+)
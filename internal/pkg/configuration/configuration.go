@@ -45,8 +45,34 @@ func SetupEnv(cfgEngine *viper.Viper, serviceName string) {
 
 // SetDefaults sets the default values for the app configuration.
 func SetDefaults(cfgEngine *viper.Viper, serviceName string) {
+	cfgEngine.SetDefault("database.type", "mongo")
 	cfgEngine.SetDefault("database.uri", "")
+	cfgEngine.SetDefault("database.path", "users.db")
+	cfgEngine.SetDefault("database.changeStreamImages", false)
+	cfgEngine.SetDefault("database.maxConnections", 20)
+	cfgEngine.SetDefault("stats.cacheInterval", "1m")
+	cfgEngine.SetDefault("watch.bufferSize", 100)
+	cfgEngine.SetDefault("watch.slowConsumerPolicy", "block")
+	cfgEngine.SetDefault("watch.heartbeatInterval", "30s")
 	cfgEngine.SetDefault("server", ":8080")
+	cfgEngine.SetDefault("grpc.keepalive.time", "1m")
+	cfgEngine.SetDefault("grpc.keepalive.timeout", "20s")
+	cfgEngine.SetDefault("grpc.keepalive.maxConnectionIdle", "0s")
+	cfgEngine.SetDefault("grpc.keepalive.minTime", "10s")
+	cfgEngine.SetDefault("grpc.keepalive.permitWithoutStream", true)
+	cfgEngine.SetDefault("grpc.web.address", ":8081")
+	cfgEngine.SetDefault("grpc.reflection", false)
+	cfgEngine.SetDefault("grpc.health.checkInterval", "10s")
+	cfgEngine.SetDefault("auth.enabled", false)
+	cfgEngine.SetDefault("auth.issuer", "")
+	cfgEngine.SetDefault("auth.audience", "")
+	cfgEngine.SetDefault("auth.jwksFile", "")
+	cfgEngine.SetDefault("auth.leeway", "1m")
+	cfgEngine.SetDefault("auth.publicMethods", []string{"/grpc.health.v1.Health/Check", "/grpc.health.v1.Health/Watch", "/grpc.health.v1.Health/List"})
+	cfgEngine.SetDefault("auth.publicPaths", []string{"/healthz", "/metrics", "/openapi.json"})
+	cfgEngine.SetDefault("cors.allowedOrigins", []string{})
+	cfgEngine.SetDefault("cors.allowedHeaders", []string{})
+	cfgEngine.SetDefault("cors.maxAge", "10m")
 }
 
 // InitConfig initializes the configuration for the service.
@@ -0,0 +1,42 @@
+package cors
+
+import (
+	"net/http"
+	"time"
+
+	connectcors "connectrpc.com/cors"
+	rscors "github.com/rs/cors"
+)
+
+type Config struct {
+	// AllowedOrigins are the origins of the browsers allowed to call the APIs, e.g. https://faceit.com, or * for any
+	// origin. No origins disable the cross-origin requests.
+	AllowedOrigins []string `yaml:"allowedOrigins" json:"allowedOrigins" mapstructure:"allowedOrigins"`
+
+	// AllowedHeaders are the request headers allowed besides the headers of the protocols and the Authorization header
+	AllowedHeaders []string `yaml:"allowedHeaders" json:"allowedHeaders" mapstructure:"allowedHeaders"`
+
+	// MaxAge is how long the browsers cache the preflight responses
+	MaxAge time.Duration `yaml:"maxAge" json:"maxAge" mapstructure:"maxAge" validate:"gte=0"`
+}
+
+// Handler allows the configured origins to call the handler from the browsers, using the REST, gRPC-Web and Connect
+// protocols. The handler is returned unchanged if no origins are allowed.
+func Handler(cfg Config, handler http.Handler) http.Handler {
+	if len(cfg.AllowedOrigins) == 0 {
+		return handler
+	}
+
+	methods := append(connectcors.AllowedMethods(), http.MethodPut, http.MethodPatch, http.MethodDelete)
+	headers := append(connectcors.AllowedHeaders(), "Authorization")
+	headers = append(headers, cfg.AllowedHeaders...)
+	options := rscors.Options{
+		AllowedOrigins: cfg.AllowedOrigins,
+		AllowedMethods: methods,
+		AllowedHeaders: headers,
+		ExposedHeaders: connectcors.ExposedHeaders(),
+		MaxAge:         int(cfg.MaxAge.Seconds()),
+	}
+
+	return rscors.New(options).Handler(handler)
+}
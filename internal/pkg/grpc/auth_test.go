@@ -0,0 +1,117 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	usergrpc "github.com/xBlaz3kx/faceit-task/internal/grpc"
+	"github.com/xBlaz3kx/faceit-task/internal/memory"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/auth"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testSecret = "d7c8a1b0e5f94c3a8b6d2e1f0a9c8b7d"
+
+func newTestAuthenticator(t *testing.T) *auth.Authenticator {
+	t.Helper()
+
+	authenticator, err := auth.NewAuthenticator(auth.Config{
+		Enabled:       true,
+		Keys:          []auth.Key{{Secret: testSecret}},
+		PublicMethods: []string{"/grpc.health.v1.Health/Check"},
+	})
+	require.NoError(t, err)
+
+	return authenticator
+}
+
+func testToken(t *testing.T) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "faceit-admin",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+
+	return token
+}
+
+func TestAuthInterceptors(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer(Configuration{}, newTestAuthenticator(t))
+	repository := memory.NewUserRepository(broadcast.Config{BufferSize: 100})
+	t.Cleanup(func() {
+		_ = repository.Close(context.Background())
+	})
+	service := users.NewUserService(repository, 0)
+	usergrpc.RegisterUserServer(server, usergrpc.NewUserGrpcHandler(service, 0))
+
+	go func() {
+		_ = server.server.Serve(listener)
+	}()
+	t.Cleanup(server.server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+
+	client := usergrpc.NewUserClient(conn)
+	authenticated := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+testToken(t))
+
+	_, err = client.GetUsers(ctx, &usergrpc.ListUsersRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.GetUsers(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer token"), &usergrpc.ListUsersRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.GetUsers(authenticated, &usergrpc.ListUsersRequest{})
+	assert.NoError(t, err)
+
+	t.Run("Stream", func(t *testing.T) {
+		stream, err := client.Watch(ctx, &usergrpc.WatchRequest{})
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Public method", func(t *testing.T) {
+		_, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		assert.NoError(t, err)
+	})
+}
+
+func TestUnaryAuthInterceptor_Caller(t *testing.T) {
+	interceptor := UnaryAuthInterceptor(newTestAuthenticator(t))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+testToken(t)))
+
+	var caller users.Caller
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.User/GetUsers"}, func(ctx context.Context, _ any) (any, error) {
+		caller, _ = users.CallerFromContext(ctx)
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "faceit-admin", caller.Subject)
+}
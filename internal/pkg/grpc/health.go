@@ -0,0 +1,54 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/tavsec/gin-healthcheck/checks"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type HealthConfig struct {
+	// CheckInterval is how often the dependencies of the services, e.g. the database, are checked. Zero only checks
+	// them when starting the server.
+	CheckInterval time.Duration `yaml:"checkInterval" json:"checkInterval" mapstructure:"checkInterval" validate:"gte=0"`
+}
+
+// watchHealth updates the serving status of the server and its services from the health checks every check interval,
+// until the context is canceled.
+func (s *Server) watchHealth(ctx context.Context, interval time.Duration, healthChecks []checks.Check) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.updateHealth(healthChecks)
+		}
+	}
+}
+
+// updateHealth sets the serving status of the server and its services to NOT_SERVING while any health check fails.
+func (s *Server) updateHealth(healthChecks []checks.Check) {
+	status := grpc_health_v1.HealthCheckResponse_SERVING
+	for _, check := range healthChecks {
+		if !check.Pass() {
+			s.logger.Warn("Health check failed", zap.String("check", check.Name()))
+			status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		}
+	}
+
+	if status != s.status {
+		s.logger.Info("Serving status changed", zap.Stringer("status", status))
+		s.status = status
+	}
+
+	// The overall status of the server is named by the empty service
+	s.health.SetServingStatus("", status)
+	for _, service := range s.services {
+		s.health.SetServingStatus(service, status)
+	}
+}
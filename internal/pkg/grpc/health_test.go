@@ -0,0 +1,76 @@
+package grpc
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	usergrpc "github.com/xBlaz3kx/faceit-task/internal/grpc"
+	"github.com/xBlaz3kx/faceit-task/internal/memory"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type testCheck struct {
+	pass atomic.Bool
+}
+
+func (c *testCheck) Pass() bool {
+	return c.pass.Load()
+}
+
+func (c *testCheck) Name() string {
+	return "database"
+}
+
+func servingStatus(t *testing.T, server *Server, service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	t.Helper()
+
+	response, err := server.health.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+
+	return response.GetStatus()
+}
+
+func TestServer_Health(t *testing.T) {
+	server := NewServer(Configuration{Health: HealthConfig{CheckInterval: 10 * time.Millisecond}}, nil)
+	repository := memory.NewUserRepository(broadcast.Config{BufferSize: 100})
+	t.Cleanup(func() {
+		_ = repository.Close(context.Background())
+	})
+	service := users.NewUserService(repository, 0)
+	usergrpc.RegisterUserServer(server, usergrpc.NewUserGrpcHandler(service, 0))
+
+	check := &testCheck{}
+	check.pass.Store(true)
+	server.Start("127.0.0.1:0", check)
+
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, servingStatus(t, server, ""))
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, servingStatus(t, server, "user.User"))
+
+	// The database is down
+	check.pass.Store(false)
+	assert.Eventually(t, func() bool {
+		return servingStatus(t, server, "user.User") == grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}, eventTimeout, 10*time.Millisecond)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, servingStatus(t, server, ""))
+
+	check.pass.Store(true)
+	assert.Eventually(t, func() bool {
+		return servingStatus(t, server, "") == grpc_health_v1.HealthCheckResponse_SERVING
+	}, eventTimeout, 10*time.Millisecond)
+
+	// The server is not serving while shutting down
+	server.Stop()
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, servingStatus(t, server, ""))
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, servingStatus(t, server, "user.User"))
+}
+
+func TestServer_Reflection(t *testing.T) {
+	assert.NotContains(t, NewServer(Configuration{}, nil).server.GetServiceInfo(), "grpc.reflection.v1.ServerReflection")
+	assert.Contains(t, NewServer(Configuration{Reflection: true}, nil).server.GetServiceInfo(), "grpc.reflection.v1.ServerReflection")
+}
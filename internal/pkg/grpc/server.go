@@ -1,25 +1,77 @@
 package grpc
 
 import (
+	"context"
+	"errors"
 	"net"
+	"net/http"
 	"runtime/debug"
+	"time"
 
 	grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
 	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
+	"github.com/tavsec/gin-healthcheck/checks"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/auth"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/cors"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 )
 
+type Configuration struct {
+	// Keepalive configures the pings on the connections and the pings accepted from the clients
+	Keepalive KeepaliveConfig `yaml:"keepalive" json:"keepalive" mapstructure:"keepalive"`
+
+	// Web configures the listener of the gRPC-Web and Connect requests
+	Web WebConfig `yaml:"web" json:"web" mapstructure:"web"`
+
+	// Reflection exposes the services and their messages, so the clients like grpcurl can call them without the proto files
+	Reflection bool `yaml:"reflection" json:"reflection" mapstructure:"reflection"`
+
+	// Health configures the health checks reported by the gRPC health service
+	Health HealthConfig `yaml:"health" json:"health" mapstructure:"health"`
+}
+
+type KeepaliveConfig struct {
+	// Time is how long a connection can be idle before the server pings the client, zero uses the gRPC default (2h)
+	Time time.Duration `yaml:"time" json:"time" mapstructure:"time" validate:"gte=0"`
+
+	// Timeout is how long the server waits for the ping to be acknowledged before closing the connection
+	Timeout time.Duration `yaml:"timeout" json:"timeout" mapstructure:"timeout" validate:"gte=0"`
+
+	// MaxConnectionIdle is how long a connection without any calls is kept open, zero keeps it open indefinitely
+	MaxConnectionIdle time.Duration `yaml:"maxConnectionIdle" json:"maxConnectionIdle" mapstructure:"maxConnectionIdle" validate:"gte=0"`
+
+	// MinTime is the minimum interval between the client pings, the clients pinging more often are disconnected
+	MinTime time.Duration `yaml:"minTime" json:"minTime" mapstructure:"minTime" validate:"gte=0"`
+
+	// PermitWithoutStream allows the clients to ping while there are no active calls
+	PermitWithoutStream bool `yaml:"permitWithoutStream" json:"permitWithoutStream" mapstructure:"permitWithoutStream"`
+}
+
 type Server struct {
-	logger *zap.Logger
-	server *grpc.Server
+	logger        *zap.Logger
+	configuration Configuration
+	server        *grpc.Server
+	web           *http.Server
+	webHandler    *webHandler
+	webCancel     context.CancelFunc
+
+	// health reports the serving status of the server and the registered services
+	health       *health.Server
+	status       grpc_health_v1.HealthCheckResponse_ServingStatus
+	services     []string
+	healthCancel context.CancelFunc
+	healthDone   chan struct{}
 }
 
-func NewServer() *Server {
+// NewServer creates the gRPC server. The calls are authenticated by the authenticator, unless it is nil.
+func NewServer(configuration Configuration, authenticator *auth.Authenticator) *Server {
 	logger := zap.L().Named("grpc-server")
 
 	// Create a GRPC server with recovery and logger interceptor
@@ -28,36 +80,73 @@ func NewServer() *Server {
 		return status.Errorf(codes.Internal, "%s", p)
 	}
 
+	// Logger and recovery interceptors, followed by the authentication
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		grpc_zap.UnaryServerInterceptor(logger),
+		grpc_recovery.UnaryServerInterceptor(grpc_recovery.WithRecoveryHandler(recoveryHandler)),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		grpc_zap.StreamServerInterceptor(logger),
+		grpc_recovery.StreamServerInterceptor(grpc_recovery.WithRecoveryHandler(recoveryHandler)),
+	}
+	if authenticator != nil {
+		unaryInterceptors = append(unaryInterceptors, UnaryAuthInterceptor(authenticator))
+		streamInterceptors = append(streamInterceptors, StreamAuthInterceptor(authenticator))
+	}
+
 	server := grpc.NewServer(
-		// Logger and recovery unary interceptors
-		grpc.ChainUnaryInterceptor(
-			grpc_zap.UnaryServerInterceptor(logger),
-			grpc_recovery.UnaryServerInterceptor(grpc_recovery.WithRecoveryHandler(recoveryHandler)),
-		),
-		// Logger and recovery stream interceptors
-		grpc.ChainStreamInterceptor(
-			grpc_zap.StreamServerInterceptor(logger),
-			grpc_recovery.StreamServerInterceptor(grpc_recovery.WithRecoveryHandler(recoveryHandler)),
-		),
+		// Keep the idle connections and the long-lived streams alive behind the proxies and load balancers
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:              configuration.Keepalive.Time,
+			Timeout:           configuration.Keepalive.Timeout,
+			MaxConnectionIdle: configuration.Keepalive.MaxConnectionIdle,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             configuration.Keepalive.MinTime,
+			PermitWithoutStream: configuration.Keepalive.PermitWithoutStream,
+		}),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
 	)
 
-	// Register the healthcheck
-	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	// Register the healthcheck, serving until the first health checks
+	healthServer := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+
+	if configuration.Reflection {
+		reflection.Register(server)
+	}
 
 	return &Server{
-		logger: logger,
-		server: server,
+		logger:        logger,
+		configuration: configuration,
+		server:        server,
+		webHandler:    newWebHandler(server),
+		health:        healthServer,
 	}
 }
 
 func (s *Server) RegisterService(service *grpc.ServiceDesc, impl interface{}) {
 	s.server.RegisterService(service, impl)
+	s.services = append(s.services, service.ServiceName)
 }
 
-// Start starts the gRPC server, listening on the provided address
-func (s *Server) Start(address string) {
+// Start starts the gRPC server, listening on the provided address. The server and the registered services are
+// reported as NOT_SERVING by the health service while any of the health checks fails.
+func (s *Server) Start(address string, healthChecks ...checks.Check) {
 	s.logger.Info("Starting gRPC server")
 
+	s.updateHealth(healthChecks)
+	if s.configuration.Health.CheckInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.healthCancel = cancel
+		s.healthDone = make(chan struct{})
+		go func() {
+			defer close(s.healthDone)
+			s.watchHealth(ctx, s.configuration.Health.CheckInterval, healthChecks)
+		}()
+	}
+
 	lis, err := net.Listen("tcp", address)
 	if err != nil {
 		s.logger.Fatal("failed to listen", zap.Error(err))
@@ -70,8 +159,50 @@ func (s *Server) Start(address string) {
 	}()
 }
 
-// Stop gracefully shuts down the gRPC server
+// StartWeb starts serving the gRPC-Web and Connect requests on the provided address, handled by the same services
+// and interceptors as the gRPC requests. The browsers from the configured origins are allowed to call the services.
+func (s *Server) StartWeb(address string, corsCfg cors.Config) {
+	s.logger.Info("Starting gRPC-Web and Connect server")
+
+	// The requests are canceled when the server is stopped, including the streams over the hijacked HTTP/2 connections
+	ctx, cancel := context.WithCancel(context.Background())
+	s.webCancel = cancel
+	s.web = &http.Server{
+		Addr:        address,
+		Handler:     cors.Handler(corsCfg, s.webHandler.Handler()),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		if err := s.web.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Fatal("failed to serve gRPC-Web and Connect", zap.Error(err))
+		}
+	}()
+}
+
+// Stop gracefully shuts down the gRPC server, reporting it as NOT_SERVING while the calls finish
 func (s *Server) Stop() {
+	s.health.Shutdown()
+	if s.healthCancel != nil {
+		s.healthCancel()
+		<-s.healthDone
+	}
+
+	if s.web != nil {
+		s.logger.Info("Shutting down gRPC-Web and Connect server")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		// Cancel the remaining streams, as the gRPC server cannot stop while serving the forwarded requests
+		if err := s.web.Shutdown(ctx); err != nil {
+			_ = s.web.Close()
+		}
+		s.webCancel()
+		s.webHandler.Wait()
+	}
+	s.webHandler.Close()
+
 	s.logger.Info("Gracefully shutting down gRPC server")
 	s.server.GracefulStop()
 }
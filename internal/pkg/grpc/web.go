@@ -0,0 +1,364 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"connectrpc.com/connect"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxMessageSize is the largest message accepted from the web clients, matching the default limit of the gRPC server.
+const maxMessageSize = 4 << 20
+
+type WebConfig struct {
+	// Address is the address of the HTTP listener serving the gRPC-Web and Connect requests, empty disables it
+	Address string `yaml:"address" json:"address" mapstructure:"address"`
+}
+
+// webHandler serves the gRPC-Web and Connect requests using the Connect handlers, which forward the calls to the gRPC
+// server over an in-process connection, so they are handled by the same handlers and interceptors as the native gRPC
+// requests.
+type webHandler struct {
+	server *grpc.Server
+
+	// The services are registered after the handler is created, so the Connect handlers are created with the first use
+	init     sync.Once
+	mux      *http.ServeMux
+	listener *pipeListener
+	conn     *grpc.ClientConn
+
+	// requests are the requests being served, which must finish before the server is stopped
+	requests sync.WaitGroup
+}
+
+func newWebHandler(server *grpc.Server) *webHandler {
+	return &webHandler{server: server}
+}
+
+// Handler serves HTTP/1.1 as well as HTTP/2 without TLS, so the native gRPC clients can use the listener as well.
+// Must be called once all the services are registered.
+func (h *webHandler) Handler() http.Handler {
+	h.init.Do(h.start)
+	return h2c.NewHandler(h, &http2.Server{})
+}
+
+// Wait waits for the requests being served to finish.
+func (h *webHandler) Wait() {
+	h.requests.Wait()
+}
+
+// Close closes the in-process connection to the gRPC server, so the server can stop.
+func (h *webHandler) Close() {
+	if h.conn != nil {
+		_ = h.conn.Close()
+		_ = h.listener.Close()
+	}
+}
+
+func (h *webHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.requests.Add(1)
+	defer h.requests.Done()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The Connect handlers serve the gRPC-Web and Connect requests, rejecting the unsupported content types
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc-web-text"):
+		serveText(h.mux, w, r)
+	case strings.HasPrefix(contentType, "application/grpc-web"), !strings.HasPrefix(contentType, "application/grpc"):
+		h.mux.ServeHTTP(w, r)
+	default:
+		h.server.ServeHTTP(w, r)
+	}
+}
+
+// start creates the Connect handlers of the unary and server streaming methods of all the registered services, along
+// with the in-process connection the calls are forwarded over.
+func (h *webHandler) start() {
+	h.listener = newPipeListener()
+	go func() {
+		// The listener is closed once the server stops
+		_ = h.server.Serve(h.listener)
+	}()
+
+	// The connection is only created, it never fails
+	h.conn, _ = grpc.NewClient("passthrough:///in-process",
+		grpc.WithContextDialer(h.listener.dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	h.mux = http.NewServeMux()
+	for serviceName, info := range h.server.GetServiceInfo() {
+		descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(serviceName))
+		if err != nil {
+			continue
+		}
+
+		service, ok := descriptor.(protoreflect.ServiceDescriptor)
+		if !ok {
+			continue
+		}
+
+		for _, methodInfo := range info.Methods {
+			method := service.Methods().ByName(protoreflect.Name(methodInfo.Name))
+			if method == nil || method.IsStreamingClient() {
+				continue
+			}
+
+			procedure := "/" + serviceName + "/" + methodInfo.Name
+			options := []connect.HandlerOption{
+				connect.WithSchema(method),
+				connect.WithRequestInitializer(initializeRequest),
+				connect.WithReadMaxBytes(maxMessageSize),
+			}
+
+			if method.IsStreamingServer() {
+				h.mux.Handle(procedure, connect.NewServerStreamHandler(procedure, h.forwardStream(procedure, method), options...))
+			} else {
+				h.mux.Handle(procedure, connect.NewUnaryHandler(procedure, h.forwardUnary(procedure, method), options...))
+			}
+		}
+	}
+}
+
+// forwardUnary returns the handler forwarding the unary calls of the method to the gRPC server.
+func (h *webHandler) forwardUnary(procedure string, method protoreflect.MethodDescriptor) func(context.Context, *connect.Request[dynamicpb.Message]) (*connect.Response[dynamicpb.Message], error) {
+	return func(ctx context.Context, request *connect.Request[dynamicpb.Message]) (*connect.Response[dynamicpb.Message], error) {
+		var header, trailer metadata.MD
+
+		message := dynamicpb.NewMessage(method.Output())
+		err := h.conn.Invoke(outgoingContext(ctx, request.Header()), procedure, request.Msg, message, grpc.Header(&header), grpc.Trailer(&trailer))
+		if err != nil {
+			return nil, toConnectError(err, header, trailer)
+		}
+
+		response := connect.NewResponse(message)
+		copyMetadata(response.Header(), header)
+		copyMetadata(response.Trailer(), trailer)
+		return response, nil
+	}
+}
+
+// forwardStream returns the handler forwarding the server streaming calls of the method to the gRPC server.
+func (h *webHandler) forwardStream(procedure string, method protoreflect.MethodDescriptor) func(context.Context, *connect.Request[dynamicpb.Message], *connect.ServerStream[dynamicpb.Message]) error {
+	return func(ctx context.Context, request *connect.Request[dynamicpb.Message], stream *connect.ServerStream[dynamicpb.Message]) error {
+		// The call is canceled once the web client is gone
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		clientStream, err := h.conn.NewStream(outgoingContext(ctx, request.Header()), &grpc.StreamDesc{ServerStreams: true}, procedure)
+		if err != nil {
+			return toConnectError(err, nil, nil)
+		}
+
+		// The errors of sending are returned by the following receive
+		if err := clientStream.SendMsg(request.Msg); err == nil {
+			_ = clientStream.CloseSend()
+		}
+
+		for received := 0; ; received++ {
+			message := dynamicpb.NewMessage(method.Output())
+			err := clientStream.RecvMsg(message)
+
+			// The headers are sent along with the first message, or the end of the stream
+			if received == 0 && (err == nil || errors.Is(err, io.EOF)) {
+				header, _ := clientStream.Header()
+				copyMetadata(stream.ResponseHeader(), header)
+			}
+
+			switch {
+			case errors.Is(err, io.EOF):
+				copyMetadata(stream.ResponseTrailer(), clientStream.Trailer())
+				return nil
+			case err != nil:
+				var header metadata.MD
+				if received == 0 {
+					header, _ = clientStream.Header()
+				}
+
+				return toConnectError(err, header, clientStream.Trailer())
+			}
+
+			if err := stream.Send(message); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// initializeRequest creates the request message of the method being called.
+func initializeRequest(spec connect.Spec, message any) error {
+	method, ok := spec.Schema.(protoreflect.MethodDescriptor)
+	if !ok {
+		return connect.NewError(connect.CodeInternal, errors.New("missing method schema"))
+	}
+
+	request, ok := message.(*dynamicpb.Message)
+	if !ok {
+		return connect.NewError(connect.CodeInternal, errors.New("unexpected request message type"))
+	}
+
+	*request = *dynamicpb.NewMessage(method.Input())
+	return nil
+}
+
+// protocolHeaders are the request headers of the web protocols and the connection, which are not forwarded as
+// the metadata of the gRPC calls.
+var protocolHeaders = map[string]bool{
+	"accept-encoding":   true,
+	"connection":        true,
+	"content-encoding":  true,
+	"content-length":    true,
+	"content-type":      true,
+	"host":              true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"te":                true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"user-agent":        true,
+	"x-grpc-web":        true,
+	"x-user-agent":      true,
+}
+
+// outgoingContext forwards the request headers, e.g. the Authorization, as the metadata of the gRPC call.
+func outgoingContext(ctx context.Context, header http.Header) context.Context {
+	md := metadata.MD{}
+	for name, values := range header {
+		key := strings.ToLower(name)
+		if protocolHeaders[key] || strings.HasPrefix(key, "connect-") || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				decoded, err := connect.DecodeBinaryHeader(value)
+				if err != nil {
+					continue
+				}
+
+				value = string(decoded)
+			}
+
+			md.Append(key, value)
+		}
+	}
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// copyMetadata copies the metadata of the gRPC call to the headers, without the headers of the gRPC protocol.
+func copyMetadata(header http.Header, md metadata.MD) {
+	for key, values := range md {
+		if key == "content-type" || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				value = connect.EncodeBinaryHeader([]byte(value))
+			}
+
+			header.Add(key, value)
+		}
+	}
+}
+
+// toConnectError converts the status of the failed gRPC call to the Connect error, along with its details and metadata.
+func toConnectError(err error, header, trailer metadata.MD) error {
+	grpcStatus := status.Convert(err)
+
+	connectErr := connect.NewError(connect.Code(grpcStatus.Code()), errors.New(grpcStatus.Message()))
+	for _, detail := range grpcStatus.Proto().GetDetails() {
+		errorDetail, err := connect.NewErrorDetail(detail)
+		if err == nil {
+			connectErr.AddDetail(errorDetail)
+		}
+	}
+
+	copyMetadata(connectErr.Meta(), header)
+	copyMetadata(connectErr.Meta(), trailer)
+	return connectErr
+}
+
+// serveText serves the gRPC-Web requests with the base64 encoded messages, which the Connect handlers do not support.
+func serveText(handler http.Handler, w http.ResponseWriter, r *http.Request) {
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{base64.NewDecoder(base64.StdEncoding, r.Body), r.Body}
+	r.Header.Set("Content-Type", "application/grpc-web+proto")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+
+	textWriter := &textResponseWriter{ResponseWriter: w}
+	defer textWriter.close()
+
+	handler.ServeHTTP(textWriter, r)
+}
+
+// textResponseWriter encodes the gRPC-Web response in base64. The data written before each flush is encoded
+// separately, so the clients can decode the streamed messages as they arrive.
+type textResponseWriter struct {
+	http.ResponseWriter
+	encoder     io.WriteCloser
+	wroteHeader bool
+}
+
+func (w *textResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("Content-Type", strings.Replace(w.Header().Get("Content-Type"), "application/grpc-web", "application/grpc-web-text", 1))
+		w.Header().Del("Content-Length")
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *textResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.encoder == nil {
+		w.encoder = base64.NewEncoder(base64.StdEncoding, w.ResponseWriter)
+	}
+
+	return w.encoder.Write(data)
+}
+
+func (w *textResponseWriter) Flush() {
+	w.close()
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *textResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close writes the remaining encoded data along with its padding.
+func (w *textResponseWriter) close() {
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+		w.encoder = nil
+	}
+}
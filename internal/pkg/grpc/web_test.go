@@ -0,0 +1,435 @@
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	usergrpc "github.com/xBlaz3kx/faceit-task/internal/grpc"
+	"github.com/xBlaz3kx/faceit-task/internal/memory"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/auth"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const eventTimeout = 5 * time.Second
+
+// newTestWebServer serves the gRPC-Web and Connect requests of the user service backed by the in-memory repository.
+// The calls are authenticated by the authenticator, unless it is nil.
+func newTestWebServer(t *testing.T, authenticator *auth.Authenticator) (*httptest.Server, users.Service) {
+	t.Helper()
+
+	server := NewServer(Configuration{}, authenticator)
+	repository := memory.NewUserRepository(broadcast.Config{BufferSize: 100})
+	t.Cleanup(func() {
+		_ = repository.Close(context.Background())
+	})
+	service := users.NewUserService(repository, 0)
+	usergrpc.RegisterUserServer(server, usergrpc.NewUserGrpcHandler(service, 0))
+
+	web := httptest.NewServer(server.webHandler.Handler())
+	t.Cleanup(func() {
+		web.Close()
+		server.Stop()
+	})
+
+	return web, service
+}
+
+func addUser(t *testing.T, service users.Service, nickname string) *users.User {
+	t.Helper()
+
+	user, err := service.AddUser(context.Background(), users.NewUser{
+		Nickname: nickname,
+		Email:    nickname + "@faceit.com",
+		Password: "password",
+		Country:  "UA",
+	})
+	require.NoError(t, err)
+
+	return user
+}
+
+func post(t *testing.T, url, contentType string, body []byte) *http.Response {
+	t.Helper()
+
+	response, err := http.Post(url, contentType, bytes.NewReader(body))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = response.Body.Close()
+	})
+
+	return response
+}
+
+func TestWebHandler_Connect(t *testing.T) {
+	server, service := newTestWebServer(t, nil)
+	s1mple := addUser(t, service, "s1mple")
+
+	t.Run("JSON", func(t *testing.T) {
+		response := post(t, server.URL+"/user.User/GetUser", "application/json", []byte(`{"id": "`+s1mple.ID+`"}`))
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+		body := struct {
+			User struct {
+				ID       string `json:"id"`
+				Nickname string `json:"nickname"`
+			} `json:"user"`
+		}{}
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+		assert.Equal(t, s1mple.ID, body.User.ID)
+		assert.Equal(t, "s1mple", body.User.Nickname)
+	})
+
+	t.Run("Protobuf", func(t *testing.T) {
+		request, err := proto.Marshal(&usergrpc.GetUserRequest{Id: s1mple.ID})
+		require.NoError(t, err)
+
+		response := post(t, server.URL+"/user.User/GetUser", "application/proto", request)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		user := &usergrpc.GetUserResponse{}
+		require.NoError(t, proto.Unmarshal(body, user))
+		assert.Equal(t, "s1mple", user.GetUser().GetNickname())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		deleted := addUser(t, service, "deleted")
+		require.NoError(t, service.DeleteUser(context.Background(), deleted.ID))
+
+		tests := []struct {
+			name     string
+			body     string
+			status   int
+			expected string
+		}{
+			{name: "Not found", body: `{"id": "` + deleted.ID + `"}`, status: http.StatusNotFound, expected: "not_found"},
+			{name: "Invalid argument", body: `{"id": "not-an-id"}`, status: http.StatusBadRequest, expected: "invalid_argument"},
+			{name: "Invalid message", body: `{"id": 1}`, status: http.StatusBadRequest, expected: "invalid_argument"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				response := post(t, server.URL+"/user.User/GetUser", "application/json", []byte(tt.body))
+				assert.Equal(t, tt.status, response.StatusCode)
+
+				connectErr := connectError{}
+				require.NoError(t, json.NewDecoder(response.Body).Decode(&connectErr))
+				assert.Equal(t, tt.expected, connectErr.Code)
+			})
+		}
+	})
+
+	t.Run("Unknown method", func(t *testing.T) {
+		response := post(t, server.URL+"/user.User/Unknown", "application/json", []byte(`{}`))
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("Unsupported content type", func(t *testing.T) {
+		response := post(t, server.URL+"/user.User/GetUser", "text/plain", []byte(`{}`))
+		assert.Equal(t, http.StatusUnsupportedMediaType, response.StatusCode)
+	})
+}
+
+func TestWebHandler_ConnectStream(t *testing.T) {
+	server, service := newTestWebServer(t, nil)
+	s1mple := addUser(t, service, "s1mple")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/user.User/Watch", bytes.NewReader(envelope(0, []byte(`{"changeTypes": ["DELETE"]}`))))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/connect+json")
+
+	// The headers are sent along with the first message
+	responses := make(chan *http.Response, 1)
+	go func() {
+		response, err := http.DefaultClient.Do(request)
+		if err == nil {
+			responses <- response
+		}
+		close(responses)
+	}()
+
+	// Give the watcher time to subscribe
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, service.DeleteUser(context.Background(), s1mple.ID))
+
+	var response *http.Response
+	select {
+	case response = <-responses:
+		require.NotNil(t, response)
+	case <-time.After(eventTimeout):
+		require.FailNow(t, "timed out waiting for the response")
+	}
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	message := receive(t, readEnvelopes(response.Body))
+	assert.EqualValues(t, 0, message.flags)
+
+	change := struct {
+		ChangeType string `json:"changeType"`
+		UserID     string `json:"userId"`
+	}{}
+	require.NoError(t, json.Unmarshal(message.data, &change))
+	assert.Equal(t, "DELETE", change.ChangeType)
+	assert.Equal(t, s1mple.ID, change.UserID)
+
+	t.Run("Error", func(t *testing.T) {
+		response := post(t, server.URL+"/user.User/Watch", "application/connect+json", envelope(0, []byte(`{"resumeToken": "token"}`)))
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		end := receive(t, readEnvelopes(response.Body))
+		assert.EqualValues(t, flagEndStream, end.flags)
+
+		endStream := struct {
+			Error connectError `json:"error"`
+		}{}
+		require.NoError(t, json.Unmarshal(end.data, &endStream))
+		assert.Equal(t, "invalid_argument", endStream.Error.Code)
+	})
+}
+
+func TestWebHandler_Auth(t *testing.T) {
+	server, service := newTestWebServer(t, newTestAuthenticator(t))
+	s1mple := addUser(t, service, "s1mple")
+
+	call := func(token string) *http.Response {
+		request, err := http.NewRequest(http.MethodPost, server.URL+"/user.User/GetUser", strings.NewReader(`{"id": "`+s1mple.ID+`"}`))
+		require.NoError(t, err)
+		request.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = response.Body.Close()
+		})
+
+		return response
+	}
+
+	// The headers are forwarded as the metadata of the gRPC calls, so they are authenticated by the interceptors
+	assert.Equal(t, http.StatusUnauthorized, call("").StatusCode)
+	assert.Equal(t, http.StatusOK, call(testToken(t)).StatusCode)
+}
+
+func TestWebHandler_GrpcWebStream(t *testing.T) {
+	server, service := newTestWebServer(t, nil)
+	s1mple := addUser(t, service, "s1mple")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watch, err := proto.Marshal(&usergrpc.WatchRequest{ChangeTypes: []usergrpc.ChangeType{usergrpc.ChangeType_DELETE}})
+	require.NoError(t, err)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/user.User/Watch", bytes.NewReader(envelope(0, watch)))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/grpc-web+proto")
+
+	// The headers are sent along with the first message
+	responses := make(chan *http.Response, 1)
+	go func() {
+		response, err := http.DefaultClient.Do(request)
+		if err == nil {
+			responses <- response
+		}
+		close(responses)
+	}()
+
+	// Give the watcher time to subscribe
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, service.DeleteUser(context.Background(), s1mple.ID))
+
+	var response *http.Response
+	select {
+	case response = <-responses:
+		require.NotNil(t, response)
+	case <-time.After(eventTimeout):
+		require.FailNow(t, "timed out waiting for the response")
+	}
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	message := receive(t, readEnvelopes(response.Body))
+	assert.EqualValues(t, 0, message.flags)
+
+	change := &usergrpc.WatchStreamResponse{}
+	require.NoError(t, proto.Unmarshal(message.data, change))
+	assert.Equal(t, usergrpc.ChangeType_DELETE, change.GetChangeType())
+	assert.Equal(t, s1mple.ID, change.GetUserId())
+}
+
+func TestWebHandler_GrpcWeb(t *testing.T) {
+	server, service := newTestWebServer(t, nil)
+	s1mple := addUser(t, service, "s1mple")
+
+	request, err := proto.Marshal(&usergrpc.GetUserRequest{Id: s1mple.ID})
+	require.NoError(t, err)
+
+	t.Run("Binary", func(t *testing.T) {
+		response := post(t, server.URL+"/user.User/GetUser", "application/grpc-web+proto", envelope(0, request))
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "application/grpc-web+proto", response.Header.Get("Content-Type"))
+
+		messages := readEnvelopes(response.Body)
+
+		user := &usergrpc.GetUserResponse{}
+		require.NoError(t, proto.Unmarshal(receive(t, messages).data, user))
+		assert.Equal(t, "s1mple", user.GetUser().GetNickname())
+
+		trailer := receive(t, messages)
+		assert.EqualValues(t, flagTrailer, trailer.flags)
+		assert.Contains(t, string(trailer.data), "grpc-status: 0\r\n")
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		body := base64.StdEncoding.EncodeToString(envelope(0, request))
+		response := post(t, server.URL+"/user.User/GetUser", "application/grpc-web-text", []byte(body))
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		// Each message is encoded separately
+		encoded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		decoded := []byte{}
+		for _, chunk := range strings.SplitAfter(string(encoded), "=") {
+			data, err := base64.StdEncoding.DecodeString(chunk)
+			require.NoError(t, err)
+			decoded = append(decoded, data...)
+		}
+
+		messages := readEnvelopes(bytes.NewReader(decoded))
+		assert.EqualValues(t, 0, receive(t, messages).flags)
+		assert.Contains(t, string(receive(t, messages).data), "grpc-status: 0\r\n")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		deleted := addUser(t, service, "deleted")
+		require.NoError(t, service.DeleteUser(context.Background(), deleted.ID))
+
+		request, err := proto.Marshal(&usergrpc.GetUserRequest{Id: deleted.ID})
+		require.NoError(t, err)
+
+		response := post(t, server.URL+"/user.User/GetUser", "application/grpc-web+proto", envelope(0, request))
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		// The errors without any messages are sent as the trailers-only responses
+		assert.Equal(t, "5", response.Header.Get("Grpc-Status"))
+	})
+
+	t.Run("Too large message", func(t *testing.T) {
+		// Only the length of the message is sent, which must be rejected before reading the message
+		header := make([]byte, 5)
+		binary.BigEndian.PutUint32(header[1:], math.MaxUint32)
+
+		response := post(t, server.URL+"/user.User/GetUser", "application/grpc-web+proto", header)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		assert.Equal(t, "8", response.Header.Get("Grpc-Status"))
+	})
+}
+
+func TestWebHandler_Grpc(t *testing.T) {
+	server, _ := newTestWebServer(t, nil)
+
+	conn, err := grpc.NewClient(strings.TrimPrefix(server.URL, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+
+	response, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, response.GetStatus())
+}
+
+const (
+	// flagTrailer marks the enveloped message carrying the trailers of the gRPC-Web response
+	flagTrailer = 0x80
+
+	// flagEndStream marks the enveloped message ending the Connect stream
+	flagEndStream = 0x02
+)
+
+// connectError is the error of the Connect protocol.
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// envelope prefixes the message with its flags and length.
+func envelope(flags byte, message []byte) []byte {
+	data := make([]byte, 5, 5+len(message))
+	data[0] = flags
+	binary.BigEndian.PutUint32(data[1:], uint32(len(message)))
+	return append(data, message...)
+}
+
+type testEnvelope struct {
+	flags byte
+	data  []byte
+}
+
+func readEnvelopes(body io.Reader) <-chan testEnvelope {
+	messages := make(chan testEnvelope)
+	go func() {
+		defer close(messages)
+
+		reader := bufio.NewReader(body)
+		for {
+			header := make([]byte, 5)
+			if _, err := io.ReadFull(reader, header); err != nil {
+				return
+			}
+
+			data := make([]byte, binary.BigEndian.Uint32(header[1:]))
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return
+			}
+
+			messages <- testEnvelope{flags: header[0], data: data}
+		}
+	}()
+
+	return messages
+}
+
+func receive(t *testing.T, messages <-chan testEnvelope) testEnvelope {
+	t.Helper()
+
+	select {
+	case message, ok := <-messages:
+		require.True(t, ok, "the stream was closed")
+		return message
+	case <-time.After(eventTimeout):
+		require.FailNow(t, "timed out waiting for a message")
+		return testEnvelope{}
+	}
+}
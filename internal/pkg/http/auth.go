@@ -0,0 +1,68 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/auth"
+)
+
+const (
+	// accessTokenParam is the query parameter carrying the token of the WebSocket requests, which cannot set headers
+	accessTokenParam = "access_token"
+
+	// accessTokenKey is the key of the access token in the context of the request
+	accessTokenKey = "accessToken"
+
+	// watchPath is the path of the WebSocket watch, the only requests accepting the token as the query parameter
+	watchPath = "/v1/users/watch"
+)
+
+// AccessToken removes the access_token query parameter from the requests, so the token is never logged along with
+// the query. The token of the WebSocket watch is kept in the context of the request for the Authentication.
+func AccessToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		if !query.Has(accessTokenParam) {
+			c.Next()
+			return
+		}
+
+		if c.Request.URL.Path == watchPath && websocket.IsWebSocketUpgrade(c.Request) {
+			c.Set(accessTokenKey, query.Get(accessTokenParam))
+		}
+
+		query.Del(accessTokenParam)
+		c.Request.URL.RawQuery = query.Encode()
+		c.Next()
+	}
+}
+
+// Authentication requires a valid bearer token on the requests of all but the public paths, adding the authenticated
+// caller to the context of the request. The browsers cannot set the headers of the WebSocket requests, so the token
+// of the WebSocket watch can also be passed as the access_token query parameter, kept by the AccessToken middleware.
+func Authentication(authenticator *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authenticator.PublicPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		token := auth.BearerToken(c.GetHeader("Authorization"))
+		if token == "" {
+			token = c.GetString(accessTokenKey)
+		}
+
+		caller, err := authenticator.Authenticate(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": "unauthenticated", "message": err.Error()})
+			return
+		}
+
+		c.Request = c.Request.WithContext(users.ContextWithCaller(c.Request.Context(), caller))
+		c.Next()
+	}
+}
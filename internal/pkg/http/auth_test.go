@@ -0,0 +1,126 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/auth"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/cors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const testSecret = "d7c8a1b0e5f94c3a8b6d2e1f0a9c8b7d"
+
+func newTestAuthenticator(t *testing.T) (*auth.Authenticator, string) {
+	t.Helper()
+
+	authenticator, err := auth.NewAuthenticator(auth.Config{
+		Enabled:     true,
+		Keys:        []auth.Key{{Secret: testSecret}},
+		PublicPaths: []string{"/healthz"},
+	})
+	require.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "faceit-admin",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+
+	return authenticator, token
+}
+
+// webSocketRequest creates the request upgrading the connection to a WebSocket.
+func webSocketRequest(path string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	return request
+}
+
+func callerSubject(c *gin.Context) {
+	caller, _ := users.CallerFromContext(c.Request.Context())
+	c.String(http.StatusOK, caller.Subject)
+}
+
+func TestAuthentication(t *testing.T) {
+	authenticator, token := newTestAuthenticator(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AccessToken(), Authentication(authenticator))
+	router.GET("/healthz", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/v1/users", callerSubject)
+	router.GET(watchPath, callerSubject)
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		webSocket     bool
+		status        int
+		body          string
+	}{
+		{name: "Public path", path: "/healthz", status: http.StatusOK},
+		{name: "Missing token", path: "/v1/users", status: http.StatusUnauthorized},
+		{name: "Invalid token", path: "/v1/users", authorization: "Bearer token", status: http.StatusUnauthorized},
+		{name: "Bearer token", path: "/v1/users", authorization: "Bearer " + token, status: http.StatusOK, body: "faceit-admin"},
+		{name: "WebSocket access token", path: watchPath + "?access_token=" + token, webSocket: true, status: http.StatusOK, body: "faceit-admin"},
+		{name: "Access token without a WebSocket", path: watchPath + "?access_token=" + token, status: http.StatusUnauthorized},
+		{name: "Access token of another path", path: "/v1/users?access_token=" + token, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.webSocket {
+				request = webSocketRequest(tt.path)
+			}
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tt.status, recorder.Code)
+			if tt.status == http.StatusUnauthorized {
+				assert.Contains(t, recorder.Body.String(), `"code":"unauthenticated"`)
+				assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+			}
+			if tt.body != "" {
+				assert.Equal(t, tt.body, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_AccessTokenNotLogged(t *testing.T) {
+	authenticator, token := newTestAuthenticator(t)
+
+	core, logs := observer.New(zap.InfoLevel)
+	server := NewServer(":0", cors.Config{}, authenticator, zap.New(core))
+	server.Router.GET(watchPath, callerSubject)
+
+	recorder := httptest.NewRecorder()
+	server.Router.ServeHTTP(recorder, webSocketRequest(watchPath+"?changeTypes=DELETE&access_token="+token))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "faceit-admin", recorder.Body.String())
+
+	entries := logs.FilterFieldKey("query").All()
+	require.Len(t, entries, 1)
+
+	query := entries[0].ContextMap()["query"]
+	assert.Equal(t, "changeTypes=DELETE", query)
+	assert.NotContains(t, query, token)
+}
@@ -8,26 +8,41 @@ import (
 
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	healthcheck "github.com/tavsec/gin-healthcheck"
 	"github.com/tavsec/gin-healthcheck/checks"
 	"github.com/tavsec/gin-healthcheck/config"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/auth"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/cors"
 	"go.uber.org/zap"
 )
 
 type Server struct {
 	Router *gin.Engine
 	server *http.Server
+	cors   cors.Config
 	logger *zap.Logger
 }
 
-func NewServer(address string, logger *zap.Logger) *Server {
+// NewServer creates the HTTP server, allowing the browsers from the configured origins to call it. The requests are
+// authenticated by the authenticator, unless it is nil.
+func NewServer(address string, corsCfg cors.Config, authenticator *auth.Authenticator, logger *zap.Logger) *Server {
 	// Create a Router and attach middleware
 	router := gin.New()
 	gin.SetMode(gin.ReleaseMode)
 
+	// Attach recovery & log middleware, before any route is added. The access tokens are removed from the queries
+	// before they are logged.
+	logger = logger.Named("http-server")
+	router.Use(AccessToken(), ginzap.Ginzap(logger, time.RFC3339, true), ginzap.RecoveryWithZap(logger, true))
+	if authenticator != nil {
+		router.Use(Authentication(authenticator))
+	}
+
 	return &Server{
 		Router: router,
-		logger: logger.Named("http-server"),
+		cors:   corsCfg,
+		logger: logger,
 		server: &http.Server{
 			Addr: address,
 		},
@@ -38,9 +53,6 @@ func NewServer(address string, logger *zap.Logger) *Server {
 func (s *Server) Start(checks ...checks.Check) {
 	s.logger.Info("Starting the server")
 
-	// Attach recovery & log middleware
-	s.Router.Use(ginzap.Ginzap(s.logger, time.RFC3339, true), ginzap.RecoveryWithZap(s.logger, true))
-
 	// Add a healthcheck endpoint
 	err := healthcheck.New(s.Router, config.DefaultConfig(), checks)
 	if err != nil {
@@ -48,7 +60,10 @@ func (s *Server) Start(checks ...checks.Check) {
 		return
 	}
 
-	s.server.Handler = s.Router.Handler()
+	// Expose the Prometheus metrics
+	s.Router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	s.server.Handler = cors.Handler(s.cors, s.Router.Handler())
 
 	go func() {
 		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
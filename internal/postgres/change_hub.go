@@ -0,0 +1,296 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pkg/errors"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"go.uber.org/zap"
+)
+
+// reconnectInterval is how long the hub waits before listening again after the listening connection failed.
+const reconnectInterval = time.Second
+
+// changeHub shares a single listening connection between all the watchers of the repository. The connection listens
+// from the first watcher until the last watcher stops watching.
+type changeHub struct {
+	logger  *zap.Logger
+	db      *sql.DB
+	changes *broadcast.Broadcaster[users.UserEvent]
+
+	// mu guards the listening connection's lifecycle, so no change received after it stopped is published to the
+	// new watchers.
+	mu          sync.Mutex
+	subscribers int
+	cancel      context.CancelFunc
+	stopped     chan struct{}
+}
+
+func newChangeHub(db *sql.DB, cfg broadcast.Config, logger *zap.Logger) *changeHub {
+	return &changeHub{
+		logger:  logger.Named("change-hub"),
+		db:      db,
+		changes: broadcast.New[users.UserEvent](cfg),
+	}
+}
+
+// subscribe returns the changes committed after subscribing, until the context is done or the watcher is
+// disconnected for falling behind.
+func (h *changeHub) subscribe(ctx context.Context) (<-chan users.UserEvent, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers == 0 {
+		// The connection listens before subscribing, so no change committed after subscribing is missed
+		listenCtx, cancel := context.WithCancel(context.Background())
+		conn, lastPosition, err := h.listen(listenCtx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		h.cancel = cancel
+		h.stopped = make(chan struct{})
+		go h.run(listenCtx, conn, lastPosition, h.stopped)
+	}
+
+	h.subscribers++
+	changes := h.changes.Subscribe(ctx)
+
+	events := make(chan users.UserEvent)
+	go func() {
+		defer close(events)
+		defer h.unsubscribe()
+
+		for event := range changes {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+
+			// The watchers missed some changes, so they have to start over
+			if event.Err != nil {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// unsubscribe stops listening once there are no more watchers.
+func (h *changeHub) unsubscribe() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.subscribers--
+	if h.subscribers == 0 {
+		h.cancel()
+	}
+}
+
+// close stops delivering the changes to the watchers, waiting until the listening connection is released once they
+// all stopped watching.
+func (h *changeHub) close(ctx context.Context) error {
+	err := h.changes.Close(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	stopped := h.stopped
+	h.mu.Unlock()
+
+	if stopped == nil {
+		return nil
+	}
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run publishes the notified changes until the context is canceled, listening again after the last published change
+// when the connection fails. The changes are notified in their commit order, so the changes up to the last position
+// were already published.
+func (h *changeHub) run(ctx context.Context, conn *sql.Conn, lastPosition int64, stopped chan struct{}) {
+	defer close(stopped)
+
+	publish := h.publish(ctx)
+	send := func(event users.UserEvent, position int64) bool {
+		// The changes continue from the position of the error, which is the last change currently logged
+		if event.Err != nil {
+			lastPosition = position
+			return publish(event)
+		}
+
+		if position <= lastPosition {
+			return true
+		}
+
+		lastPosition = position
+		return publish(event)
+	}
+
+	for {
+		err := h.readNotifications(ctx, conn, send)
+		h.unlisten(conn)
+		if err == nil {
+			return
+		}
+
+		h.logger.Error("Listening for user changes failed, reconnecting", zap.Error(err))
+
+		conn = h.reopen(ctx, lastPosition, send)
+		if conn == nil {
+			return
+		}
+	}
+}
+
+// publish returns the function publishing the changes, until the context is canceled.
+func (h *changeHub) publish(ctx context.Context) func(users.UserEvent) bool {
+	return func(event users.UserEvent) bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return false
+		}
+
+		h.changes.Publish(event)
+		return true
+	}
+}
+
+// reopen listens again until it succeeds, sending the changes logged after the last position first. Returns nil once
+// the context is canceled.
+func (h *changeHub) reopen(ctx context.Context, lastPosition int64, send func(users.UserEvent, int64) bool) *sql.Conn {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectInterval):
+		}
+
+		conn, currentPosition, err := h.listen(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				h.logger.Error("Unable to listen for user changes", zap.Error(err))
+			}
+			continue
+		}
+
+		condition, args, err := afterPosition(ctx, h.db, lastPosition)
+		if errors.Is(err, users.ErrResumeTokenExpired) {
+			// The changes since the failure are lost, so the current watchers are stopped
+			h.logger.Error("Unable to resume the user changes, changes were lost", zap.Error(err))
+			send(users.UserEvent{Err: fmt.Errorf("%w: the changes were lost while reconnecting", users.ErrResumeTokenExpired)}, currentPosition)
+			return conn
+		}
+
+		if err == nil {
+			_, err = readChanges(ctx, h.db, h.logger, condition, args, send)
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			h.unlisten(conn)
+			return nil
+		case err != nil:
+			h.logger.Error("Unable to read the user changes logged while reconnecting", zap.Error(err))
+			h.unlisten(conn)
+		default:
+			return conn
+		}
+	}
+}
+
+// listen listens for the changes on a dedicated connection, returning the position of the last change committed
+// before listening.
+func (h *changeHub) listen(ctx context.Context) (*sql.Conn, int64, error) {
+	conn, err := h.db.Conn(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_, err = conn.ExecContext(ctx, "LISTEN "+changesChannel)
+	if err != nil {
+		_ = conn.Close()
+		return nil, 0, err
+	}
+
+	var lastPosition int64
+	err = conn.QueryRowContext(ctx, "SELECT coalesce(max(commit_position), 0) FROM user_changes").Scan(&lastPosition)
+	if err != nil {
+		h.unlisten(conn)
+		return nil, 0, err
+	}
+
+	return conn, lastPosition, nil
+}
+
+// readNotifications sends the notified changes, blocking until the next notification. Returns the error of the
+// connection once it fails, or nil once the context is done or the change could not be sent.
+func (h *changeHub) readNotifications(ctx context.Context, conn *sql.Conn, send func(users.UserEvent, int64) bool) error {
+	for {
+		var notification *pgconn.Notification
+		err := conn.Raw(func(driverConn any) error {
+			var err error
+			notification, err = driverConn.(*stdlib.Conn).Conn().WaitForNotification(ctx)
+			return err
+		})
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			return err
+		}
+
+		event, position, err := toUserEvent(notification.Payload)
+		if err != nil {
+			h.logger.Error("Error decoding user change", zap.Error(err))
+			continue
+		}
+
+		if !send(*event, position) {
+			return nil
+		}
+	}
+}
+
+// unlisten stops listening on the connection before returning it to the pool.
+func (h *changeHub) unlisten(conn *sql.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := conn.Raw(func(driverConn any) error {
+		// Cancelling a wait for a notification closes the underlying connection
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+		if pgxConn.IsClosed() {
+			return nil
+		}
+
+		_, err := pgxConn.Exec(ctx, "UNLISTEN "+changesChannel)
+		return err
+	})
+	if err != nil {
+		h.logger.Warn("Error stopping listening for user changes", zap.Error(err))
+	}
+
+	err = conn.Close()
+	if err != nil {
+		h.logger.Error("Error closing the listening connection", zap.Error(err))
+	}
+}
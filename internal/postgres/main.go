@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	// Registers the pgx driver for database/sql
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/tavsec/gin-healthcheck/checks"
+	"go.uber.org/zap"
+)
+
+type Configuration struct {
+	URI string `yaml:"uri" json:"uri" mapstructure:"uri"`
+
+	// MaxConnections limits the open connections, including the one listening for the changes, 0 does not limit them
+	MaxConnections int `yaml:"maxConnections" json:"maxConnections" mapstructure:"maxConnections"`
+}
+
+// Connect connects to the PostgreSQL database and migrates the schema to the latest version.
+func Connect(configuration Configuration, logger *zap.Logger) (*sql.DB, checks.Check) {
+	logger.Info("Attempting to connect to the database")
+
+	db, err := sql.Open("pgx", configuration.URI)
+	if err != nil {
+		logger.With(zap.Error(err)).Fatal("Unable to connect to database")
+	}
+
+	db.SetMaxOpenConns(configuration.MaxConnections)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		logger.With(zap.Error(err)).Fatal("Unable to connect to database")
+	}
+
+	err = migrate(ctx, db, logger)
+	if err != nil {
+		logger.With(zap.Error(err)).Fatal("Unable to migrate the database")
+	}
+
+	logger.Info("Connected to the database")
+
+	return db, checks.SqlCheck{Sql: db}
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the key of the advisory lock held while migrating, so that replicas never migrate concurrently.
+const migrationLockKey = 20250601
+
+// migrate applies all the migrations that were not applied yet, in the order of their version.
+// Migrations are named <version>_<name>.sql and each of them is applied in its own transaction.
+func migrate(ctx context.Context, db *sql.DB, logger *zap.Logger) error {
+	// Advisory locks are held by the session, so all the statements must run on the same connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey)
+	if err != nil {
+		return errors.Wrap(err, "unable to acquire the migration lock")
+	}
+
+	defer func() {
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+		if err != nil {
+			logger.Error("Unable to release the migration lock", zap.Error(err))
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version    INTEGER PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return errors.Wrap(err, "unable to create the migrations table")
+	}
+
+	// Entries are sorted by the file name
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		versionPart, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(versionPart)
+		if err != nil {
+			return errors.Wrapf(err, "invalid migration version in %s", entry.Name())
+		}
+
+		var applied bool
+		err = conn.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied)
+		if err != nil {
+			return err
+		}
+
+		if applied {
+			continue
+		}
+
+		logger.Info("Applying migration", zap.Int("version", version), zap.String("name", name))
+
+		script, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		err = applyMigration(ctx, conn, version, name, string(script))
+		if err != nil {
+			return errors.Wrapf(err, "unable to apply migration %s", name)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, version int, name, script string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Executing without arguments allows multiple statements in a single script
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", version, name)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
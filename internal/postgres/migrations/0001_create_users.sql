@@ -0,0 +1,18 @@
+CREATE TABLE users
+(
+    id             TEXT PRIMARY KEY,
+    schema_version INTEGER     NOT NULL DEFAULT 1,
+    first_name     TEXT        NOT NULL DEFAULT '',
+    last_name      TEXT        NOT NULL DEFAULT '',
+    nickname       TEXT        NOT NULL DEFAULT '',
+    email          TEXT        NOT NULL,
+    country        TEXT        NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT users_email_key UNIQUE (email)
+);
+
+-- Listing is sorted by the creation time, with the id as a tiebreaker
+CREATE INDEX users_created_at_idx ON users (created_at DESC, id DESC);
+CREATE INDEX users_country_idx ON users (country);
+CREATE INDEX users_nickname_idx ON users (nickname);
@@ -0,0 +1,23 @@
+-- Publish every change of the users table on the user_changes channel, which is used to watch for changes.
+-- Deleted users only carry the id, the same as the change streams of the other repositories.
+CREATE FUNCTION notify_user_change() RETURNS TRIGGER AS
+$$
+DECLARE
+    payload JSONB;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        payload := jsonb_build_object('id', OLD.id);
+    ELSE
+        payload := to_jsonb(NEW);
+    END IF;
+
+    PERFORM pg_notify('user_changes', jsonb_build_object('operation', lower(TG_OP), 'user', payload)::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_notify_change
+    AFTER INSERT OR UPDATE OR DELETE
+    ON users
+    FOR EACH ROW
+EXECUTE FUNCTION notify_user_change();
@@ -0,0 +1,2 @@
+-- Indexes of the other fields the users can be ordered by
+CREATE INDEX users_updated_at_idx ON users (updated_at DESC, id DESC);
@@ -0,0 +1,4 @@
+-- Full-text search over the names, nickname and email, without any language specific stemming or stop words.
+-- The expression must match the searchDocument used by the repository for the index to be used.
+CREATE INDEX users_search_idx ON users USING GIN (to_tsvector('simple', first_name || ' ' || last_name || ' ' ||
+                                                               nickname || ' ' || translate(email, '@.', '  ')));
@@ -0,0 +1,25 @@
+-- Publish the columns changed by an update, so the watchers can be limited to the changes of some fields.
+CREATE OR REPLACE FUNCTION notify_user_change() RETURNS TRIGGER AS
+$$
+DECLARE
+    payload        JSONB;
+    changed_fields JSONB := '[]';
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        payload := jsonb_build_object('id', OLD.id);
+    ELSE
+        payload := to_jsonb(NEW);
+    END IF;
+
+    IF TG_OP = 'UPDATE' THEN
+        SELECT coalesce(jsonb_agg(new_column.key), '[]')
+        INTO changed_fields
+        FROM jsonb_each(payload) AS new_column
+        WHERE new_column.value IS DISTINCT FROM to_jsonb(OLD) -> new_column.key;
+    END IF;
+
+    PERFORM pg_notify('user_changes', jsonb_build_object('operation', lower(TG_OP), 'user', payload,
+                                                         'changed_fields', changed_fields)::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
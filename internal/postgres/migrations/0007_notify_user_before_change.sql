@@ -0,0 +1,38 @@
+-- Publish the users before they were updated or deleted, so the watchers can tell what changed.
+CREATE OR REPLACE FUNCTION notify_user_change() RETURNS TRIGGER AS
+$$
+DECLARE
+    payload        JSONB;
+    changed_fields JSONB := '[]';
+    change         JSONB;
+    change_id      BIGINT;
+    change_time    TIMESTAMPTZ;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        payload := jsonb_build_object('id', OLD.id);
+    ELSE
+        payload := to_jsonb(NEW);
+    END IF;
+
+    IF TG_OP = 'UPDATE' THEN
+        SELECT coalesce(jsonb_agg(new_column.key), '[]')
+        INTO changed_fields
+        FROM jsonb_each(payload) AS new_column
+        WHERE new_column.value IS DISTINCT FROM to_jsonb(OLD) -> new_column.key;
+    END IF;
+
+    change := jsonb_build_object('operation', lower(TG_OP), 'user', payload, 'changed_fields', changed_fields);
+    IF TG_OP <> 'INSERT' THEN
+        change := change || jsonb_build_object('before', to_jsonb(OLD));
+    END IF;
+
+    INSERT INTO user_changes (change)
+    VALUES (change)
+    RETURNING id, changed_at INTO change_id, change_time;
+
+    DELETE FROM user_changes WHERE changed_at < clock_timestamp() - INTERVAL '1 day';
+
+    PERFORM pg_notify('user_changes', (change || jsonb_build_object('id', change_id, 'changed_at', change_time))::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
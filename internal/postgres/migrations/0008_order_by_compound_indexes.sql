@@ -0,0 +1,4 @@
+-- Indexes of the orderings by multiple fields
+CREATE INDEX users_country_nickname_idx ON users (country, nickname, id);
+CREATE INDEX users_country_created_at_idx ON users (country, created_at DESC, id DESC);
+CREATE INDEX users_nickname_created_at_idx ON users (nickname, created_at DESC, id DESC);
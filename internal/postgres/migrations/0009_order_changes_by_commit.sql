@@ -0,0 +1,80 @@
+-- Number the logged changes in the order their transactions commit. The ids are assigned when the changes are made,
+-- so a transaction committing after another one can log its changes with lower ids, which the watchers resuming
+-- after a change would miss. The positions are taken at commit time under a lock, so the changes visible to any
+-- transaction are always the ones up to a position.
+CREATE SEQUENCE user_changes_position_seq AS BIGINT;
+
+ALTER TABLE user_changes
+    ADD COLUMN commit_position BIGINT UNIQUE;
+
+-- The already logged changes keep their ids, so the resume tokens issued before stay valid
+UPDATE user_changes
+SET commit_position = id;
+
+SELECT setval('user_changes_position_seq', coalesce((SELECT max(id) FROM user_changes), 0) + 1, false);
+
+-- Log the changes of the users, which are published once their transaction commits.
+CREATE OR REPLACE FUNCTION notify_user_change() RETURNS TRIGGER AS
+$$
+DECLARE
+    payload        JSONB;
+    changed_fields JSONB := '[]';
+    change         JSONB;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        payload := jsonb_build_object('id', OLD.id);
+    ELSE
+        payload := to_jsonb(NEW);
+    END IF;
+
+    IF TG_OP = 'UPDATE' THEN
+        SELECT coalesce(jsonb_agg(new_column.key), '[]')
+        INTO changed_fields
+        FROM jsonb_each(payload) AS new_column
+        WHERE new_column.value IS DISTINCT FROM to_jsonb(OLD) -> new_column.key;
+    END IF;
+
+    change := jsonb_build_object('operation', lower(TG_OP), 'user', payload, 'changed_fields', changed_fields);
+    IF TG_OP <> 'INSERT' THEN
+        change := change || jsonb_build_object('before', to_jsonb(OLD));
+    END IF;
+
+    INSERT INTO user_changes (change) VALUES (change);
+
+    DELETE FROM user_changes WHERE changed_at < clock_timestamp() - INTERVAL '1 day';
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Runs while committing the transaction which logged the change. The lock is held until the transaction ends, so the
+-- transactions committing later wait for it and take the later positions.
+CREATE FUNCTION publish_user_change() RETURNS TRIGGER AS
+$$
+DECLARE
+    change_position BIGINT;
+BEGIN
+    PERFORM pg_advisory_xact_lock(20250602);
+
+    UPDATE user_changes
+    SET commit_position = nextval('user_changes_position_seq')
+    WHERE id = NEW.id
+    RETURNING commit_position INTO change_position;
+
+    -- The change was already removed from the log
+    IF NOT FOUND THEN
+        RETURN NULL;
+    END IF;
+
+    PERFORM pg_notify('user_changes', (NEW.change || jsonb_build_object('position', change_position,
+                                                                        'changed_at', NEW.changed_at))::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER user_changes_publish
+    AFTER INSERT
+    ON user_changes
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW
+EXECUTE FUNCTION publish_user_change();
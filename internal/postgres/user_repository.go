@@ -0,0 +1,887 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"go.uber.org/zap"
+)
+
+const (
+	schemaVersion = 1
+
+	// userColumns are the columns selected when reading a user, in the order expected by scanUser.
+	userColumns = "id, first_name, last_name, nickname, email, country, created_at, updated_at"
+
+	// changesChannel is the channel the users table triggers notify the changes on.
+	changesChannel = "user_changes"
+
+	// changeRetention is how long the changes are kept in the user_changes log, matching the notify_user_change trigger.
+	changeRetention = 24 * time.Hour
+
+	// searchDocument is the full-text search document of a user, matching the users_search_idx index. The email is
+	// split to words, the same as the other fields.
+	searchDocument = "to_tsvector('simple', first_name || ' ' || last_name || ' ' || nickname || ' ' || translate(email, '@.', '  '))"
+
+	// uniqueViolation is the PostgreSQL error code for violating a unique constraint.
+	uniqueViolation = "23505"
+)
+
+type userRepository struct {
+	logger *zap.Logger
+	db     *sql.DB
+	hub    *changeHub
+
+	// closed is canceled once the repository is closed, stopping the watchers, which are tracked until they
+	// release their connections.
+	closed   context.Context
+	stop     context.CancelFunc
+	watchers sync.WaitGroup
+}
+
+// NewUserRepository creates a repository storing the users in the database. The changes are delivered to the
+// watchers as configured.
+func NewUserRepository(db *sql.DB, watch broadcast.Config) users.Repository {
+	logger := zap.L().Named("user-repository")
+	closed, stop := context.WithCancel(context.Background())
+	return &userRepository{
+		logger: logger,
+		db:     db,
+		hub:    newChangeHub(db, watch, logger),
+		closed: closed,
+		stop:   stop,
+	}
+}
+
+func (u *userRepository) AddUser(ctx context.Context, user *users.User) error {
+	u.logger.Info("Adding user to the database")
+
+	// The time ordered IDs keep the users created in the same millisecond in the order they were created
+	id := uuid.Must(uuid.NewV7()).String()
+
+	_, err := u.db.ExecContext(ctx,
+		`INSERT INTO users (id, schema_version, first_name, last_name, nickname, email, country) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		id, schemaVersion, user.FirstName, user.LastName, user.Nickname, user.Email, user.Country,
+	)
+	switch {
+	case err == nil:
+		user.ID = id
+		return nil
+	case isUniqueViolation(err):
+		return users.ErrUserAlreadyExists
+	default:
+		return err
+	}
+}
+
+func (u *userRepository) UpdateUser(ctx context.Context, user users.User) (*users.User, error) {
+	u.logger.Info("Updating user in the database")
+
+	_, err := uuid.Parse(user.ID)
+	if err != nil {
+		return nil, users.ErrInvalidID
+	}
+
+	row := u.db.QueryRowContext(ctx,
+		`UPDATE users SET first_name = $2, last_name = $3, nickname = $4, email = $5, country = $6, updated_at = now()
+WHERE id = $1 RETURNING `+userColumns,
+		user.ID, user.FirstName, user.LastName, user.Nickname, user.Email, user.Country,
+	)
+
+	res, err := scanUser(row)
+	switch {
+	case err == nil:
+		return res, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, users.ErrUserNotFound
+	case isUniqueViolation(err):
+		return nil, users.ErrUserAlreadyExists
+	default:
+		return nil, err
+	}
+}
+
+func (u *userRepository) DeleteUser(ctx context.Context, id string) error {
+	u.logger.Info("Deleting user from the database", zap.String("id", id))
+
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return users.ErrInvalidID
+	}
+
+	res, err := u.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return users.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (u *userRepository) GetUser(ctx context.Context, id string) (*users.User, error) {
+	u.logger.Info("Getting a user from the database", zap.String("id", id))
+
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return nil, users.ErrInvalidID
+	}
+
+	row := u.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
+	user, err := scanUser(row)
+	switch {
+	case err == nil:
+		return user, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, users.ErrUserNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (u *userRepository) GetUsers(ctx context.Context, query users.Query) (*users.UserPage, error) {
+	ordering, err := query.Ordering()
+	if err != nil {
+		return nil, err
+	}
+
+	// Continue after the last user of the previous page
+	cursor, err := query.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	conditions, args, err := filterConditions(query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.list(ctx, query, ordering, cursor, "users", conditions, args)
+}
+
+func (u *userRepository) SearchUsers(ctx context.Context, query users.SearchQuery) (*users.UserPage, error) {
+	ordering, err := query.Ordering()
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := query.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	switch query.Mode {
+	case users.SearchPrefix:
+		conditions, args, err := filterConditions(query.Query, []any{query.Prefix()})
+		if err != nil {
+			return nil, err
+		}
+
+		conditions = append(conditions, `(starts_with(lower(first_name), $1) OR starts_with(lower(last_name), $1)
+OR starts_with(lower(nickname), $1) OR starts_with(lower(email), $1))`)
+
+		return u.list(ctx, query.Query, ordering, cursor, "users", conditions, args)
+	case users.SearchFullText:
+		// The terms only contain letters and digits, so they are safe to use as a query matching any of them
+		args := []any{strings.Join(query.Terms(), " | ")}
+		source := `(SELECT ` + userColumns + `, ts_rank(` + searchDocument + `, to_tsquery('simple', $1))::float8 AS relevance
+FROM users WHERE ` + searchDocument + ` @@ to_tsquery('simple', $1)) AS search_results`
+
+		conditions, args, err := filterConditions(query.Query, args)
+		if err != nil {
+			return nil, err
+		}
+
+		return u.list(ctx, query.Query, ordering, cursor, source, conditions, args)
+	case users.SearchFuzzy:
+		// Only the MongoDB repository keeps the trigrams of the nicknames, so all the filtered users are compared
+		conditions, args, err := filterConditions(query.Query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates, err := u.all(ctx, conditions, args)
+		if err != nil {
+			return nil, err
+		}
+
+		return query.FuzzyPage(candidates)
+	default:
+		return nil, users.ErrInvalidSearch
+	}
+}
+
+// all returns all the users matching the conditions.
+func (u *userRepository) all(ctx context.Context, conditions []string, args []any) ([]users.User, error) {
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := u.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users"+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := []users.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, *user)
+	}
+
+	return all, rows.Err()
+}
+
+// list returns a page of the users from the source matching the conditions, sorted in the ordering. When sorting by
+// relevance, the source must have a relevance column.
+func (u *userRepository) list(ctx context.Context, query users.Query, ordering users.Ordering, cursor *users.Cursor, source string, conditions []string, args []any) (*users.UserPage, error) {
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	page := &users.UserPage{Users: []users.User{}}
+	if query.IncludeTotalSize {
+		total := int64(0)
+		err := u.db.QueryRowContext(ctx, "SELECT count(*) FROM "+source+where, args...).Scan(&total)
+		if err != nil {
+			return nil, err
+		}
+
+		page.TotalSize = &total
+	}
+
+	if cursor != nil {
+		var condition string
+		condition, args = afterCursor(ordering, cursor, args)
+		conditions = append(conditions, condition)
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	columns := userColumns
+	if ordering.ByRelevance() {
+		columns += ", " + users.FieldRelevance
+	}
+
+	// Fetching an extra user to know if there is a next page
+	pageSize := query.PageSize()
+	args = append(args, pageSize+1, query.Skip())
+	sqlQuery := "SELECT " + columns + " FROM " + source + where + " ORDER BY " + orderBy(ordering) +
+		fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	u.logger.Info("Getting users from the database", zap.String("query", sqlQuery))
+
+	rows, err := u.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	last := users.Cursor{}
+	for rows.Next() {
+		var relevance float64
+
+		extra := []any{}
+		if ordering.ByRelevance() {
+			extra = append(extra, &relevance)
+		}
+
+		user, err := scanUser(rows, extra...)
+		if err != nil {
+			return nil, err
+		}
+
+		// The extra user only signals that there is a next page
+		if int64(len(page.Users)) == pageSize {
+			page.NextPageToken = last.Encode()
+			break
+		}
+
+		page.Users = append(page.Users, *user)
+		if ordering.ByRelevance() {
+			last = users.NewRelevanceCursor(user.ID, relevance)
+		} else {
+			last = ordering.NewCursor(*user)
+		}
+	}
+
+	return page, rows.Err()
+}
+
+func (u *userRepository) GetUserStats(ctx context.Context, query users.StatsQuery) (*users.UserStats, error) {
+	conditions, args, err := filterConditions(users.Query{CreatedAfter: query.CreatedAfter, CreatedBefore: query.CreatedBefore}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	stats := &users.UserStats{Countries: []users.CountryCount{}, SignUps: []users.SignUpBucket{}}
+
+	// The total is the sum of the users in all the countries
+	rows, err := u.db.QueryContext(ctx, `SELECT country, count(*) FROM users`+where+`
+GROUP BY country ORDER BY count(*) DESC, country COLLATE "C"`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		country := users.CountryCount{}
+		err = rows.Scan(&country.Country, &country.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Total += country.Count
+		stats.Countries = append(stats.Countries, country)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The buckets start at midnight UTC, date_trunc starts the weeks on Mondays
+	args = append(args, string(query.BucketInterval()))
+	rows, err = u.db.QueryContext(ctx, fmt.Sprintf(`SELECT date_trunc($%d, created_at AT TIME ZONE 'UTC') AS start, count(*)
+FROM users%s GROUP BY start ORDER BY start`, len(args), where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bucket := users.SignUpBucket{}
+		err = rows.Scan(&bucket.Start, &bucket.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		bucket.Start = bucket.Start.UTC()
+		stats.SignUps = append(stats.SignUps, bucket)
+	}
+
+	return stats, rows.Err()
+}
+
+// Watch receives the changes notified by the users table triggers through the change hub, which shares a single
+// listening connection between all the watchers. When resuming, the logged changes are sent first, followed by the
+// notifications of the changes logged after them.
+func (u *userRepository) Watch(ctx context.Context, query users.WatchQuery) (<-chan users.UserEvent, error) {
+	backlog, args, err := u.backlogCondition(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// The watcher is stopped when the repository is closed
+	ctx, cancel := context.WithCancel(ctx)
+	stopWatching := context.AfterFunc(u.closed, cancel)
+
+	// Subscribing before reading the logged changes, so no change committed after them is missed
+	changes, err := u.hub.subscribe(ctx)
+	if err != nil {
+		stopWatching()
+		cancel()
+		return nil, err
+	}
+
+	userChan := make(chan users.UserEvent)
+
+	// Stream to goroutine
+	u.watchers.Add(1)
+	go func() {
+		defer u.watchers.Done()
+		defer close(userChan)
+		defer cancel()
+		defer stopWatching()
+
+		send := func(event users.UserEvent) bool {
+			// All the changes are published on the same channel, so they are filtered here
+			if event.Err == nil && !query.Matches(event) {
+				return true
+			}
+
+			select {
+			case userChan <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// The changes logged after subscribing are notified as well, so only the newer notifications are sent. The
+		// positions follow the commit order, so the changes committed after reading the backlog have higher positions.
+		var lastPosition int64
+		if backlog != "" {
+			var err error
+			lastPosition, err = readChanges(ctx, u.db, u.logger, backlog, args, func(event users.UserEvent, _ int64) bool {
+				return send(event)
+			})
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				u.logger.Error("Error reading the logged user changes", zap.Error(err))
+				send(users.UserEvent{Err: err})
+				return
+			}
+		}
+
+		for event := range changes {
+			if event.Err == nil {
+				position, err := decodePosition(event.ResumeToken)
+				if err == nil && position <= lastPosition {
+					continue
+				}
+			}
+
+			if !send(event) {
+				return
+			}
+		}
+	}()
+
+	return userChan, nil
+}
+
+// Snapshot reads all the users, sorted by their ID, along with the last logged change, without holding off the
+// writes. All the queries of the transaction read the same snapshot of the database, which contains the changes up
+// to the last logged one, as the positions follow the commit order. The changes after it are made after the snapshot.
+func (u *userRepository) Snapshot(ctx context.Context) (*users.Snapshot, error) {
+	tx, err := u.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rows, err := tx.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshot := &users.Snapshot{Users: []users.User{}}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot.Users = append(snapshot.Users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Without any logged change, the changes are watched from the time of the snapshot
+	var lastPosition sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT max(commit_position), clock_timestamp() FROM user_changes").Scan(&lastPosition, &snapshot.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Time = snapshot.Time.UTC()
+	if lastPosition.Valid {
+		snapshot.ResumeToken = encodePosition(lastPosition.Int64)
+	}
+
+	return snapshot, tx.Commit()
+}
+
+// backlogCondition returns the condition of the logged changes to send before the notifications, or an empty
+// condition when only the new changes are watched.
+func (u *userRepository) backlogCondition(ctx context.Context, query users.WatchQuery) (string, []any, error) {
+	switch {
+	case query.ResumeToken != "":
+		position, err := decodePosition(query.ResumeToken)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return afterPosition(ctx, u.db, position)
+	case query.StartAt != nil:
+		if time.Since(*query.StartAt) > changeRetention {
+			return "", nil, users.ErrResumeTokenExpired
+		}
+
+		return "changed_at >= $1", []any{query.StartAt.UTC()}, nil
+	default:
+		return "", nil, nil
+	}
+}
+
+// afterPosition returns the condition of the changes logged after the position. The change at the position is removed
+// from the log along with the changes after it, so it must still be logged.
+func afterPosition(ctx context.Context, db *sql.DB, position int64) (string, []any, error) {
+	if position == 0 {
+		return "TRUE", nil, nil
+	}
+
+	var logged bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM user_changes WHERE commit_position = $1)", position).Scan(&logged)
+	switch {
+	case err != nil:
+		return "", nil, err
+	case !logged:
+		return "", nil, users.ErrResumeTokenExpired
+	}
+
+	return "commit_position > $1", []any{position}, nil
+}
+
+// readChanges sends the logged changes matching the condition in their commit order, returning the position of the
+// last one. Stops once a change could not be sent, as the context is done.
+func readChanges(ctx context.Context, db *sql.DB, logger *zap.Logger, condition string, args []any, send func(users.UserEvent, int64) bool) (int64, error) {
+	// The logged changes are decoded the same as the notifications
+	rows, err := db.QueryContext(ctx, `SELECT (change || jsonb_build_object('position', commit_position, 'changed_at', changed_at))::TEXT
+FROM user_changes WHERE `+condition+` ORDER BY commit_position`, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var lastPosition int64
+	for rows.Next() {
+		var payload string
+		err = rows.Scan(&payload)
+		if err != nil {
+			return 0, err
+		}
+
+		event, position, err := toUserEvent(payload)
+		if err != nil {
+			logger.Error("Error decoding user change", zap.Error(err))
+			continue
+		}
+
+		lastPosition = position
+		if !send(*event, position) {
+			return lastPosition, ctx.Err()
+		}
+	}
+
+	return lastPosition, rows.Err()
+}
+
+func filterConditions(query users.Query, args []any) ([]string, []any, error) {
+	conditions := []string{}
+
+	addCondition := func(column string, value *string) {
+		if value == nil {
+			return
+		}
+
+		args = append(args, *value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	addCondition("first_name", query.FirstName)
+	addCondition("last_name", query.LastName)
+	addCondition("nickname", query.Nickname)
+	addCondition("country", query.Country)
+	addCondition("email", query.Email)
+
+	if len(query.Countries) > 0 {
+		args = append(args, query.Countries)
+		conditions = append(conditions, fmt.Sprintf("country = ANY($%d)", len(args)))
+	}
+
+	if len(query.ExcludedCountries) > 0 {
+		args = append(args, query.ExcludedCountries)
+		conditions = append(conditions, fmt.Sprintf("country <> ALL($%d)", len(args)))
+	}
+
+	addTimeCondition := func(condition string, value *time.Time) {
+		if value == nil {
+			return
+		}
+
+		args = append(args, *value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	// The time ranges include their start, but not their end
+	addTimeCondition("created_at >= $%d", query.CreatedAfter)
+	addTimeCondition("created_at < $%d", query.CreatedBefore)
+	addTimeCondition("updated_at >= $%d", query.UpdatedAfter)
+	addTimeCondition("updated_at < $%d", query.UpdatedBefore)
+
+	expression, err := query.Expression()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if expression != nil {
+		var condition string
+		condition, args = expressionCondition(expression, args)
+		conditions = append(conditions, condition)
+	}
+
+	return conditions, args, nil
+}
+
+// expressionCondition compiles the filter expression to a condition, adding the compared values to the args.
+// The filterable fields are the names of the columns.
+func expressionCondition(expression users.Expression, args []any) (string, []any) {
+	switch expression := expression.(type) {
+	case users.AndExpression:
+		return joinedCondition(expression.Expressions, " AND ", args)
+	case users.OrExpression:
+		return joinedCondition(expression.Expressions, " OR ", args)
+	case users.NotExpression:
+		condition, args := expressionCondition(expression.Expression, args)
+		return "NOT " + condition, args
+	case users.Comparison:
+		column, operator := expression.Field, string(expression.Operator)
+		switch expression.Operator {
+		case users.OperatorEqual:
+		case users.OperatorNotEqual:
+			operator = "<>"
+		default:
+			// Compare the strings byte by byte, the same as the other repositories
+			if !users.IsTimeField(expression.Field) {
+				column += ` COLLATE "C"`
+			}
+		}
+
+		args = append(args, expression.Value)
+		return fmt.Sprintf("(%s %s $%d)", column, operator, len(args)), args
+	default:
+		return "FALSE", args
+	}
+}
+
+// joinedCondition joins the conditions of the expressions using the operator.
+func joinedCondition(expressions []users.Expression, operator string, args []any) (string, []any) {
+	conditions := make([]string, 0, len(expressions))
+	for _, expression := range expressions {
+		var condition string
+		condition, args = expressionCondition(expression, args)
+		conditions = append(conditions, condition)
+	}
+
+	return "(" + strings.Join(conditions, operator) + ")", args
+}
+
+// orderBy returns the ORDER BY clause sorting the users in the ordering.
+func orderBy(ordering users.Ordering) string {
+	clauses := make([]string, 0, len(ordering)+1)
+	for _, field := range ordering {
+		clauses = append(clauses, field.Field+direction(field.Descending))
+	}
+
+	return strings.Join(append(clauses, "id"+direction(ordering.IDDescending())), ", ")
+}
+
+// afterCursor returns the condition matching the users listed after the cursor, adding its values to the args.
+// The users are after the cursor if any of the ordered fields is after the cursor's value, while the previous
+// fields are equal.
+func afterCursor(ordering users.Ordering, cursor *users.Cursor, args []any) (string, []any) {
+	alternatives := []string{}
+	equal := []string{}
+
+	addField := func(column string, descending bool, value any) {
+		args = append(args, value)
+		placeholder := fmt.Sprintf("$%d", len(args))
+
+		operator := " > "
+		if descending {
+			operator = " < "
+		}
+
+		after := append(slices.Clone(equal), column+operator+placeholder)
+		alternatives = append(alternatives, "("+strings.Join(after, " AND ")+")")
+		equal = append(equal, column+" = "+placeholder)
+	}
+
+	for i, field := range ordering {
+		addField(field.Field, field.Descending, cursor.Values[i])
+	}
+	addField("id", ordering.IDDescending(), cursor.ID)
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func direction(descending bool) string {
+	if descending {
+		return " DESC"
+	}
+
+	return " ASC"
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser scans the user. The extra destinations are scanned after the user's columns.
+func scanUser(row scanner, extra ...any) (*users.User, error) {
+	var user users.User
+
+	dest := []any{&user.ID, &user.FirstName, &user.LastName, &user.Nickname, &user.Email, &user.Country, &user.CreatedAt, &user.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	user.CreatedAt = user.CreatedAt.UTC()
+	user.UpdatedAt = user.UpdatedAt.UTC()
+	return &user, nil
+}
+
+// userChange is the payload of a notification sent by the users table triggers.
+// userRow is a row of the users table in a change notification.
+type userRow struct {
+	ID        string    `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Nickname  string    `json:"nickname"`
+	Email     string    `json:"email"`
+	Country   string    `json:"country"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (r userRow) toUser() users.User {
+	return users.User{
+		ID:        r.ID,
+		FirstName: r.FirstName,
+		LastName:  r.LastName,
+		Nickname:  r.Nickname,
+		Email:     r.Email,
+		Country:   r.Country,
+		CreatedAt: r.CreatedAt.UTC(),
+		UpdatedAt: r.UpdatedAt.UTC(),
+	}
+}
+
+type userChange struct {
+	Operation string  `json:"operation"`
+	User      userRow `json:"user"`
+
+	// Before is the row before an update or a delete
+	Before *userRow `json:"before"`
+
+	// ChangedFields are all the columns changed by an update
+	ChangedFields []string `json:"changed_fields"`
+
+	// Position and ChangedAt identify the change in the user_changes log, the positions follow the commit order
+	Position  int64     `json:"position"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// toUserEvent decodes the change, returning the position of the change in the log along with the event.
+func toUserEvent(payload string) (*users.UserEvent, int64, error) {
+	change := userChange{}
+	err := json.Unmarshal([]byte(payload), &change)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	event := &users.UserEvent{
+		ChangeType:  change.Operation,
+		Time:        change.ChangedAt.UTC(),
+		ResumeToken: encodePosition(change.Position),
+		User:        change.User.toUser(),
+	}
+
+	if change.Operation == users.ChangeUpdate {
+		event.UpdatedFields = change.ChangedFields
+		event.ChangedFields = lo.Filter(users.WatchableFields, func(field string, _ int) bool {
+			return lo.Contains(change.ChangedFields, field)
+		})
+	}
+
+	if change.Before != nil {
+		event.Before = lo.ToPtr(change.Before.toUser())
+	}
+
+	// Deleted users only carry the id
+	if change.Operation == users.ChangeDelete {
+		event.User = users.User{ID: change.User.ID}
+	} else {
+		event.After = lo.ToPtr(event.User)
+	}
+
+	return event, change.Position, nil
+}
+
+// encodePosition returns the resume token of the logged change at the position.
+func encodePosition(position int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(position, 10)))
+}
+
+// decodePosition returns the position of the logged change the resume token was created for.
+func decodePosition(token string) (int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, users.ErrInvalidResumeToken
+	}
+
+	position, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || position <= 0 {
+		return 0, users.ErrInvalidResumeToken
+	}
+
+	return position, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// Close stops the watchers and waits until they released their connections.
+func (u *userRepository) Close(ctx context.Context) error {
+	u.stop()
+
+	err := u.hub.close(ctx)
+	if err != nil {
+		return err
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		u.watchers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users/repositorytest"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"go.uber.org/zap"
+)
+
+// TestUserRepository runs against the database in USER_TEST_POSTGRES_URI, emptying the users table before each test.
+func TestUserRepository(t *testing.T) {
+	uri := os.Getenv("USER_TEST_POSTGRES_URI")
+	if uri == "" {
+		t.Skip("USER_TEST_POSTGRES_URI is not set")
+	}
+
+	db, _ := Connect(Configuration{URI: uri}, zap.NewNop())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	repositorytest.Run(t, func(t *testing.T) users.Repository {
+		_, err := db.ExecContext(context.Background(), "TRUNCATE users")
+		require.NoError(t, err)
+
+		return NewUserRepository(db, broadcast.Config{BufferSize: 100})
+	})
+}
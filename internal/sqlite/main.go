@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"time"
+
+	"github.com/tavsec/gin-healthcheck/checks"
+	"go.uber.org/zap"
+	// Registers the pure Go SQLite driver for database/sql
+	_ "modernc.org/sqlite"
+)
+
+type Configuration struct {
+	// Path to the database file, created if it does not exist.
+	Path string `yaml:"path" json:"path" mapstructure:"path"`
+}
+
+// Connect opens the SQLite database file in WAL mode and migrates the schema to the latest version.
+func Connect(configuration Configuration, logger *zap.Logger) (*sql.DB, checks.Check) {
+	logger.Info("Attempting to open the database", zap.String("path", configuration.Path))
+
+	db, err := sql.Open("sqlite", dataSourceName(configuration.Path))
+	if err != nil {
+		logger.With(zap.Error(err)).Fatal("Unable to open the database")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		logger.With(zap.Error(err)).Fatal("Unable to open the database")
+	}
+
+	err = migrate(ctx, db, logger)
+	if err != nil {
+		logger.With(zap.Error(err)).Fatal("Unable to migrate the database")
+	}
+
+	logger.Info("Opened the database")
+
+	return db, checks.SqlCheck{Sql: db}
+}
+
+// dataSourceName builds the DSN for the file. Write-ahead logging lets readers work alongside the single writer,
+// the busy timeout makes concurrent writers wait for each other instead of failing and immediate transactions
+// take the write lock upfront, so they cannot deadlock when upgrading from a read lock.
+func dataSourceName(path string) string {
+	query := url.Values{}
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "synchronous(NORMAL)")
+	query.Set("_txlock", "immediate")
+
+	return "file:" + path + "?" + query.Encode()
+}
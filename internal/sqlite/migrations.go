@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrate applies all the migrations that were not applied yet, in the order of their version.
+// Migrations are named <version>_<name>.sql and each of them is applied in its own transaction.
+func migrate(ctx context.Context, db *sql.DB, logger *zap.Logger) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version    INTEGER PRIMARY KEY,
+    name       TEXT    NOT NULL,
+    applied_at INTEGER NOT NULL DEFAULT (unixepoch())
+)`)
+	if err != nil {
+		return errors.Wrap(err, "unable to create the migrations table")
+	}
+
+	// Entries are sorted by the file name
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		versionPart, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(versionPart)
+		if err != nil {
+			return errors.Wrapf(err, "invalid migration version in %s", entry.Name())
+		}
+
+		script, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		err = applyMigration(ctx, db, version, name, string(script), logger)
+		if err != nil {
+			return errors.Wrapf(err, "unable to apply migration %s", name)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration applies the migration if it was not applied yet. Transactions hold the write lock from the start,
+// so other processes opening the same file cannot apply the migration concurrently.
+func applyMigration(ctx context.Context, db *sql.DB, version int, name, script string, logger *zap.Logger) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)", version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+
+	if applied {
+		return nil
+	}
+
+	logger.Info("Applying migration", zap.Int("version", version), zap.String("name", name))
+
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", version, name)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,34 @@
+-- Full-text search over the names, nickname and email. The index keeps the id of the user instead of relying on the
+-- rowid, which might change when the database is vacuumed.
+CREATE VIRTUAL TABLE users_search USING fts5(id UNINDEXED, first_name, last_name, nickname, email);
+
+INSERT INTO users_search (id, first_name, last_name, nickname, email)
+SELECT id, first_name, last_name, nickname, email
+FROM users;
+
+CREATE TRIGGER users_search_insert
+    AFTER INSERT
+    ON users
+BEGIN
+    INSERT INTO users_search (id, first_name, last_name, nickname, email)
+    VALUES (new.id, new.first_name, new.last_name, new.nickname, new.email);
+END;
+
+CREATE TRIGGER users_search_update
+    AFTER UPDATE
+    ON users
+BEGIN
+    UPDATE users_search
+    SET first_name = new.first_name,
+        last_name  = new.last_name,
+        nickname   = new.nickname,
+        email      = new.email
+    WHERE id = old.id;
+END;
+
+CREATE TRIGGER users_search_delete
+    AFTER DELETE
+    ON users
+BEGIN
+    DELETE FROM users_search WHERE id = old.id;
+END;
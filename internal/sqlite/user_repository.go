@@ -0,0 +1,637 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"go.uber.org/zap"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	schemaVersion = 1
+
+	// userColumns are the columns selected when reading a user, in the order expected by scanUser.
+	userColumns = "id, first_name, last_name, nickname, email, country, created_at, updated_at"
+
+	// historySize is the number of the last events kept for resuming the watchers.
+	historySize = 1000
+)
+
+// likeEscaper escapes the wildcards of a LIKE pattern, using the backslash as the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+type userRepository struct {
+	logger *zap.Logger
+	db     *sql.DB
+
+	// writeMu serializes the writes, so the changes are published in the order they were committed.
+	// SQLite only allows a single writer at a time anyway.
+	writeMu sync.Mutex
+	changes *users.EventLog
+}
+
+// NewUserRepository creates a users.Repository backed by the SQLite database. As the database is embedded,
+// changes can only be watched within this process, so all the writes must go through the same repository. The
+// changes are delivered to the watchers as configured.
+func NewUserRepository(db *sql.DB, watch broadcast.Config) users.Repository {
+	return &userRepository{
+		logger:  zap.L().Named("user-repository"),
+		db:      db,
+		changes: users.NewEventLog(historySize, watch),
+	}
+}
+
+func (u *userRepository) AddUser(ctx context.Context, user *users.User) error {
+	u.logger.Info("Adding user to the database")
+
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+
+	// The time ordered IDs keep the users created in the same millisecond in the order they were created
+	id := uuid.Must(uuid.NewV7()).String()
+	now := time.Now().UnixMilli()
+
+	row := u.db.QueryRowContext(ctx,
+		`INSERT INTO users (id, schema_version, first_name, last_name, nickname, email, country, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING `+userColumns,
+		id, schemaVersion, user.FirstName, user.LastName, user.Nickname, user.Email, user.Country, now, now,
+	)
+
+	created, err := scanUser(row)
+	switch {
+	case err == nil:
+		user.ID = id
+		u.changes.Publish(users.InsertEvent(*created))
+		return nil
+	case isUniqueViolation(err):
+		return users.ErrUserAlreadyExists
+	default:
+		return err
+	}
+}
+
+func (u *userRepository) UpdateUser(ctx context.Context, user users.User) (*users.User, error) {
+	u.logger.Info("Updating user in the database")
+
+	_, err := uuid.Parse(user.ID)
+	if err != nil {
+		return nil, users.ErrInvalidID
+	}
+
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+
+	// The writes are serialized, so the user cannot change before it is updated
+	before, err := scanUser(u.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", user.ID))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, users.ErrUserNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	row := u.db.QueryRowContext(ctx,
+		`UPDATE users SET first_name = ?, last_name = ?, nickname = ?, email = ?, country = ?, updated_at = ?
+WHERE id = ? RETURNING `+userColumns,
+		user.FirstName, user.LastName, user.Nickname, user.Email, user.Country, time.Now().UnixMilli(), user.ID,
+	)
+
+	res, err := scanUser(row)
+	switch {
+	case err == nil:
+		u.changes.Publish(users.UpdateEvent(*before, *res))
+		return res, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, users.ErrUserNotFound
+	case isUniqueViolation(err):
+		return nil, users.ErrUserAlreadyExists
+	default:
+		return nil, err
+	}
+}
+
+func (u *userRepository) DeleteUser(ctx context.Context, id string) error {
+	u.logger.Info("Deleting user from the database", zap.String("id", id))
+
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return users.ErrInvalidID
+	}
+
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+
+	deleted, err := scanUser(u.db.QueryRowContext(ctx, "DELETE FROM users WHERE id = ? RETURNING "+userColumns, id))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return users.ErrUserNotFound
+	case err != nil:
+		return err
+	}
+
+	u.changes.Publish(users.DeleteEvent(*deleted))
+	return nil
+}
+
+func (u *userRepository) GetUser(ctx context.Context, id string) (*users.User, error) {
+	u.logger.Info("Getting a user from the database", zap.String("id", id))
+
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return nil, users.ErrInvalidID
+	}
+
+	row := u.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", id)
+	user, err := scanUser(row)
+	switch {
+	case err == nil:
+		return user, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, users.ErrUserNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (u *userRepository) GetUsers(ctx context.Context, query users.Query) (*users.UserPage, error) {
+	ordering, err := query.Ordering()
+	if err != nil {
+		return nil, err
+	}
+
+	// Continue after the last user of the previous page
+	cursor, err := query.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	conditions, args, err := filterConditions(query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.list(ctx, query, ordering, cursor, "users", conditions, args)
+}
+
+func (u *userRepository) SearchUsers(ctx context.Context, query users.SearchQuery) (*users.UserPage, error) {
+	ordering, err := query.Ordering()
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := query.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	switch query.Mode {
+	case users.SearchPrefix:
+		conditions, args, err := filterConditions(query.Query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		conditions = append(conditions, `(lower(first_name) LIKE ? ESCAPE '\' OR lower(last_name) LIKE ? ESCAPE '\'
+OR lower(nickname) LIKE ? ESCAPE '\' OR lower(email) LIKE ? ESCAPE '\')`)
+
+		pattern := likeEscaper.Replace(query.Prefix()) + "%"
+		args = append(args, pattern, pattern, pattern, pattern)
+
+		return u.list(ctx, query.Query, ordering, cursor, "users", conditions, args)
+	case users.SearchFullText:
+		// The terms only contain letters and digits, so they are safe to quote in a query matching any of them
+		terms := lo.Map(query.Terms(), func(term string, _ int) string {
+			return `"` + term + `"`
+		})
+
+		// The lower rank is the better match, so it is negated to get the relevance
+		args := []any{strings.Join(terms, " OR ")}
+		source := `(SELECT ` + userColumns + `, relevance FROM users JOIN
+(SELECT id AS search_id, -rank AS relevance FROM users_search WHERE users_search MATCH ?) ON search_id = users.id) AS search_results`
+
+		conditions, args, err := filterConditions(query.Query, args)
+		if err != nil {
+			return nil, err
+		}
+
+		return u.list(ctx, query.Query, ordering, cursor, source, conditions, args)
+	case users.SearchFuzzy:
+		// Only the MongoDB repository keeps the trigrams of the nicknames, so all the filtered users are compared
+		conditions, args, err := filterConditions(query.Query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates, err := u.all(ctx, conditions, args)
+		if err != nil {
+			return nil, err
+		}
+
+		return query.FuzzyPage(candidates)
+	default:
+		return nil, users.ErrInvalidSearch
+	}
+}
+
+// all returns all the users matching the conditions.
+func (u *userRepository) all(ctx context.Context, conditions []string, args []any) ([]users.User, error) {
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := u.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users"+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := []users.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, *user)
+	}
+
+	return all, rows.Err()
+}
+
+// list returns a page of the users from the source matching the conditions, sorted in the ordering. When sorting by
+// relevance, the source must have a relevance column.
+func (u *userRepository) list(ctx context.Context, query users.Query, ordering users.Ordering, cursor *users.Cursor, source string, conditions []string, args []any) (*users.UserPage, error) {
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	page := &users.UserPage{Users: []users.User{}}
+	if query.IncludeTotalSize {
+		total := int64(0)
+		err := u.db.QueryRowContext(ctx, "SELECT count(*) FROM "+source+where, args...).Scan(&total)
+		if err != nil {
+			return nil, err
+		}
+
+		page.TotalSize = &total
+	}
+
+	if cursor != nil {
+		var condition string
+		condition, args = afterCursor(ordering, cursor, args)
+		conditions = append(conditions, condition)
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	columns := userColumns
+	if ordering.ByRelevance() {
+		columns += ", " + users.FieldRelevance
+	}
+
+	// Fetching an extra user to know if there is a next page
+	pageSize := query.PageSize()
+	args = append(args, pageSize+1, query.Skip())
+	sqlQuery := "SELECT " + columns + " FROM " + source + where + " ORDER BY " + orderBy(ordering) + " LIMIT ? OFFSET ?"
+
+	u.logger.Info("Getting users from the database", zap.String("query", sqlQuery))
+
+	rows, err := u.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	last := users.Cursor{}
+	for rows.Next() {
+		var relevance float64
+
+		extra := []any{}
+		if ordering.ByRelevance() {
+			extra = append(extra, &relevance)
+		}
+
+		user, err := scanUser(rows, extra...)
+		if err != nil {
+			return nil, err
+		}
+
+		// The extra user only signals that there is a next page
+		if int64(len(page.Users)) == pageSize {
+			page.NextPageToken = last.Encode()
+			break
+		}
+
+		page.Users = append(page.Users, *user)
+		if ordering.ByRelevance() {
+			last = users.NewRelevanceCursor(user.ID, relevance)
+		} else {
+			last = ordering.NewCursor(*user)
+		}
+	}
+
+	return page, rows.Err()
+}
+
+func (u *userRepository) GetUserStats(ctx context.Context, query users.StatsQuery) (*users.UserStats, error) {
+	conditions, args, err := filterConditions(users.Query{CreatedAfter: query.CreatedAfter, CreatedBefore: query.CreatedBefore}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	stats := &users.UserStats{Countries: []users.CountryCount{}, SignUps: []users.SignUpBucket{}}
+
+	// The total is the sum of the users in all the countries
+	rows, err := u.db.QueryContext(ctx, `SELECT country, count(*) AS users FROM users`+where+`
+GROUP BY country ORDER BY users DESC, country`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		country := users.CountryCount{}
+		err = rows.Scan(&country.Country, &country.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Total += country.Count
+		stats.Countries = append(stats.Countries, country)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = u.db.QueryContext(ctx, `SELECT `+bucketStart(query.BucketInterval())+` AS start, count(*) FROM users`+where+`
+GROUP BY start ORDER BY start`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var start string
+		bucket := users.SignUpBucket{}
+		err = rows.Scan(&start, &bucket.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		bucket.Start, err = time.Parse(time.DateOnly, start)
+		if err != nil {
+			return nil, err
+		}
+
+		stats.SignUps = append(stats.SignUps, bucket)
+	}
+
+	return stats, rows.Err()
+}
+
+// bucketStart returns the expression of the date the histogram bucket of the user's creation starts at.
+func bucketStart(interval users.StatsInterval) string {
+	switch interval {
+	case users.StatsWeek:
+		// Moves to the next Sunday, unless it is already a Sunday, and back to the Monday before it
+		return `date(created_at / 1000, 'unixepoch', 'weekday 0', '-6 days')`
+	case users.StatsMonth:
+		return `date(created_at / 1000, 'unixepoch', 'start of month')`
+	default:
+		return `date(created_at / 1000, 'unixepoch')`
+	}
+}
+
+func (u *userRepository) Watch(ctx context.Context, query users.WatchQuery) (<-chan users.UserEvent, error) {
+	return u.changes.Watch(ctx, query)
+}
+
+// Snapshot reads all the users, sorted by their ID. The writes are held off while reading, so no change is
+// published between reading the users and the position of the changes.
+func (u *userRepository) Snapshot(ctx context.Context) (*users.Snapshot, error) {
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+
+	all, err := u.all(ctx, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(all, func(a, b users.User) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return &users.Snapshot{
+		Users:       all,
+		ResumeToken: u.changes.Position(),
+		Time:        time.Now().UTC(),
+	}, nil
+}
+
+// filterConditions returns the conditions matching the query's fields, adding their values to the args.
+func filterConditions(query users.Query, args []any) ([]string, []any, error) {
+	conditions := []string{}
+
+	addCondition := func(column string, value *string) {
+		if value == nil {
+			return
+		}
+
+		args = append(args, *value)
+		conditions = append(conditions, column+" = ?")
+	}
+
+	addCondition("first_name", query.FirstName)
+	addCondition("last_name", query.LastName)
+	addCondition("nickname", query.Nickname)
+	addCondition("country", query.Country)
+	addCondition("email", query.Email)
+
+	if len(query.Countries) > 0 {
+		args = append(args, lo.ToAnySlice(query.Countries)...)
+		conditions = append(conditions, "country IN ("+placeholders(len(query.Countries))+")")
+	}
+
+	if len(query.ExcludedCountries) > 0 {
+		args = append(args, lo.ToAnySlice(query.ExcludedCountries)...)
+		conditions = append(conditions, "country NOT IN ("+placeholders(len(query.ExcludedCountries))+")")
+	}
+
+	addTimeCondition := func(condition string, value *time.Time) {
+		if value == nil {
+			return
+		}
+
+		args = append(args, value.UnixMilli())
+		conditions = append(conditions, condition)
+	}
+
+	// The time ranges include their start, but not their end
+	addTimeCondition("created_at >= ?", query.CreatedAfter)
+	addTimeCondition("created_at < ?", query.CreatedBefore)
+	addTimeCondition("updated_at >= ?", query.UpdatedAfter)
+	addTimeCondition("updated_at < ?", query.UpdatedBefore)
+
+	expression, err := query.Expression()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if expression != nil {
+		var condition string
+		condition, args = expressionCondition(expression, args)
+		conditions = append(conditions, condition)
+	}
+
+	return conditions, args, nil
+}
+
+// placeholders returns n comma separated placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// expressionCondition compiles the filter expression to a condition, adding the compared values to the args.
+// The filterable fields are the names of the columns.
+func expressionCondition(expression users.Expression, args []any) (string, []any) {
+	switch expression := expression.(type) {
+	case users.AndExpression:
+		return joinedCondition(expression.Expressions, " AND ", args)
+	case users.OrExpression:
+		return joinedCondition(expression.Expressions, " OR ", args)
+	case users.NotExpression:
+		condition, args := expressionCondition(expression.Expression, args)
+		return "NOT " + condition, args
+	case users.Comparison:
+		operator := string(expression.Operator)
+		if expression.Operator == users.OperatorNotEqual {
+			operator = "<>"
+		}
+
+		value := expression.Value
+		if t, ok := value.(time.Time); ok {
+			value = t.UnixMilli()
+		}
+
+		args = append(args, value)
+		return "(" + expression.Field + " " + operator + " ?)", args
+	default:
+		return "FALSE", args
+	}
+}
+
+// joinedCondition joins the conditions of the expressions using the operator.
+func joinedCondition(expressions []users.Expression, operator string, args []any) (string, []any) {
+	conditions := make([]string, 0, len(expressions))
+	for _, expression := range expressions {
+		var condition string
+		condition, args = expressionCondition(expression, args)
+		conditions = append(conditions, condition)
+	}
+
+	return "(" + strings.Join(conditions, operator) + ")", args
+}
+
+// orderBy returns the ORDER BY clause sorting the users in the ordering.
+func orderBy(ordering users.Ordering) string {
+	clauses := make([]string, 0, len(ordering)+1)
+	for _, field := range ordering {
+		clauses = append(clauses, field.Field+direction(field.Descending))
+	}
+
+	return strings.Join(append(clauses, "id"+direction(ordering.IDDescending())), ", ")
+}
+
+// afterCursor returns the condition matching the users listed after the cursor, adding its values to the args.
+// The users are after the cursor if any of the ordered fields is after the cursor's value, while the previous
+// fields are equal.
+func afterCursor(ordering users.Ordering, cursor *users.Cursor, args []any) (string, []any) {
+	alternatives := []string{}
+	equal := []string{}
+	equalArgs := []any{}
+
+	addField := func(column string, descending bool, value any) {
+		// Times are stored as Unix milliseconds
+		if t, ok := value.(time.Time); ok {
+			value = t.UnixMilli()
+		}
+
+		operator := " > ?"
+		if descending {
+			operator = " < ?"
+		}
+
+		after := append(slices.Clone(equal), column+operator)
+		alternatives = append(alternatives, "("+strings.Join(after, " AND ")+")")
+		args = append(append(args, equalArgs...), value)
+
+		equal = append(equal, column+" = ?")
+		equalArgs = append(equalArgs, value)
+	}
+
+	for i, field := range ordering {
+		addField(field.Field, field.Descending, cursor.Values[i])
+	}
+	addField("id", ordering.IDDescending(), cursor.ID)
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func direction(descending bool) string {
+	if descending {
+		return " DESC"
+	}
+
+	return " ASC"
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser scans the user. The extra destinations are scanned after the user's columns.
+func scanUser(row scanner, extra ...any) (*users.User, error) {
+	var (
+		user      users.User
+		createdAt int64
+		updatedAt int64
+	)
+
+	dest := []any{&user.ID, &user.FirstName, &user.LastName, &user.Nickname, &user.Email, &user.Country, &createdAt, &updatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	user.CreatedAt = time.UnixMilli(createdAt).UTC()
+	user.UpdatedAt = time.UnixMilli(updatedAt).UTC()
+	return &user, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// Close stops delivering the changes to the watchers.
+func (u *userRepository) Close(ctx context.Context) error {
+	return u.changes.Close(ctx)
+}
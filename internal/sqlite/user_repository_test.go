@@ -0,0 +1,22 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users/repositorytest"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"go.uber.org/zap"
+)
+
+func TestUserRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) users.Repository {
+		db, _ := Connect(Configuration{Path: filepath.Join(t.TempDir(), "users.db")}, zap.NewNop())
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		return NewUserRepository(db, broadcast.Config{BufferSize: 100})
+	})
+}
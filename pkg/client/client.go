@@ -0,0 +1,298 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	usergrpc "github.com/xBlaz3kx/faceit-task/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrNotFound      = errors.New("user not found")
+	ErrAlreadyExists = errors.New("user already exists")
+	ErrInvalid       = errors.New("invalid request")
+
+	// ErrUnauthenticated is returned when the service requires a valid token.
+	ErrUnauthenticated = errors.New("unauthenticated")
+
+	// ErrResumeTokenExpired is returned when the changes after the resume token or the start time are no longer kept.
+	ErrResumeTokenExpired = errors.New("resume token expired")
+)
+
+type Config struct {
+	// Address of the user service, e.g. localhost:8080
+	Address string `yaml:"address" json:"address" mapstructure:"address" validate:"required"`
+
+	// Timeout is the deadline of each call attempt, zero only uses the deadline of the context
+	Timeout time.Duration `yaml:"timeout" json:"timeout" mapstructure:"timeout" validate:"gte=0"`
+
+	// MaxRetries is the number of times the idempotent calls are retried while the service is unavailable
+	MaxRetries int `yaml:"maxRetries" json:"maxRetries" mapstructure:"maxRetries" validate:"gte=0"`
+
+	// RetryBackoff is the delay before the first retry, doubled with each retry up to the MaxBackoff
+	RetryBackoff time.Duration `yaml:"retryBackoff" json:"retryBackoff" mapstructure:"retryBackoff" validate:"gte=0"`
+
+	// MaxBackoff caps the delay between the retries and the reconnects of the watchers and informers
+	MaxBackoff time.Duration `yaml:"maxBackoff" json:"maxBackoff" mapstructure:"maxBackoff" validate:"gte=0"`
+
+	// Token is the bearer token sent with the calls, empty sends no token
+	Token string `yaml:"token" json:"token" mapstructure:"token"`
+}
+
+// DefaultConfig returns the configuration of the service at the address with the recommended timeouts and retries.
+func DefaultConfig(address string) Config {
+	return Config{
+		Address:      address,
+		Timeout:      10 * time.Second,
+		MaxRetries:   3,
+		RetryBackoff: 100 * time.Millisecond,
+		MaxBackoff:   30 * time.Second,
+	}
+}
+
+// Client is a typed client of the user service. The calls are made with the configured deadline, and the
+// idempotent calls are retried while the service is unavailable.
+type Client struct {
+	cfg   Config
+	conn  *grpc.ClientConn
+	users usergrpc.UserClient
+}
+
+// New connects to the user service. Without any dial options, the connection is not encrypted.
+func New(cfg Config, opts ...grpc.DialOption) (*Client, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+
+	if cfg.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(cfg.Token)))
+	}
+
+	conn, err := grpc.NewClient(cfg.Address, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		cfg:   cfg,
+		conn:  conn,
+		users: usergrpc.NewUserClient(conn),
+	}, nil
+}
+
+// Close closes the connection to the user service.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// CreateUser creates the user. It is never retried, as a retry could create the user twice.
+func (c *Client) CreateUser(ctx context.Context, user NewUser) (*User, error) {
+	var response *usergrpc.CreateUserResponse
+	err := c.invoke(ctx, false, func(ctx context.Context) (err error) {
+		response, err = c.users.CreateUser(ctx, &usergrpc.CreateUserRequest{
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Nickname:  user.Nickname,
+			Email:     user.Email,
+			Password:  user.Password,
+			Country:   user.Country,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	created := fromGrpcUser(response.GetUser())
+	return &created, nil
+}
+
+func (c *Client) GetUser(ctx context.Context, id string) (*User, error) {
+	var response *usergrpc.GetUserResponse
+	err := c.invoke(ctx, true, func(ctx context.Context) (err error) {
+		response, err = c.users.GetUser(ctx, &usergrpc.GetUserRequest{Id: id})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user := fromGrpcUser(response.GetUser())
+	return &user, nil
+}
+
+// UpdateUser replaces the fields of the user with the update.
+func (c *Client) UpdateUser(ctx context.Context, id string, update UserUpdate) (*User, error) {
+	var response *usergrpc.UpdateUserResponse
+	err := c.invoke(ctx, true, func(ctx context.Context) (err error) {
+		response, err = c.users.UpdateUser(ctx, &usergrpc.UpdateUserRequest{
+			Id:        id,
+			FirstName: update.FirstName,
+			LastName:  update.LastName,
+			Nickname:  update.Nickname,
+			Email:     update.Email,
+			Password:  update.Password,
+			Country:   update.Country,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user := fromGrpcUser(response.GetUser())
+	return &user, nil
+}
+
+// DeleteUser deletes the user. When retried, the user might have been deleted by the previous attempt, so
+// ErrNotFound is only returned if the first attempt did not find the user.
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	attempts := 0
+	return c.invoke(ctx, true, func(ctx context.Context) error {
+		attempts++
+
+		_, err := c.users.DeleteUser(ctx, &usergrpc.DeleteUserRequest{Id: id})
+		if status.Code(err) == codes.NotFound && attempts > 1 {
+			return nil
+		}
+
+		return err
+	})
+}
+
+// ListUsers returns a page of the users matching the options.
+func (c *Client) ListUsers(ctx context.Context, opts ListOptions) (*UserPage, error) {
+	request := &usergrpc.ListUsersRequest{
+		Countries:        opts.Countries,
+		IncludeTotalSize: opts.IncludeTotalSize,
+	}
+
+	if opts.Limit > 0 {
+		request.Limit = &opts.Limit
+	}
+
+	if opts.PageToken != "" {
+		request.PageToken = &opts.PageToken
+	}
+
+	if opts.OrderBy != "" {
+		request.OrderBy = &opts.OrderBy
+	}
+
+	if opts.Filter != "" {
+		request.Filter = &opts.Filter
+	}
+
+	var response *usergrpc.ListUsersResponse
+	err := c.invoke(ctx, true, func(ctx context.Context) (err error) {
+		response, err = c.users.GetUsers(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{
+		Users:         make([]User, 0, len(response.GetUsers())),
+		NextPageToken: response.GetNextPageToken(),
+		TotalSize:     response.TotalSize,
+	}
+	for _, user := range response.GetUsers() {
+		page.Users = append(page.Users, fromGrpcUser(user))
+	}
+
+	return page, nil
+}
+
+// invoke makes the call with the configured deadline, retrying the idempotent calls while the service is unavailable.
+func (c *Client) invoke(ctx context.Context, idempotent bool, call func(ctx context.Context) error) error {
+	backoff := c.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		callCtx, cancel := c.withTimeout(ctx)
+		err := call(callCtx)
+		cancel()
+
+		if err == nil || !idempotent || attempt >= c.cfg.MaxRetries || !c.retryable(ctx, err) {
+			return toError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return toError(err)
+		case <-time.After(backoff):
+		}
+
+		backoff = c.nextBackoff(backoff)
+	}
+}
+
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.cfg.Timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, c.cfg.Timeout)
+}
+
+// retryable checks if the call failed as the service was unavailable, or the attempt timed out before the call's
+// own deadline.
+func (c *Client) retryable(ctx context.Context, err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return true
+	case codes.DeadlineExceeded:
+		return ctx.Err() == nil
+	default:
+		return false
+	}
+}
+
+// nextBackoff doubles the backoff up to the MaxBackoff.
+func (c *Client) nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if c.cfg.MaxBackoff > 0 && backoff > c.cfg.MaxBackoff {
+		return c.cfg.MaxBackoff
+	}
+
+	return backoff
+}
+
+// toError marks the errors of the service with the matching errors of the client, keeping the status.
+func toError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch status.Code(err) {
+	case codes.NotFound:
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case codes.AlreadyExists:
+		return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	case codes.OutOfRange:
+		return fmt.Errorf("%w: %w", ErrResumeTokenExpired, err)
+	case codes.Unauthenticated:
+		return fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	default:
+		return err
+	}
+}
+
+// bearerToken sends the token in the authorization metadata of the calls.
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity allows sending the token over the unencrypted connections, e.g. inside the cluster.
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xBlaz3kx/faceit-task/internal/domain/users"
+	usergrpc "github.com/xBlaz3kx/faceit-task/internal/grpc"
+	"github.com/xBlaz3kx/faceit-task/internal/memory"
+	"github.com/xBlaz3kx/faceit-task/internal/pkg/broadcast"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const eventTimeout = 5 * time.Second
+
+// newTestClient serves the user service backed by the in-memory repository, returning the client connected to it.
+func newTestClient(t *testing.T, heartbeatInterval time.Duration) *Client {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	repository := memory.NewUserRepository(broadcast.Config{BufferSize: 100})
+	t.Cleanup(func() {
+		_ = repository.Close(context.Background())
+	})
+	service := users.NewUserService(repository, 0)
+	usergrpc.RegisterUserServer(server, usergrpc.NewUserGrpcHandler(service, heartbeatInterval))
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	cfg := DefaultConfig("passthrough:///bufnet")
+	cfg.RetryBackoff = 10 * time.Millisecond
+	client, err := New(cfg,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+func newUser(nickname, country string) NewUser {
+	return NewUser{
+		FirstName: "First " + nickname,
+		LastName:  "Last " + nickname,
+		Nickname:  nickname,
+		Email:     nickname + "@faceit.com",
+		Password:  "password",
+		Country:   country,
+	}
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, 0)
+
+	created, err := client.CreateUser(ctx, newUser("s1mple", "UA"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+
+	_, err = client.CreateUser(ctx, newUser("s1mple", "UA"))
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	user, err := client.GetUser(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, user.ID)
+	assert.Equal(t, "s1mple", user.Nickname)
+	assert.False(t, user.CreatedAt.IsZero())
+
+	updated, err := client.UpdateUser(ctx, created.ID, UserUpdate{
+		FirstName: "Oleksandr",
+		LastName:  "Kostyliev",
+		Nickname:  "s1mple",
+		Email:     "s1mple@faceit.com",
+		Country:   "UA",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Oleksandr", updated.FirstName)
+
+	_, err = client.CreateUser(ctx, newUser("zywoo", "FR"))
+	require.NoError(t, err)
+
+	page, err := client.ListUsers(ctx, ListOptions{Countries: []string{"UA"}, IncludeTotalSize: true})
+	require.NoError(t, err)
+	require.Len(t, page.Users, 1)
+	assert.Equal(t, created.ID, page.Users[0].ID)
+	require.NotNil(t, page.TotalSize)
+	assert.EqualValues(t, 1, *page.TotalSize)
+
+	_, err = client.ListUsers(ctx, ListOptions{Filter: "country =="})
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	require.NoError(t, client.DeleteUser(ctx, created.ID))
+
+	_, err = client.GetUser(ctx, created.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, codes.NotFound, status.Code(err), "the status is kept")
+}
+
+func TestClient_Retries(t *testing.T) {
+	client := &Client{cfg: Config{MaxRetries: 2, RetryBackoff: time.Millisecond}}
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	tests := []struct {
+		name       string
+		idempotent bool
+		errs       []error
+		attempts   int
+		expected   codes.Code
+	}{
+		{name: "Retried until it succeeds", idempotent: true, errs: []error{unavailable, unavailable, nil}, attempts: 3, expected: codes.OK},
+		{name: "Retries are limited", idempotent: true, errs: []error{unavailable, unavailable, unavailable, nil}, attempts: 3, expected: codes.Unavailable},
+		{name: "Not idempotent", idempotent: false, errs: []error{unavailable, nil}, attempts: 1, expected: codes.Unavailable},
+		{name: "Not retryable", idempotent: true, errs: []error{status.Error(codes.Internal, "failed"), nil}, attempts: 1, expected: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := client.invoke(context.Background(), tt.idempotent, func(ctx context.Context) error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			assert.Equal(t, tt.expected, status.Code(err))
+			assert.Equal(t, tt.attempts, attempts)
+		})
+	}
+}
+
+func TestClient_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := newTestClient(t, 10*time.Millisecond)
+	events := client.Watch(ctx, WatchOptions{ChangeTypes: []ChangeType{ChangeInsert}})
+
+	// The heartbeats are not delivered
+	time.Sleep(50 * time.Millisecond)
+
+	created, err := client.CreateUser(ctx, newUser("s1mple", "UA"))
+	require.NoError(t, err)
+
+	event := receiveEvent(t, events)
+	assert.Equal(t, ChangeInsert, event.ChangeType)
+	assert.Equal(t, created.ID, event.User.ID)
+	assert.Equal(t, "s1mple", event.User.Nickname)
+	assert.NotEmpty(t, event.ResumeToken)
+
+	t.Run("Invalid filter", func(t *testing.T) {
+		events := client.Watch(ctx, WatchOptions{ChangeTypes: []ChangeType{"upsert"}})
+
+		event := receiveEvent(t, events)
+		assert.ErrorIs(t, event.Err, ErrInvalid)
+
+		_, ok := <-events
+		assert.False(t, ok, "the events channel was not closed")
+	})
+}
+
+func receiveEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "the events channel was closed")
+		return event
+	case <-time.After(eventTimeout):
+		require.FailNow(t, "timed out waiting for an event")
+		return Event{}
+	}
+}
@@ -0,0 +1,264 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	usergrpc "github.com/xBlaz3kx/faceit-task/internal/grpc"
+	"go.uber.org/zap"
+)
+
+// EventHandler is notified about the changes of the informer's users. Any of the callbacks can be nil.
+type EventHandler struct {
+	OnAdd    func(user User)
+	OnUpdate func(oldUser, newUser User)
+	OnDelete func(user User)
+}
+
+// Informer keeps a local replica of all the users, updated with the changes made to them. The replica is read
+// using SyncUsers, so it is consistent with the changes applied after it. After a reconnect, the users are read
+// again and the differences are notified to the handlers, so the replica catches up with the missed changes.
+type Informer struct {
+	client *Client
+	logger *zap.Logger
+
+	// mu guards the replica of the users.
+	mu     sync.RWMutex
+	users  map[string]User
+	synced chan struct{}
+
+	handlersMu sync.RWMutex
+	handlers   []EventHandler
+}
+
+// NewInformer creates an informer of the users. The replica is empty until the informer is started with Run.
+func (c *Client) NewInformer() *Informer {
+	return &Informer{
+		client: c,
+		logger: zap.L().Named("user-informer"),
+		users:  map[string]User{},
+		synced: make(chan struct{}),
+	}
+}
+
+// AddEventHandler registers the handler for the changes made after registering it. The handlers are called one at
+// a time, in the order of the changes, after the replica was updated.
+func (i *Informer) AddEventHandler(handler EventHandler) {
+	i.handlersMu.Lock()
+	defer i.handlersMu.Unlock()
+
+	i.handlers = append(i.handlers, handler)
+}
+
+// Run keeps the replica up to date until the context is done, reconnecting with a backoff when the stream fails.
+func (i *Informer) Run(ctx context.Context) {
+	backoff := i.client.cfg.RetryBackoff
+	for {
+		received, err := i.sync(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if received {
+			backoff = i.client.cfg.RetryBackoff
+		}
+
+		i.logger.Warn("Users stream failed, syncing again", zap.Error(err), zap.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = i.client.nextBackoff(backoff)
+	}
+}
+
+// HasSynced checks if the replica was read at least once.
+func (i *Informer) HasSynced() bool {
+	select {
+	case <-i.synced:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitForSync blocks until the replica was read at least once, or the context is done.
+func (i *Informer) WaitForSync(ctx context.Context) error {
+	select {
+	case <-i.synced:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Get returns the user from the replica.
+func (i *Informer) Get(id string) (User, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	user, ok := i.users[id]
+	return user, ok
+}
+
+// List returns all the users of the replica, sorted by their ID.
+func (i *Informer) List() []User {
+	i.mu.RLock()
+	all := make([]User, 0, len(i.users))
+	for _, user := range i.users {
+		all = append(all, user)
+	}
+	i.mu.RUnlock()
+
+	sort.Slice(all, func(a, b int) bool {
+		return all[a].ID < all[b].ID
+	})
+
+	return all
+}
+
+// sync reads the snapshot of the users and applies the changes made after it until the stream fails. Returns whether
+// the snapshot was received before the stream failed.
+func (i *Informer) sync(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := i.client.users.SyncUsers(ctx, &usergrpc.SyncUsersRequest{})
+	if err != nil {
+		return false, err
+	}
+
+	snapshot := map[string]User{}
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			return false, toError(err)
+		}
+
+		if user := response.GetUser(); user != nil {
+			snapshot[user.GetId()] = fromGrpcUser(user)
+			continue
+		}
+
+		if response.GetSnapshotEnd() != nil {
+			break
+		}
+	}
+
+	i.replace(snapshot)
+
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			return true, toError(err)
+		}
+
+		change := response.GetChange()
+		if change == nil || change.GetChangeType() == usergrpc.ChangeType_HEARTBEAT {
+			continue
+		}
+
+		i.apply(fromGrpcEvent(change))
+	}
+}
+
+// replace replaces the replica with the snapshot, notifying the handlers about the differences.
+func (i *Informer) replace(snapshot map[string]User) {
+	i.mu.Lock()
+	previous := i.users
+	i.users = snapshot
+	i.mu.Unlock()
+
+	for id, user := range snapshot {
+		old, existed := previous[id]
+		switch {
+		case !existed:
+			i.notifyAdd(user)
+		case old != user:
+			i.notifyUpdate(old, user)
+		}
+	}
+
+	for id, user := range previous {
+		if _, exists := snapshot[id]; !exists {
+			i.notifyDelete(user)
+		}
+	}
+
+	if !i.HasSynced() {
+		close(i.synced)
+	}
+}
+
+// apply updates the replica with the change, notifying the handlers.
+func (i *Informer) apply(event Event) {
+	i.mu.Lock()
+	old, existed := i.users[event.User.ID]
+
+	switch event.ChangeType {
+	case ChangeDelete:
+		delete(i.users, event.User.ID)
+		i.mu.Unlock()
+
+		if existed {
+			i.notifyDelete(old)
+		}
+	case ChangeInsert, ChangeUpdate:
+		// The updated user is looked up after the change, so it is missing when it was deleted since
+		if event.User.CreatedAt.IsZero() {
+			i.mu.Unlock()
+			return
+		}
+
+		i.users[event.User.ID] = event.User
+		i.mu.Unlock()
+
+		if existed {
+			i.notifyUpdate(old, event.User)
+			return
+		}
+
+		i.notifyAdd(event.User)
+	default:
+		i.mu.Unlock()
+		i.logger.Warn("Unknown change type", zap.Any("changeType", event.ChangeType))
+	}
+}
+
+func (i *Informer) notifyAdd(user User) {
+	i.notify(func(handler EventHandler) {
+		if handler.OnAdd != nil {
+			handler.OnAdd(user)
+		}
+	})
+}
+
+func (i *Informer) notifyUpdate(oldUser, newUser User) {
+	i.notify(func(handler EventHandler) {
+		if handler.OnUpdate != nil {
+			handler.OnUpdate(oldUser, newUser)
+		}
+	})
+}
+
+func (i *Informer) notifyDelete(user User) {
+	i.notify(func(handler EventHandler) {
+		if handler.OnDelete != nil {
+			handler.OnDelete(user)
+		}
+	})
+}
+
+func (i *Informer) notify(call func(handler EventHandler)) {
+	i.handlersMu.RLock()
+	handlers := i.handlers
+	i.handlersMu.RUnlock()
+
+	for _, handler := range handlers {
+		call(handler)
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInformer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := newTestClient(t, 0)
+
+	s1mple, err := client.CreateUser(ctx, newUser("s1mple", "UA"))
+	require.NoError(t, err)
+
+	notified := make(chan string, 10)
+	informer := client.NewInformer()
+	informer.AddEventHandler(EventHandler{
+		OnAdd: func(user User) {
+			notified <- "add " + user.Nickname
+		},
+		OnUpdate: func(oldUser, newUser User) {
+			notified <- "update " + oldUser.Nickname + " " + newUser.Nickname
+		},
+		OnDelete: func(user User) {
+			notified <- "delete " + user.Nickname
+		},
+	})
+
+	assert.False(t, informer.HasSynced())
+	go informer.Run(ctx)
+
+	syncCtx, syncCancel := context.WithTimeout(ctx, eventTimeout)
+	defer syncCancel()
+	require.NoError(t, informer.WaitForSync(syncCtx))
+
+	// The users of the snapshot are added
+	assert.Equal(t, "add s1mple", receiveNotification(t, notified))
+	stored, err := client.GetUser(ctx, s1mple.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []User{*stored}, informer.List())
+
+	zywoo, err := client.CreateUser(ctx, newUser("zywoo", "FR"))
+	require.NoError(t, err)
+	assert.Equal(t, "add zywoo", receiveNotification(t, notified))
+
+	update := UserUpdate{FirstName: "Oleksandr", LastName: "Kostyliev", Nickname: "s1mple2", Email: "s1mple@faceit.com", Country: "UA"}
+	_, err = client.UpdateUser(ctx, s1mple.ID, update)
+	require.NoError(t, err)
+	assert.Equal(t, "update s1mple s1mple2", receiveNotification(t, notified))
+
+	user, ok := informer.Get(s1mple.ID)
+	require.True(t, ok)
+	assert.Equal(t, "Oleksandr", user.FirstName)
+
+	require.NoError(t, client.DeleteUser(ctx, zywoo.ID))
+	assert.Equal(t, "delete zywoo", receiveNotification(t, notified))
+
+	_, ok = informer.Get(zywoo.ID)
+	assert.False(t, ok)
+}
+
+func TestInformer_Resync(t *testing.T) {
+	informer := (&Client{}).NewInformer()
+
+	notified := []string{}
+	informer.AddEventHandler(EventHandler{
+		OnAdd:    func(user User) { notified = append(notified, "add "+user.ID) },
+		OnUpdate: func(oldUser, newUser User) { notified = append(notified, "update "+newUser.ID) },
+		OnDelete: func(user User) { notified = append(notified, "delete "+user.ID) },
+	})
+
+	now := time.Now().UTC()
+	informer.replace(map[string]User{
+		"1": {ID: "1", Nickname: "s1mple", CreatedAt: now},
+		"2": {ID: "2", Nickname: "zywoo", CreatedAt: now},
+	})
+	assert.True(t, informer.HasSynced())
+	assert.ElementsMatch(t, []string{"add 1", "add 2"}, notified)
+
+	// The changes missed while disconnected are notified after syncing again
+	notified = nil
+	informer.replace(map[string]User{
+		"2": {ID: "2", Nickname: "zywoo2", CreatedAt: now},
+		"3": {ID: "3", Nickname: "device", CreatedAt: now},
+	})
+	assert.ElementsMatch(t, []string{"delete 1", "update 2", "add 3"}, notified)
+}
+
+func receiveNotification(t *testing.T, notified <-chan string) string {
+	t.Helper()
+
+	select {
+	case notification := <-notified:
+		return notification
+	case <-time.After(eventTimeout):
+		require.FailNow(t, "timed out waiting for a notification")
+		return ""
+	}
+}
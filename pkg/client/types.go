@@ -0,0 +1,191 @@
+package client
+
+import (
+	"time"
+
+	usergrpc "github.com/xBlaz3kx/faceit-task/internal/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ChangeType is the type of change made to a user.
+type ChangeType string
+
+const (
+	ChangeInsert = ChangeType("insert")
+	ChangeUpdate = ChangeType("update")
+	ChangeDelete = ChangeType("delete")
+)
+
+type User struct {
+	ID        string    `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Nickname  string    `json:"nickname"`
+	Email     string    `json:"email"`
+	Country   string    `json:"country"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewUser is a user to create.
+type NewUser struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Nickname  string `json:"nickname"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	Country   string `json:"country"`
+}
+
+// UserUpdate replaces the fields of an existing user.
+type UserUpdate struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Nickname  string `json:"nickname"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	Country   string `json:"country"`
+}
+
+// ListOptions filter and paginate the listed users. Empty fields do not limit the users.
+type ListOptions struct {
+	// Limit is the number of users on the page, defaults to 30 and is capped at 100.
+	Limit int64
+
+	// PageToken continues with the page after the UserPage.NextPageToken.
+	PageToken string
+
+	// OrderBy are the comma separated fields to order the users by, e.g. "nickname asc, created_at desc".
+	OrderBy string
+
+	// Countries of the users to list.
+	Countries []string
+
+	// Filter is an AIP-160 filter expression, e.g. `country = "DE" OR country = "AT"`.
+	Filter string
+
+	// IncludeTotalSize counts all the users matching the filters.
+	IncludeTotalSize bool
+}
+
+type UserPage struct {
+	Users []User
+
+	// NextPageToken is the token of the next page, empty when there are no more users.
+	NextPageToken string
+
+	// TotalSize is the number of users matching the filters, only set with the ListOptions.IncludeTotalSize.
+	TotalSize *int64
+}
+
+// WatchOptions filter the watched changes, along with the position to start watching from. Empty fields do not
+// limit the changes.
+type WatchOptions struct {
+	ChangeTypes   []ChangeType
+	UserIDs       []string
+	Countries     []string
+	ChangedFields []string
+
+	// ResumeToken continues with the changes after the Event.ResumeToken.
+	ResumeToken string
+
+	// StartAt continues with the changes made at or after the time. Cannot be combined with the ResumeToken.
+	StartAt *time.Time
+}
+
+// Event is a change made to a user.
+type Event struct {
+	ChangeType ChangeType
+
+	// User is the changed user, deleted users only carry their ID.
+	User User
+
+	// ChangedFields are the fields changed by an update.
+	ChangedFields []string
+
+	// Before and After are the snapshots of the user before and after the change, when the service provides them.
+	Before *User
+	After  *User
+
+	// Time the change was made at.
+	Time time.Time
+
+	// ResumeToken continues watching after the change.
+	ResumeToken string
+
+	// Err is only set on the last event of a failed watch, after which the events channel is closed.
+	Err error
+}
+
+func fromGrpcUser(user *usergrpc.UserModel) User {
+	return User{
+		ID:        user.GetId(),
+		FirstName: user.GetName(),
+		LastName:  user.GetLastName(),
+		Nickname:  user.GetNickname(),
+		Email:     user.GetEmail(),
+		Country:   user.GetCountry(),
+		CreatedAt: fromTimestamp(user.GetCreatedAt()),
+		UpdatedAt: fromTimestamp(user.GetUpdatedAt()),
+	}
+}
+
+func fromGrpcEvent(response *usergrpc.WatchStreamResponse) Event {
+	event := Event{
+		ChangeType:    fromChangeType(response.GetChangeType()),
+		User:          fromGrpcUser(response.GetUser()),
+		ChangedFields: response.GetChangedFields(),
+		Time:          fromTimestamp(response.GetTime()),
+		ResumeToken:   response.GetResumeToken(),
+	}
+
+	// Deleted users only carry the ID
+	event.User.ID = response.GetUserId()
+
+	if response.GetBefore() != nil {
+		before := fromGrpcUser(response.GetBefore())
+		event.Before = &before
+	}
+
+	if response.GetAfter() != nil {
+		after := fromGrpcUser(response.GetAfter())
+		event.After = &after
+	}
+
+	return event
+}
+
+func fromChangeType(changeType usergrpc.ChangeType) ChangeType {
+	switch changeType {
+	case usergrpc.ChangeType_INSERT:
+		return ChangeInsert
+	case usergrpc.ChangeType_UPDATE:
+		return ChangeUpdate
+	case usergrpc.ChangeType_DELETE:
+		return ChangeDelete
+	default:
+		return ChangeType(changeType.String())
+	}
+}
+
+func toChangeType(changeType ChangeType) usergrpc.ChangeType {
+	switch changeType {
+	case ChangeInsert:
+		return usergrpc.ChangeType_INSERT
+	case ChangeUpdate:
+		return usergrpc.ChangeType_UPDATE
+	case ChangeDelete:
+		return usergrpc.ChangeType_DELETE
+	default:
+		// Rejected by the service as an invalid filter
+		return -1
+	}
+}
+
+func fromTimestamp(timestamp *timestamppb.Timestamp) time.Time {
+	if timestamp == nil {
+		return time.Time{}
+	}
+
+	return timestamp.AsTime()
+}
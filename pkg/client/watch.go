@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/lo"
+	usergrpc "github.com/xBlaz3kx/faceit-task/internal/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Watch returns the changes matching the options until the context is done. When the stream fails, the watch is
+// resumed after the last change or heartbeat received, so no change is missed. Once the changes can no longer be
+// resumed, e.g. with ErrResumeTokenExpired, the last event carries the error and the channel is closed.
+func (c *Client) Watch(ctx context.Context, opts WatchOptions) <-chan Event {
+	events := make(chan Event)
+
+	go func() {
+		defer close(events)
+
+		send := func(event Event) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		backoff := c.cfg.RetryBackoff
+		for {
+			received, err := c.watch(ctx, &opts, send)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case status.Code(err) != codes.Unavailable:
+				send(Event{Err: toError(err)})
+				return
+			case received:
+				// The stream was healthy, so the backoff starts over
+				backoff = c.cfg.RetryBackoff
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff = c.nextBackoff(backoff)
+		}
+	}()
+
+	return events
+}
+
+// watch sends the changes of a single stream, moving the options' position after each received change or heartbeat.
+// Returns whether anything was received before the stream failed.
+func (c *Client) watch(ctx context.Context, opts *WatchOptions, send func(Event) bool) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.users.Watch(ctx, toWatchRequest(*opts))
+	if err != nil {
+		return false, err
+	}
+
+	received := false
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			return received, err
+		}
+
+		received = true
+		switch {
+		case response.GetResumeToken() != "":
+			opts.ResumeToken = response.GetResumeToken()
+			opts.StartAt = nil
+		case opts.ResumeToken == "" && opts.StartAt == nil && response.GetTime() != nil:
+			// Before the first change, the watch is resumed from the time of the heartbeat
+			opts.StartAt = lo.ToPtr(response.GetTime().AsTime())
+		}
+
+		if response.GetChangeType() == usergrpc.ChangeType_HEARTBEAT {
+			continue
+		}
+
+		if !send(fromGrpcEvent(response)) {
+			return received, ctx.Err()
+		}
+	}
+}
+
+func toWatchRequest(opts WatchOptions) *usergrpc.WatchRequest {
+	request := &usergrpc.WatchRequest{
+		UserIds:       opts.UserIDs,
+		Countries:     opts.Countries,
+		ChangedFields: opts.ChangedFields,
+	}
+
+	for _, changeType := range opts.ChangeTypes {
+		request.ChangeTypes = append(request.ChangeTypes, toChangeType(changeType))
+	}
+
+	if opts.ResumeToken != "" {
+		request.ResumeToken = &opts.ResumeToken
+	}
+
+	if opts.StartAt != nil {
+		request.StartAt = timestamppb.New(*opts.StartAt)
+	}
+
+	return request
+}